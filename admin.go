@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/redhatinsights/sources-superkey-worker/admin"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// initAdminApi serves the admin API on its own port, apart from the metrics and the status API, as long as it is
+// protected by a PSK.
+func initAdminApi() {
+	if conf.AdminApiPSK == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	admin.RegisterHandlers(mux, adminActions{}, conf.AdminApiPSK)
+
+	go func() {
+		err := http.ListenAndServe(fmt.Sprintf(":%d", conf.AdminApiPort), mux)
+		if err != nil {
+			l.Log.Errorf("Admin API init error: %s", err)
+		}
+	}()
+}
+
+// adminActions implements the actions the admin API triggers, by running them the same way the requests coming from
+// Kafka are run.
+type adminActions struct{}
+
+// Create re-runs the creation of the resources for the application.
+func (adminActions) Create(ctx context.Context, req *superkey.CreateRequest) error {
+	unlock, err := lockApplication(ctx, req.IdentityHeader, req.OrgIdHeader, &req.SourceID, "", req.ApplicationID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx = l.WithSourceId(ctx, req.SourceID)
+	ctx = l.WithApplicationType(ctx, req.ApplicationType)
+
+	return createResources(ctx, req)
+}
+
+// TearDown tears down the resources of the application, fetching their state from Sources when the request does not
+// carry it.
+func (adminActions) TearDown(ctx context.Context, req *superkey.DestroyRequest) (*superkey.TeardownSummary, error) {
+	unlock, err := lockApplication(ctx, req.IdentityHeader, req.OrgIdHeader, &req.SourceID, req.GUID, req.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ctx = l.WithSourceId(ctx, req.SourceID)
+
+	if len(req.StepsCompleted) == 0 {
+		state, err := superkey.FetchSuperKeyState(ctx, req.IdentityHeader, req.OrgIdHeader, req.ApplicationID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch the state of the resources from Sources: %w", err)
+		}
+
+		req.GUID = state.GUID
+		req.StepsCompleted = state.Steps
+		if req.Provider == "" {
+			req.Provider = state.Provider
+		}
+	}
+
+	report, err := destroyResources(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := superkey.ReconstructForgedApplication(req).TeardownSummary(report)
+	if !report.Succeeded() {
+		return summary, report.Err()
+	}
+
+	return summary, nil
+}
+
+// Register repeats the registration in Sources of the application's resources, fetching their state from Sources when
+// the request does not carry it.
+func (adminActions) Register(ctx context.Context, req *admin.RegisterRequest) error {
+	unlock, err := lockApplication(ctx, req.IdentityHeader, req.OrgIdHeader, &req.SourceID, req.GUID, req.ApplicationID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx = l.WithSourceId(ctx, req.SourceID)
+	ctx = l.WithApplicationType(ctx, req.ApplicationType)
+
+	if len(req.StepsCompleted) == 0 {
+		state, err := superkey.FetchSuperKeyState(ctx, req.IdentityHeader, req.OrgIdHeader, req.ApplicationID)
+		if err != nil {
+			return fmt.Errorf("unable to fetch the state of the resources from Sources: %w", err)
+		}
+
+		req.GUID = state.GUID
+		req.StepsCompleted = state.Steps
+	}
+
+	return registerResources(ctx, &req.CreateRequest, req.GUID, req.StepsCompleted)
+}
+
+// lockApplication takes the same lock the consumer takes for the requests of the application's source, so that the
+// admin actions do not run concurrently with them. The source gets looked up from the application when the request
+// does not carry it.
+// returns: the function that releases the lock, or an error when the lock key cannot be determined.
+func lockApplication(ctx context.Context, identityHeader, orgIdHeader string, sourceId *string, guid, applicationId string) (func(), error) {
+	if *sourceId == "" && applicationId != "" {
+		sourcesClient, err := sources.Client()
+		if err != nil {
+			return nil, err
+		}
+
+		application, err := sourcesClient.GetApplication(ctx, &sources.AuthenticationData{IdentityHeader: identityHeader, OrgId: orgIdHeader}, applicationId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look the application's source up in Sources: %w", err)
+		}
+
+		*sourceId = application.SourceID
+	}
+
+	lockKey, err := applicationLockKey(*sourceId, guid)
+	if err != nil {
+		return nil, err
+	}
+
+	return applicationLocks.Lock(lockKey), nil
+}
+
+// registerResources registers in Sources the resources that were already forged for the request. Unlike a failed
+// creation, a failed registration does not tear the resources down, since they were forged by an earlier request.
+func registerResources(ctx context.Context, req *superkey.CreateRequest, guid string, stepsCompleted map[string]map[string]string) error {
+	// The registration needs the same steps the resources were forged with.
+	err := req.ResolveSteps(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the superkey steps of the request: %w", err)
+	}
+
+	f, err := provider.ReconstructForRegistration(req, guid, stepsCompleted)
+	if err != nil {
+		return err
+	}
+
+	// Registering again would create a second authentication for the same resources.
+	registered, err := f.IsRegistered(ctx)
+	if err != nil {
+		return err
+	}
+
+	if registered {
+		return fmt.Errorf("%w: the resources are already registered in Sources", admin.ErrSkipped)
+	}
+
+	err = f.CreateInSourcesAPI(ctx)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Error while registering the resources in Sources: %s`, err)
+		unsuccessfulResourcesCreationCounter.Inc()
+		return err
+	}
+
+	l.LogWithContext(ctx).Info("Registered the forged resources in Sources")
+	successfulResourcesCreationCounter.Inc()
+
+	return nil
+}
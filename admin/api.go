@@ -0,0 +1,276 @@
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"github.com/redhatinsights/sources-superkey-worker/internal/httpapi"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	"github.com/sirupsen/logrus"
+)
+
+// identityHeader is the header with the identity the gateway authenticated the caller with, which identifies who
+// requested the action for the audit trail.
+const identityHeader = "x-rh-identity"
+
+// The outcomes of the admin actions.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeSkipped   = "skipped"
+	OutcomeFailed    = "failed"
+	OutcomeRejected  = "rejected"
+)
+
+// ErrSkipped is wrapped by the errors of the actions that had nothing to do, which get reported with the "skipped"
+// outcome along with the reason.
+var ErrSkipped = errors.New("skipped")
+
+var adminActionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sources_superkey_admin_actions",
+	Help: "The number of actions triggered through the admin API, by action and outcome",
+}, []string{"action", "outcome"})
+
+// RegisterHandlers registers the admin endpoints in the given mux, which require the given PSK:
+//
+//	POST /admin/create      re-runs a create for an application, with a "create_application" request as the body
+//	POST /admin/teardown    tears down the resources of a "destroy_application" request, fetching the completed
+//	                        steps from the application's "_superkey" extra when the request does not carry them
+//	POST /admin/register    repeats only the registration in Sources of resources that were already forged
+func RegisterHandlers(mux *http.ServeMux, actions Actions, psk string) {
+	h := &handlers{actions: actions}
+
+	// Besides the PSK, the requests must carry an identity that tells who the actor is.
+	auth := httpapi.Authenticator{
+		PSK: psk,
+		Verify: func(r *http.Request) error {
+			_, err := identityActor(r.Header.Get(identityHeader))
+			return err
+		},
+		Rejected: func(r *http.Request, err error) {
+			l.Log.WithFields(logrus.Fields{"audit": true, "remote_addr": r.RemoteAddr, "path": r.URL.Path}).Warnf("Unauthorized admin API request: %s", err)
+		},
+	}
+
+	mux.Handle("POST /admin/create", auth.Authenticated(h.create))
+	mux.Handle("POST /admin/teardown", auth.Authenticated(h.teardown))
+	mux.Handle("POST /admin/register", auth.Authenticated(h.register))
+}
+
+// create re-runs the creation of the resources for an application.
+func (h *handlers) create(w http.ResponseWriter, r *http.Request) {
+	audit := newAuditEntry(r, "create")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		audit.reject(w, fmt.Errorf("unable to read the request body: %w", err))
+		return
+	}
+
+	err = superkey.ValidateRequest(superkey.DefaultSchemaVersion, "create_application", body)
+	if err != nil {
+		audit.reject(w, err)
+		return
+	}
+
+	req := &superkey.CreateRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		audit.reject(w, fmt.Errorf("unable to parse the request: %w", err))
+		return
+	}
+
+	audit.tenantId = req.TenantID
+	audit.applicationId = req.ApplicationID
+	audit.start()
+
+	err = h.actions.Create(audit.context(), req)
+	audit.finish(w, err, nil)
+}
+
+// teardown tears down the resources of an application.
+func (h *handlers) teardown(w http.ResponseWriter, r *http.Request) {
+	audit := newAuditEntry(r, "teardown")
+
+	req := &superkey.DestroyRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		audit.reject(w, fmt.Errorf("unable to parse the request: %w", err))
+		return
+	}
+
+	audit.tenantId = req.TenantID
+	audit.applicationId = req.ApplicationID
+	audit.guid = req.GUID
+
+	err = validateState(req.TenantID, req.SuperKey, req.ApplicationID, req.GUID, req.StepsCompleted)
+	if err != nil {
+		audit.reject(w, err)
+		return
+	}
+
+	audit.start()
+
+	summary, err := h.actions.TearDown(audit.context(), req)
+	audit.finish(w, err, summary)
+}
+
+// register repeats the registration in Sources of an application whose resources were already forged.
+func (h *handlers) register(w http.ResponseWriter, r *http.Request) {
+	audit := newAuditEntry(r, "register")
+
+	req := &RegisterRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		audit.reject(w, fmt.Errorf("unable to parse the request: %w", err))
+		return
+	}
+
+	audit.tenantId = req.TenantID
+	audit.applicationId = req.ApplicationID
+	audit.guid = req.GUID
+
+	err = validateState(req.TenantID, req.SuperKey, req.ApplicationID, req.GUID, req.StepsCompleted)
+	if err == nil && req.ApplicationID == "" {
+		err = errors.New(`the "application_id" is required to register the resources`)
+	}
+	if err != nil {
+		audit.reject(w, err)
+		return
+	}
+
+	audit.start()
+
+	err = h.actions.Register(audit.context(), req)
+	audit.finish(w, err, nil)
+}
+
+// validateState checks that the request identifies the tenant and the superkey, and that it either carries the state
+// of the resources or the application the state can be fetched from.
+func validateState(tenantId, superKey, applicationId, guid string, stepsCompleted map[string]map[string]string) error {
+	if tenantId == "" || superKey == "" {
+		return errors.New(`the "tenant_id" and the "super_key" are required`)
+	}
+
+	if len(stepsCompleted) == 0 && applicationId == "" {
+		return errors.New(`either the "steps_completed" or the "application_id" to fetch them from are required`)
+	}
+
+	if len(stepsCompleted) != 0 && guid == "" {
+		return errors.New(`the "guid" is required along with the "steps_completed"`)
+	}
+
+	return nil
+}
+
+// newAuditEntry starts the audit trail of the admin action requested with the given request, for the actor the
+// request's identity belongs to.
+func newAuditEntry(r *http.Request, action string) *auditEntry {
+	actor, _ := identityActor(r.Header.Get(identityHeader))
+
+	return &auditEntry{action: action, actor: actor, remoteAddr: r.RemoteAddr, request: r}
+}
+
+// identityActor returns who the given "x-rh-identity" header identifies: the associate's email, the user's username
+// or the certificate's subject.
+func identityActor(header string) (string, error) {
+	if header == "" {
+		return "", fmt.Errorf(`missing "%s" header`, identityHeader)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return "", fmt.Errorf(`unable to decode the "%s" header: %w`, identityHeader, err)
+	}
+
+	xrhid := identity.XRHID{}
+	err = json.Unmarshal(raw, &xrhid)
+	if err != nil {
+		return "", fmt.Errorf(`unable to parse the "%s" header: %w`, identityHeader, err)
+	}
+
+	var actor string
+	switch xrhid.Identity.Type {
+	case "Associate":
+		actor = xrhid.Identity.Associate.Email
+	case "User":
+		actor = xrhid.Identity.User.Username
+	case "X509":
+		actor = xrhid.Identity.X509.SubjectDN
+	}
+
+	if actor == "" {
+		return "", fmt.Errorf(`the "%s" header does not identify an associate, a user or a certificate`, identityHeader)
+	}
+
+	return actor, nil
+}
+
+// logger returns a logger with the audit trail's fields.
+func (a *auditEntry) logger() *logrus.Entry {
+	return l.Log.WithFields(logrus.Fields{
+		"audit":          true,
+		"admin_action":   a.action,
+		"actor":          a.actor,
+		"remote_addr":    a.remoteAddr,
+		"tenant_id":      a.tenantId,
+		"application_id": a.applicationId,
+		"guid":           a.guid,
+	})
+}
+
+// context returns the context the action runs with. The action is not cancelled when the client disconnects, since
+// that would leave the resources halfway through.
+func (a *auditEntry) context() context.Context {
+	ctx := context.WithoutCancel(a.request.Context())
+	ctx = l.WithTenantId(ctx, a.tenantId)
+	ctx = l.WithApplicationId(ctx, a.applicationId)
+
+	return ctx
+}
+
+// start logs that the action was requested.
+func (a *auditEntry) start() {
+	a.logger().Infof(`Admin action "%s" requested`, a.action)
+}
+
+// reject logs and responds that the action was rejected because of an invalid request.
+func (a *auditEntry) reject(w http.ResponseWriter, err error) {
+	a.logger().WithField("outcome", OutcomeRejected).Warnf(`Admin action "%s" rejected: %s`, a.action, err)
+	adminActionsCounter.WithLabelValues(a.action, OutcomeRejected).Inc()
+
+	httpapi.WriteJSON(w, http.StatusBadRequest, ActionResponse{Action: a.action, Outcome: OutcomeRejected, Error: err.Error()})
+}
+
+// finish logs and responds with the outcome of the action.
+func (a *auditEntry) finish(w http.ResponseWriter, err error, teardown *superkey.TeardownSummary) {
+	response := ActionResponse{Action: a.action, Outcome: OutcomeSucceeded, Teardown: teardown}
+	statusCode := http.StatusOK
+
+	if errors.Is(err, ErrSkipped) {
+		response.Outcome = OutcomeSkipped
+		response.Error = err.Error()
+
+		a.logger().WithField("outcome", OutcomeSkipped).Infof(`Admin action "%s" skipped: %s`, a.action, err)
+	} else if err != nil {
+		response.Outcome = OutcomeFailed
+		response.Error = err.Error()
+		statusCode = http.StatusInternalServerError
+
+		a.logger().WithField("outcome", OutcomeFailed).Errorf(`Admin action "%s" failed: %s`, a.action, err)
+	} else {
+		a.logger().WithField("outcome", OutcomeSucceeded).Infof(`Admin action "%s" succeeded`, a.action)
+	}
+
+	adminActionsCounter.WithLabelValues(a.action, response.Outcome).Inc()
+
+	httpapi.WriteJSON(w, statusCode, response)
+}
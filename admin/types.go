@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// Actions are the operations the admin API triggers, which are implemented by the worker.
+type Actions interface {
+	// Create forges the resources of the request and registers them in Sources, as a "create_application" request
+	// would.
+	Create(ctx context.Context, request *superkey.CreateRequest) error
+	// TearDown tears down the resources of the request. When the request does not carry the completed steps, they
+	// are fetched from the application's "_superkey" extra.
+	TearDown(ctx context.Context, request *superkey.DestroyRequest) (*superkey.TeardownSummary, error)
+	// Register repeats the registration in Sources of resources that were already forged. When the request does not
+	// carry the completed steps, they are fetched from the application's "_superkey" extra.
+	Register(ctx context.Context, request *RegisterRequest) error
+}
+
+// RegisterRequest is the body of the registration endpoint: the original create request, along with the state of
+// the resources that were forged for it.
+type RegisterRequest struct {
+	superkey.CreateRequest
+	GUID           string                       `json:"guid"`
+	StepsCompleted map[string]map[string]string `json:"steps_completed"`
+}
+
+// ActionResponse is the response of every admin endpoint.
+type ActionResponse struct {
+	Action   string                    `json:"action"`
+	Outcome  string                    `json:"outcome"`
+	Error    string                    `json:"error,omitempty"`
+	Teardown *superkey.TeardownSummary `json:"teardown,omitempty"`
+}
+
+// handlers holds what the admin endpoints need to serve the requests.
+type handlers struct {
+	actions Actions
+}
+
+// auditEntry is the audit trail of an admin action, which gets logged when the action is requested and when it
+// finishes.
+type auditEntry struct {
+	action        string
+	actor         string
+	remoteAddr    string
+	tenantId      string
+	applicationId string
+	guid          string
+	request       *http.Request
+}
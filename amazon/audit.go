@@ -0,0 +1,181 @@
+package amazon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	cost "github.com/aws/aws-sdk-go-v2/service/costandusagereportservice"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/redhatinsights/sources-superkey-worker/audit"
+)
+
+// auditActionPrefixes are the prefixes of the audited actions for every service, which match the IAM action prefixes.
+var auditActionPrefixes = map[string]string{
+	"s3":          "s3",
+	"iam":         "iam",
+	"cost_report": "cur",
+}
+
+// SetAuditScope sets the organization, the application and the GUID of the resources that the client's mutating calls
+// are recorded for in the audit log.
+func (a *Client) SetAuditScope(orgId, applicationId, guid string) {
+	a.AuditScope.OrgID = orgId
+	a.AuditScope.ApplicationID = applicationId
+	a.AuditScope.GUID = guid
+}
+
+// MarshalLog returns who the client makes the calls for, without its credentials.
+func (a *Client) MarshalLog() map[string]interface{} {
+	if a == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"tenant_id":      a.AuditScope.TenantID,
+		"org_id":         a.AuditScope.OrgID,
+		"application_id": a.AuditScope.ApplicationID,
+		"guid":           a.AuditScope.GUID,
+		"account_id":     a.AuditScope.AccountID,
+	}
+}
+
+// auditMiddleware returns an API option which emits an audit record for every call to the given service that mutates
+// a resource, once the call has finished.
+func auditMiddleware(service string, client *Client) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SuperkeyAudit", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+
+			resource, mutating := mutatedResource(in.Parameters, out.Result, client.AccountID(ctx))
+			if !mutating {
+				return out, metadata, err
+			}
+
+			scope := client.AuditScope
+			record := audit.Record{
+				Timestamp:     time.Now().UTC(),
+				OrgID:         scope.OrgID,
+				TenantID:      scope.TenantID,
+				ApplicationID: scope.ApplicationID,
+				GUID:          scope.GUID,
+				AccountID:     client.AccountID(ctx),
+				Action:        fmt.Sprintf("%s:%s", auditActionPrefixes[service], middleware.GetOperationName(ctx)),
+				Resource:      resource,
+				Result:        audit.ResultSuccess,
+			}
+
+			// The account is not known in advance when the request did not carry it, but the ARNs tell it.
+			if record.AccountID == "" {
+				record.AccountID = arnAccountId(resource)
+			}
+
+			record.RequestID, _ = awsmiddleware.GetRequestIDMetadata(metadata)
+
+			if err != nil {
+				record.Result = audit.ResultFailure
+				record.Error = err.Error()
+
+				var responseErr *awshttp.ResponseError
+				if record.RequestID == "" && errors.As(err, &responseErr) {
+					record.RequestID = responseErr.ServiceRequestID()
+				}
+			}
+
+			audit.Emit(record)
+
+			return out, metadata, err
+		}), middleware.After)
+	}
+}
+
+// mutatedResource returns the ARN of the resource the call with the given input and output mutates, or false when
+// the call does not mutate anything. Policy attachments are recorded against the role.
+func mutatedResource(params, result interface{}, accountId string) (string, bool) {
+	switch p := params.(type) {
+	case *s3.CreateBucketInput:
+		return bucketArn(aws.ToString(p.Bucket)), true
+	case *s3.DeleteBucketInput:
+		return bucketArn(aws.ToString(p.Bucket)), true
+	case *s3.PutBucketPolicyInput:
+		return bucketArn(aws.ToString(p.Bucket)), true
+	case *s3.DeleteBucketPolicyInput:
+		return bucketArn(aws.ToString(p.Bucket)), true
+	case *s3.DeleteObjectInput:
+		return fmt.Sprintf("%s/%s", bucketArn(aws.ToString(p.Bucket)), aws.ToString(p.Key)), true
+
+	case *iam.CreateRoleInput:
+		if out, ok := result.(*iam.CreateRoleOutput); ok && out.Role != nil {
+			return aws.ToString(out.Role.Arn), true
+		}
+
+		return iamArn(accountId, "role", aws.ToString(p.RoleName)), true
+	case *iam.DeleteRoleInput:
+		return iamArn(accountId, "role", aws.ToString(p.RoleName)), true
+	case *iam.UpdateAssumeRolePolicyInput:
+		return iamArn(accountId, "role", aws.ToString(p.RoleName)), true
+	case *iam.AttachRolePolicyInput:
+		return iamArn(accountId, "role", aws.ToString(p.RoleName)), true
+	case *iam.DetachRolePolicyInput:
+		return iamArn(accountId, "role", aws.ToString(p.RoleName)), true
+	case *iam.CreatePolicyInput:
+		if out, ok := result.(*iam.CreatePolicyOutput); ok && out.Policy != nil {
+			return aws.ToString(out.Policy.Arn), true
+		}
+
+		return iamArn(accountId, "policy", aws.ToString(p.PolicyName)), true
+	case *iam.CreatePolicyVersionInput:
+		return aws.ToString(p.PolicyArn), true
+	case *iam.DeletePolicyVersionInput:
+		return aws.ToString(p.PolicyArn), true
+	case *iam.DeletePolicyInput:
+		return aws.ToString(p.PolicyArn), true
+
+	case *cost.PutReportDefinitionInput:
+		if p.ReportDefinition == nil {
+			return reportArn(accountId, ""), true
+		}
+
+		return reportArn(accountId, aws.ToString(p.ReportDefinition.ReportName)), true
+	case *cost.ModifyReportDefinitionInput:
+		return reportArn(accountId, aws.ToString(p.ReportName)), true
+	case *cost.DeleteReportDefinitionInput:
+		return reportArn(accountId, aws.ToString(p.ReportName)), true
+
+	default:
+		return "", false
+	}
+}
+
+// bucketArn returns the ARN of the given S3 bucket.
+func bucketArn(bucket string) string {
+	return fmt.Sprintf("arn:aws:s3:::%s", bucket)
+}
+
+// iamArn returns the ARN of the IAM resource of the given kind and name.
+func iamArn(accountId, kind, name string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:%s/%s", accountId, kind, name)
+}
+
+// reportArn returns the ARN of the given cost and usage report definition.
+func reportArn(accountId, name string) string {
+	return fmt.Sprintf("arn:aws:cur:us-east-1:%s:definition/%s", accountId, name)
+}
+
+// arnAccountId returns the account identifier of the given ARN, which is empty for S3 buckets.
+func arnAccountId(arn string) string {
+	// arn:partition:service:region:account-id:resource
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+
+	return parts[4]
+}
@@ -32,6 +32,32 @@ func (a *Client) CreateCostAndUsageReport(costReport *CostReport) error {
 	return nil
 }
 
+// ModifyCostAndUsageReport - replaces the definition of an existing cost report
+// returns an error if there was a problem
+func (a *Client) ModifyCostAndUsageReport(costReport *CostReport) error {
+	reportDefinition := cost.ModifyReportDefinitionInput{
+		ReportName: &costReport.ReportName,
+		ReportDefinition: &types.ReportDefinition{
+			AdditionalSchemaElements: costReport.AdditionalSchemaElements,
+			Compression:              costReport.Compression,
+			Format:                   costReport.Format,
+			ReportName:               &costReport.ReportName,
+			S3Bucket:                 &costReport.S3Bucket,
+			S3Prefix:                 &costReport.S3Prefix,
+			S3Region:                 costReport.S3Region,
+			TimeUnit:                 costReport.TimeUnit,
+			AdditionalArtifacts:      costReport.AdditionalArtifacts,
+		},
+	}
+
+	_, err := a.CostReporting.ModifyReportDefinition(context.Background(), &reportDefinition)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // DestroyCostAndUsageReport - creates a cost report based on input
 // returns an error if there was a problem
 func (a *Client) DestroyCostAndUsageReport(name string) error {
@@ -44,3 +70,66 @@ func (a *Client) DestroyCostAndUsageReport(name string) error {
 
 	return nil
 }
+
+// DescribeCostAndUsageReport - fetches the definition of the cost report with
+// name
+// returns (the report definition, nil when the report does not exist, error)
+func (a *Client) DescribeCostAndUsageReport(name string) (*CostReport, error) {
+	paginator := cost.NewDescribeReportDefinitionsPaginator(a.CostReporting, &cost.DescribeReportDefinitionsInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, definition := range page.ReportDefinitions {
+			if definition.ReportName == nil || *definition.ReportName != name {
+				continue
+			}
+
+			costReport := &CostReport{
+				AdditionalArtifacts:      definition.AdditionalArtifacts,
+				AdditionalSchemaElements: definition.AdditionalSchemaElements,
+				Compression:              definition.Compression,
+				Format:                   definition.Format,
+				TimeUnit:                 definition.TimeUnit,
+				ReportName:               name,
+				S3Region:                 definition.S3Region,
+			}
+
+			if definition.S3Prefix != nil {
+				costReport.S3Prefix = *definition.S3Prefix
+			}
+
+			if definition.S3Bucket != nil {
+				costReport.S3Bucket = *definition.S3Bucket
+			}
+
+			return costReport, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CostAndUsageReportExists - checks whether the cost report with name exists
+// returns (whether the report exists, error)
+func (a *Client) CostAndUsageReportExists(name string) (bool, error) {
+	paginator := cost.NewDescribeReportDefinitionsPaginator(a.CostReporting, &cost.DescribeReportDefinitionsInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return false, err
+		}
+
+		for _, definition := range page.ReportDefinitions {
+			if definition.ReportName != nil && *definition.ReportName == name {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
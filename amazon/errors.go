@@ -0,0 +1,61 @@
+package amazon
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// The classes the errors returned by AWS are sorted into.
+const (
+	ErrorClassNotFound     = "not_found"
+	ErrorClassThrottled    = "throttled"
+	ErrorClassAccessDenied = "access_denied"
+	ErrorClassConflict     = "conflict"
+	ErrorClassCanceled     = "canceled"
+	ErrorClassUnknown      = "unknown"
+)
+
+// errorCodeClasses maps the error codes returned by the AWS APIs we call to their class.
+var errorCodeClasses = map[string]string{
+	"NoSuchEntity":                    ErrorClassNotFound,
+	"NoSuchBucket":                    ErrorClassNotFound,
+	"NotFound":                        ErrorClassNotFound,
+	"Throttling":                      ErrorClassThrottled,
+	"ThrottlingException":             ErrorClassThrottled,
+	"TooManyRequestsException":        ErrorClassThrottled,
+	"RequestLimitExceeded":            ErrorClassThrottled,
+	"SlowDown":                        ErrorClassThrottled,
+	"AccessDenied":                    ErrorClassAccessDenied,
+	"AccessDeniedException":           ErrorClassAccessDenied,
+	"InvalidClientTokenId":            ErrorClassAccessDenied,
+	"SignatureDoesNotMatch":           ErrorClassAccessDenied,
+	"ExpiredToken":                    ErrorClassAccessDenied,
+	"DeleteConflict":                  ErrorClassConflict,
+	"BucketNotEmpty":                  ErrorClassConflict,
+	"ConcurrentModification":          ErrorClassConflict,
+	"ConcurrentModificationException": ErrorClassConflict,
+	"OperationAborted":                ErrorClassConflict,
+}
+
+// ClassifyError returns the class of the given error returned by AWS.
+func ClassifyError(err error) string {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassCanceled
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if class, ok := errorCodeClasses[apiErr.ErrorCode()]; ok {
+			return class
+		}
+	}
+
+	return ErrorClassUnknown
+}
+
+// IsNotFound returns true when the given error means that the resource does not exist.
+func IsNotFound(err error) bool {
+	return err != nil && ClassifyError(err) == ErrorClassNotFound
+}
@@ -2,6 +2,8 @@ package amazon
 
 import (
 	"context"
+	"errors"
+	"net/url"
 
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 )
@@ -51,6 +53,60 @@ func (a *Client) CreatePolicy(name, payload string) (*string, error) {
 	return out.Policy.Arn, nil
 }
 
+// UpdatePolicy - creates a new default version of the policy (arn) with the
+// given payload, and removes the previous versions so that the policy never
+// reaches IAM's limit of versions per policy.
+// returns: error
+func (a *Client) UpdatePolicy(arn, payload string) error {
+	newVersion, err := a.Iam.CreatePolicyVersion(context.Background(), &iam.CreatePolicyVersionInput{
+		PolicyArn:      &arn,
+		PolicyDocument: &payload,
+		SetAsDefault:   true,
+	})
+	if err != nil {
+		return err
+	}
+
+	versions, err := a.Iam.ListPolicyVersions(context.Background(), &iam.ListPolicyVersionsInput{
+		PolicyArn: &arn,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions.Versions {
+		if version.IsDefaultVersion || *version.VersionId == *newVersion.PolicyVersion.VersionId {
+			continue
+		}
+
+		_, err := a.Iam.DeletePolicyVersion(context.Background(), &iam.DeletePolicyVersionInput{
+			PolicyArn: &arn,
+			VersionId: version.VersionId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateRoleTrustPolicy - replaces the trust policy of the role (name) with
+// the given payload
+// returns: error
+func (a *Client) UpdateRoleTrustPolicy(name, payload string) error {
+	_, err := a.Iam.UpdateAssumeRolePolicy(context.Background(), &iam.UpdateAssumeRolePolicyInput{
+		PolicyDocument: &payload,
+		RoleName:       &name,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // DestroyPolicy - inverse of CreatePolicy, takes an ARN pointing to a Policy
 // and destroys it.
 // returns: error
@@ -95,3 +151,118 @@ func (a *Client) UnBindPolicyToRole(policy, role string) error {
 
 	return nil
 }
+
+// RoleExists - checks whether the role with name exists
+// returns: (whether the role exists, error)
+func (a *Client) RoleExists(name string) (bool, error) {
+	_, err := a.Iam.GetRole(context.Background(), &iam.GetRoleInput{
+		RoleName: &name,
+	})
+
+	return existsFromError(err)
+}
+
+// PolicyArn - returns the ARN of the policy (name), built from the account
+// the credentials belong to, since policy names are unique within an account.
+// returns: (the policy's ARN, error)
+func (a *Client) PolicyArn(ctx context.Context, name string) (string, error) {
+	account := a.AccountID(ctx)
+	if account == "" {
+		return "", errors.New("the AWS account the credentials belong to is unknown")
+	}
+
+	return iamArn(account, "policy", name), nil
+}
+
+// PolicyExists - checks whether the policy (arn) exists
+// returns: (whether the policy exists, error)
+func (a *Client) PolicyExists(arn string) (bool, error) {
+	_, err := a.Iam.GetPolicy(context.Background(), &iam.GetPolicyInput{
+		PolicyArn: &arn,
+	})
+
+	return existsFromError(err)
+}
+
+// PolicyBoundToRole - checks whether the policy (arn) is attached to the role
+// (name). A missing role means that the policy is not attached.
+// returns: (whether the policy is attached, error)
+func (a *Client) PolicyBoundToRole(policy, role string) (bool, error) {
+	paginator := iam.NewListAttachedRolePoliciesPaginator(a.Iam, &iam.ListAttachedRolePoliciesInput{
+		RoleName: &role,
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return existsFromError(err)
+		}
+
+		for _, attached := range page.AttachedPolicies {
+			if attached.PolicyArn != nil && *attached.PolicyArn == policy {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// PolicyDocument - fetches the document of the default version of the policy
+// (arn)
+// returns: (the policy document, error)
+func (a *Client) PolicyDocument(arn string) (string, error) {
+	policy, err := a.Iam.GetPolicy(context.Background(), &iam.GetPolicyInput{
+		PolicyArn: &arn,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	version, err := a.Iam.GetPolicyVersion(context.Background(), &iam.GetPolicyVersionInput{
+		PolicyArn: &arn,
+		VersionId: policy.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if version.PolicyVersion.Document == nil {
+		return "", nil
+	}
+
+	// IAM returns the documents URL encoded.
+	return url.QueryUnescape(*version.PolicyVersion.Document)
+}
+
+// RoleTrustPolicy - fetches the trust policy of the role (name)
+// returns: (the trust policy document, error)
+func (a *Client) RoleTrustPolicy(name string) (string, error) {
+	role, err := a.Iam.GetRole(context.Background(), &iam.GetRoleInput{
+		RoleName: &name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if role.Role.AssumeRolePolicyDocument == nil {
+		return "", nil
+	}
+
+	// IAM returns the documents URL encoded.
+	return url.QueryUnescape(*role.Role.AssumeRolePolicyDocument)
+}
+
+// existsFromError turns the error of an IAM lookup into whether the looked up
+// entity exists.
+func existsFromError(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+
+	if IsNotFound(err) {
+		return false, nil
+	}
+
+	return false, err
+}
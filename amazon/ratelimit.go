@@ -0,0 +1,135 @@
+package amazon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	"golang.org/x/time/rate"
+)
+
+// idleLimiterTTL is the time after which the limiters that have not been used get evicted, so that the limiters map
+// does not grow forever with every tenant and account we ever talked to.
+const idleLimiterTTL = 10 * time.Minute
+
+var (
+	// sharedRateLimiter is the rate limiter shared by every Amazon client, and therefore by every goroutine.
+	sharedRateLimiter = NewRateLimiter(config.Get().AwsRateLimits)
+
+	rateLimitWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sources_superkey_aws_rate_limit_wait_seconds",
+		Help:    "The time spent waiting for the rate limiter before calling an AWS service",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"service"})
+	rateLimitedCallsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_aws_rate_limited_calls",
+		Help: "The number of AWS calls that had to wait because of the tenant's or the account's rate limit",
+	}, []string{"service", "scope"})
+)
+
+// NewRateLimiter returns a rate limiter with the given token bucket settings for each AWS service.
+func NewRateLimiter(limits map[string]config.AwsRateLimit) *RateLimiter {
+	return &RateLimiter{
+		limits:   limits,
+		limiters: make(map[string]*limiterEntry),
+	}
+}
+
+// Wait blocks until both the tenant's and the AWS account's buckets for the given service allow a call to be made,
+// or until the context is done. Empty tenant or account identifiers are not rate limited.
+func (r *RateLimiter) Wait(ctx context.Context, service, tenantId, accountId string) error {
+	start := time.Now()
+	defer func() {
+		rateLimitWaitSeconds.WithLabelValues(service).Observe(time.Since(start).Seconds())
+	}()
+
+	scopes := []struct {
+		name string
+		id   string
+	}{
+		{name: "tenant", id: tenantId},
+		{name: "account", id: accountId},
+	}
+
+	for _, scope := range scopes {
+		if scope.id == "" {
+			continue
+		}
+
+		limiter := r.getLimiter(service, scope.name, scope.id)
+		if limiter == nil {
+			continue
+		}
+
+		reservation := limiter.Reserve()
+		delay := reservation.Delay()
+		if delay == 0 {
+			continue
+		}
+
+		rateLimitedCallsCounter.WithLabelValues(service, scope.name).Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			reservation.Cancel()
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// getLimiter returns the limiter for the given service and scope, creating it if necessary. It returns nil when no
+// limits are configured for the service.
+func (r *RateLimiter) getLimiter(service, scope, id string) *rate.Limiter {
+	limit, ok := r.limits[service]
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastEviction) > idleLimiterTTL {
+		for key, entry := range r.limiters {
+			if now.Sub(entry.lastUsed) > idleLimiterTTL {
+				delete(r.limiters, key)
+			}
+		}
+
+		r.lastEviction = now
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", service, scope, id)
+	entry, ok := r.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.Burst)}
+		r.limiters[key] = entry
+	}
+	entry.lastUsed = now
+
+	return entry.limiter
+}
+
+// rateLimitMiddleware returns an API option which makes every call the client makes to the given service wait for the
+// shared rate limiter before being sent.
+func rateLimitMiddleware(service string, client *Client) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SuperkeyRateLimit", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			err := sharedRateLimiter.Wait(ctx, service, client.AuditScope.TenantID, client.AccountID(ctx))
+			if err != nil {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf(`rate limited call to "%s" canceled: %w`, service, err)
+			}
+
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+	}
+}
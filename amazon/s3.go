@@ -2,8 +2,10 @@ package amazon
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 )
 
 // CreateS3Bucket - Creates an s3 bucket from name and config
@@ -63,3 +65,55 @@ func (a *Client) AttachBucketPolicy(bucket, policy string) error {
 
 	return nil
 }
+
+// DeleteBucketPolicy - removes the policy attached to a bucket
+// returns error
+func (a *Client) DeleteBucketPolicy(bucket string) error {
+	_, err := a.S3.DeleteBucketPolicy(context.Background(), &s3.DeleteBucketPolicyInput{
+		Bucket: &bucket,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BucketPolicy - fetches the policy attached to a bucket
+// returns (the bucket policy, empty when there is none, error)
+func (a *Client) BucketPolicy(bucket string) (string, error) {
+	out, err := a.S3.GetBucketPolicy(context.Background(), &s3.GetBucketPolicyInput{
+		Bucket: &bucket,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucketPolicy" {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if out.Policy == nil {
+		return "", nil
+	}
+
+	return *out.Policy, nil
+}
+
+// S3BucketExists - checks whether the s3 bucket with name exists
+// returns (whether the bucket exists, error)
+func (a *Client) S3BucketExists(name string) (bool, error) {
+	_, err := a.S3.HeadBucket(context.Background(), &s3.HeadBucketInput{
+		Bucket: &name,
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	if IsNotFound(err) {
+		return false, nil
+	}
+
+	return false, err
+}
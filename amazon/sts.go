@@ -0,0 +1,47 @@
+package amazon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// CallerAccountId - fetches the identifier of the AWS account the given
+// credentials belong to, which is the customer's account
+// returns: (account id, error)
+func CallerAccountId(ctx context.Context, creds *aws.Config) (string, error) {
+	out, err := sts.NewFromConfig(*creds).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the caller identity: %w", err)
+	}
+
+	return aws.ToString(out.Account), nil
+}
+
+// AccountID returns the identifier of the customer's AWS account the client's
+// credentials belong to. When the client was not given one, it gets looked up
+// through STS the first time it is needed, rate limited for the tenant. An
+// empty account is returned when the lookup fails, so that the calls are only
+// rate limited for the tenant instead of failing.
+func (a *Client) AccountID(ctx context.Context) string {
+	a.accountOnce.Do(func() {
+		a.accountId = a.AuditScope.AccountID
+		if a.accountId != "" {
+			return
+		}
+
+		err := sharedRateLimiter.Wait(ctx, "sts", a.AuditScope.TenantID, "")
+		if err == nil {
+			a.accountId, err = CallerAccountId(ctx, a.Credentials)
+		}
+
+		if err != nil {
+			l.LogWithContext(ctx).Warnf("Unable to look up the AWS account of the credentials, the calls are only rate limited for the tenant: %s", err)
+		}
+	})
+
+	return a.accountId
+}
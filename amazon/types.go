@@ -2,13 +2,17 @@ package amazon
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	cost "github.com/aws/aws-sdk-go-v2/service/costandusagereportservice"
 	costtypes "github.com/aws/aws-sdk-go-v2/service/costandusagereportservice/types"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/redhatinsights/sources-superkey-worker/config"
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"golang.org/x/time/rate"
 )
 
 var CostS3Policy = `{
@@ -39,39 +43,77 @@ var CostS3Policy = `{
 // Client the amazon client object, holds credentials and API clients for each service necessary
 // which are set when instantiated from the `NewClient` method.
 type Client struct {
-	AccessKey     string
-	SecretKey     string
+	AccessKey     l.Secret
+	SecretKey     l.Secret
 	Credentials   *aws.Config
 	Iam           *iam.Client
 	S3            *s3.Client
 	CostReporting *cost.Client
+	// AuditScope identifies who the mutating calls made with the client are made for, in their audit records.
+	AuditScope AuditScope
+	// accountOnce looks the AWS account up through STS, the first time it is needed, when it was not given.
+	accountOnce sync.Once
+	accountId   string
 }
 
-// NewClient - takes a key+secret and list of API clients to set up
+// AuditScope holds the identifiers of the tenant, the application and the resources that the mutating calls of a
+// client are recorded with in the audit log.
+type AuditScope struct {
+	OrgID         string
+	TenantID      string
+	ApplicationID string
+	GUID          string
+	AccountID     string
+}
+
+// NewClient - takes a key+secret, the tenant and AWS account the calls are
+// rate limited for, and list of API clients to set up. An empty account gets
+// looked up from the credentials' caller identity once a call needs it.
 // returns: new AmazonClient and error
-func NewClient(ctx context.Context, key, sec string, apis ...string) (*Client, error) {
-	a := Client{AccessKey: key, SecretKey: sec}
+func NewClient(ctx context.Context, key, sec, tenantId, accountId string, apis ...string) (*Client, error) {
+	return NewClientForEndpoint(ctx, key, sec, "", tenantId, accountId, apis...)
+}
 
+// NewClientForEndpoint - same as NewClient, but the API clients talk to the
+// given endpoint instead of the AWS ones, e.g. a LocalStack instance. An empty
+// endpoint keeps the AWS ones.
+// returns: new AmazonClient and error
+func NewClientForEndpoint(ctx context.Context, key, sec, endpoint, tenantId, accountId string, apis ...string) (*Client, error) {
 	creds, err := NewAmazonConfig(key, sec)
 	if err != nil {
 		return nil, err
 	}
 
+	if endpoint != "" {
+		creds.BaseEndpoint = aws.String(endpoint)
+	}
+
+	// The calls are rate limited and audited for the customer's account, which the credentials belong to. When it is
+	// not known, it only gets looked up once a call is made, so that building a client does not depend on STS.
+	a := Client{AccessKey: l.Secret(key), SecretKey: l.Secret(sec), AuditScope: AuditScope{TenantID: tenantId, AccountID: accountId}}
 	a.Credentials = creds
 
 	for _, api := range getRequiredApis(apis) {
 		switch api {
 		case "s3":
 			if a.S3 == nil {
-				a.S3 = s3.NewFromConfig(*creds)
+				a.S3 = s3.NewFromConfig(*creds, func(o *s3.Options) {
+					// Custom endpoints don't usually resolve the bucket subdomains.
+					o.UsePathStyle = endpoint != ""
+					o.APIOptions = append(o.APIOptions, rateLimitMiddleware("s3", &a), auditMiddleware("s3", &a))
+				})
 			}
 		case "iam":
 			if a.Iam == nil {
-				a.Iam = iam.NewFromConfig(*creds)
+				a.Iam = iam.NewFromConfig(*creds, func(o *iam.Options) {
+					o.APIOptions = append(o.APIOptions, rateLimitMiddleware("iam", &a), auditMiddleware("iam", &a))
+				})
 			}
 		case "cost_report":
 			if a.CostReporting == nil {
-				a.CostReporting = cost.NewFromConfig(*creds)
+				a.CostReporting = cost.NewFromConfig(*creds, func(o *cost.Options) {
+					o.APIOptions = append(o.APIOptions, rateLimitMiddleware("cost_report", &a), auditMiddleware("cost_report", &a))
+				})
 			}
 		default:
 			l.LogWithContext(ctx).Errorf(`Unsupported "%s" API requested when creating an Amazon client`, api)
@@ -98,6 +140,21 @@ func getRequiredApis(steps []string) []string {
 	return apis
 }
 
+// RateLimiter holds token buckets for every AWS service, tenant and AWS account
+// combination, which are lazily created with the configured limits.
+type RateLimiter struct {
+	mu           sync.Mutex
+	limits       map[string]config.AwsRateLimit
+	limiters     map[string]*limiterEntry
+	lastEviction time.Time
+}
+
+// limiterEntry is a single token bucket along with the last time it was used.
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
 type CostReport struct {
 	AdditionalArtifacts      []costtypes.AdditionalArtifact `json:"additional_artifacts"`
 	AdditionalSchemaElements []costtypes.SchemaElement      `json:"additional_schema_elements"`
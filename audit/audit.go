@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/messaging"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// kafkaSinkBufferSize is the number of audit records the Kafka sink holds while they wait to be published.
+const kafkaSinkBufferSize = 1000
+
+// Errors returned by the Kafka sink when it cannot take a record.
+var (
+	ErrSinkBufferFull = errors.New("the audit records buffer is full")
+	ErrSinkClosed     = errors.New("the audit sink is closed")
+)
+
+// The results of the audited calls.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// sink is where the audit records are written to. The records are dropped when no sink has been configured.
+	sink Sink
+
+	auditRecordsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_audit_records",
+		Help: "The number of audit records of cloud mutations, by action and result",
+	}, []string{"action", "result"})
+	failedAuditRecordsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_failed_audit_records",
+		Help: "The number of audit records that could not be written to the audit sink",
+	})
+)
+
+// SetSink sets the sink every audit record is written to.
+func SetSink(s Sink) {
+	sink = s
+}
+
+// Emit writes the given record to the configured sink. Records that cannot be written are logged along with the
+// error, so that they do not get lost.
+func Emit(record Record) {
+	auditRecordsCounter.WithLabelValues(record.Action, record.Result).Inc()
+
+	if sink == nil {
+		return
+	}
+
+	err := sink.Write(record)
+	if err != nil {
+		failedAuditRecordsCounter.Inc()
+		l.Log.WithField("audit_record", record).Errorf("Unable to write the audit record: %s", err)
+	}
+}
+
+// NewFileSink opens the given file to append the audit records to it, creating it if it does not exist.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the audit log: %w", err)
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+// Write appends the record to the file, and syncs it to disk.
+func (s *FileSink) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(line)
+	if err != nil {
+		return fmt.Errorf("unable to append the audit record: %w", err)
+	}
+
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// NewKafkaSink returns a sink that publishes the audit records with the given writer. The records are buffered and
+// published in the background, so that the audited calls do not wait for Kafka.
+func NewKafkaSink(writer *kafkago.Writer) *KafkaSink {
+	s := &KafkaSink{
+		writer:  writer,
+		records: make(chan Record, kafkaSinkBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	go s.publish()
+
+	return s
+}
+
+// Write queues the record to be published as a "cloud_mutation" event. The record is rejected when the buffer is
+// full, so that a slow Kafka never holds the audited calls back.
+func (s *KafkaSink) Write(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSinkClosed
+	}
+
+	select {
+	case s.records <- record:
+		return nil
+	default:
+		return ErrSinkBufferFull
+	}
+}
+
+// Close publishes the buffered records, and stops the sink. The writer is not closed, since it is owned by the
+// caller.
+func (s *KafkaSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.records)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+
+	return nil
+}
+
+// publish publishes the queued records, keyed by the GUID of the resources, until the sink gets closed. Records that
+// cannot be published are logged, so that they do not get lost.
+func (s *KafkaSink) publish() {
+	defer close(s.done)
+
+	for record := range s.records {
+		err := messaging.Publish(context.Background(), s.writer, record.GUID, "cloud_mutation", record)
+		if err != nil {
+			failedAuditRecordsCounter.Inc()
+			l.Log.WithField("audit_record", record).Errorf("Unable to publish the audit record: %s", err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Record is the audit record of a call that mutated a resource in a customer's cloud account.
+type Record struct {
+	Timestamp     time.Time `json:"timestamp"`
+	OrgID         string    `json:"org_id"`
+	TenantID      string    `json:"tenant_id"`
+	ApplicationID string    `json:"application_id"`
+	GUID          string    `json:"guid"`
+	AccountID     string    `json:"account_id"`
+	Action        string    `json:"action"`
+	Resource      string    `json:"resource"`
+	RequestID     string    `json:"request_id"`
+	Result        string    `json:"result"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Sink is where the audit records get written to, separately from the logs.
+type Sink interface {
+	// Write durably stores the given record.
+	Write(record Record) error
+	// Close releases the resources held by the sink.
+	Close() error
+}
+
+// FileSink appends the audit records to a JSONL file, one record per line.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// KafkaSink publishes the audit records to a Kafka topic from a background goroutine.
+type KafkaSink struct {
+	writer  *kafkago.Writer
+	records chan Record
+	done    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
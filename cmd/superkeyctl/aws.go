@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// credentialFlags registers the flags of the credentials the resources are forged or torn down with.
+func credentialFlags(fs *flag.FlagSet) *provider.Credentials {
+	creds := &provider.Credentials{}
+
+	fs.StringVar(&creds.AccessKey, "access-key", os.Getenv("AWS_ACCESS_KEY_ID"), `the access key, defaults to "AWS_ACCESS_KEY_ID"`)
+	fs.StringVar(&creds.SecretKey, "secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), `the secret key, defaults to "AWS_SECRET_ACCESS_KEY"`)
+	fs.StringVar(&creds.Endpoint, "endpoint", "", "the endpoint the provider's APIs are reached at, e.g. a LocalStack instance. Defaults to the provider's own")
+
+	return creds
+}
+
+// forge forges the resources of a create request, and prints the destroy request that tears them down.
+func forge(args []string) error {
+	fs := flag.NewFlagSet("forge", flag.ExitOnError)
+	creds := credentialFlags(fs)
+	rollback := fs.Bool("rollback", true, "tear down the resources that were created when the forge fails")
+	options := createRequestFlags(fs)
+	_ = fs.Parse(args)
+
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return errors.New("the access key and the secret key are required")
+	}
+
+	req, err := prepareCreateRequest(fs, options)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	f, forgeErr := provider.ForgeWithCredentials(ctx, req, *creds)
+	if forgeErr != nil && f != nil && *rollback {
+		report, err := provider.TearDownWithCredentials(ctx, f, *creds)
+		if err != nil {
+			return fmt.Errorf("%w, and the created resources could not be torn down: %w", forgeErr, err)
+		}
+
+		return fmt.Errorf("%w, created resources torn down: %s", forgeErr, report.Summary())
+	}
+
+	// Print the destroy request even when the forge failed, so that whatever was created can be torn down later on.
+	if f != nil {
+		err = printJSON(destroyRequestFor(f))
+		if err != nil {
+			return err
+		}
+	}
+
+	return forgeErr
+}
+
+// teardown tears down the resources of a destroy request, and prints the results.
+func teardown(args []string) error {
+	fs := flag.NewFlagSet("teardown", flag.ExitOnError)
+	creds := credentialFlags(fs)
+	_ = fs.Parse(args)
+
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return errors.New("the access key and the secret key are required")
+	}
+
+	req := &superkey.DestroyRequest{}
+	raw, err := readRequest(fs, req)
+	if err != nil {
+		return err
+	}
+
+	err = superkey.ValidateRequest(superkey.DefaultSchemaVersion, "destroy_application", raw)
+	if err != nil {
+		return err
+	}
+
+	f := superkey.ReconstructForgedApplication(req)
+
+	report, err := provider.TearDownWithCredentials(context.Background(), f, *creds)
+	if err != nil {
+		return err
+	}
+
+	err = printJSON(f.TeardownSummary(report))
+	if err != nil {
+		return err
+	}
+
+	return report.Err()
+}
+
+// destroyRequestFor returns the destroy request that tears down the resources of the forged application.
+func destroyRequestFor(f *superkey.ForgedApplication) *superkey.DestroyRequest {
+	return &superkey.DestroyRequest{
+		TenantID:       f.Request.TenantID,
+		SourceID:       f.Request.SourceID,
+		ApplicationID:  f.Request.ApplicationID,
+		SuperKey:       f.Request.SuperKey,
+		GUID:           f.GUID,
+		Provider:       f.Request.Provider,
+		StepsCompleted: f.StepsCompleted,
+		SuperKeySteps:  f.Request.SuperKeySteps,
+	}
+}
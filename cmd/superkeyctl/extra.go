@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// extra decodes the "_superkey" state of an application, either from a given extra or fetched from Sources.
+func extra(args []string) error {
+	fs := flag.NewFlagSet("extra", flag.ExitOnError)
+	applicationId := fs.String("application-id", "", `fetch the extra of the given application from Sources, configured through the "SOURCES_*" env vars, instead of reading it`)
+	identity := fs.String("identity", "", `the "x-rh-identity" header to fetch the application with`)
+	orgId := fs.String("org-id", "", `the "x-rh-sources-org-id" header to fetch the application with`)
+	_ = fs.Parse(args)
+
+	if *applicationId != "" {
+		state, err := superkey.FetchSuperKeyState(context.Background(), *identity, *orgId, *applicationId)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(state)
+	}
+
+	raw, err := readInput(fs)
+	if err != nil {
+		return err
+	}
+
+	state, err := superkey.ParseSuperKeyExtra(raw)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(state)
+}
@@ -0,0 +1,100 @@
+// Command superkeyctl validates, plans, forges and tears down superkey requests outside of the worker, inspects the
+// "_superkey" state stored in the applications and produces requests to Kafka.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// command is a superkeyctl subcommand.
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{name: "validate", description: "validate a request against its JSON schema", run: validate},
+	{name: "plan", description: "show the resources a create request would forge, with their substituted payloads", run: plan},
+	{name: "forge", description: "forge the resources of a create request with explicit credentials", run: forge},
+	{name: "teardown", description: "tear down the resources of a destroy request with explicit credentials", run: teardown},
+	{name: "extra", description: `decode and inspect an application's "_superkey" extra`, run: extra},
+	{name: "produce", description: "produce a create, update or destroy request to Kafka, with the headers the worker expects", run: produce},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// The logs go to the standard error, so that the output of the commands can be piped.
+	l.InitLogger(config.Get())
+	l.Log.Out = os.Stderr
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+
+		err := cmd.run(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "superkeyctl %s: %s\n", cmd.name, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the available subcommands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: superkeyctl <command> [flags] [file]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nThe requests are read from the given file, or from the standard input when no file or \"-\" is given.\nRun \"superkeyctl <command> -h\" for the flags of each command.\n")
+}
+
+// readInput reads the file given as the flag set's first argument, or the standard input when there is none.
+func readInput(fs *flag.FlagSet) ([]byte, error) {
+	path := fs.Arg(0)
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+// readRequest reads and parses the request given as the flag set's first argument.
+func readRequest(fs *flag.FlagSet, request interface{}) ([]byte, error) {
+	raw, err := readInput(fs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the request: %w", err)
+	}
+
+	err = json.Unmarshal(raw, request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the request: %w", err)
+	}
+
+	return raw, nil
+}
+
+// printJSON prints the given value as indented JSON to the standard output.
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
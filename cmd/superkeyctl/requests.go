@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	"github.com/segmentio/kafka-go"
+)
+
+// validate validates a request against the JSON schema of its event type.
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	eventType := fs.String("event-type", "create_application", "the event type of the request")
+	schemaVersion := fs.String("schema-version", superkey.DefaultSchemaVersion, "the schema version to validate the request against")
+	_ = fs.Parse(args)
+
+	raw, err := readInput(fs)
+	if err != nil {
+		return fmt.Errorf("unable to read the request: %w", err)
+	}
+
+	err = superkey.ValidateRequest(*schemaVersion, *eventType, raw)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The \"%s\" request conforms to the schema version \"%s\"\n", *eventType, *schemaVersion)
+
+	return nil
+}
+
+// createRequestOptions are the flags of the commands that act on create requests.
+type createRequestOptions struct {
+	schemaVersion *string
+	identity      *string
+	orgId         *string
+	localSteps    *bool
+}
+
+// createRequestFlags registers the flags of the commands that act on create requests.
+func createRequestFlags(fs *flag.FlagSet) *createRequestOptions {
+	return &createRequestOptions{
+		schemaVersion: fs.String("schema-version", superkey.DefaultSchemaVersion, "the schema version to validate the request against"),
+		identity:      fs.String("identity", "", `the "x-rh-identity" header to fetch the application type's superkey metadata with`),
+		orgId:         fs.String("org-id", "", `the "x-rh-sources-org-id" header to fetch the application type's superkey metadata with`),
+		localSteps:    fs.Bool("local-steps", false, `use the superkey steps embedded in the request instead of the ones of the application type's superkey metadata, fetched from Sources through the "SOURCES_*" env vars`),
+	}
+}
+
+// prepareCreateRequest reads and validates a create request, and resolves its superkey steps like the worker does.
+func prepareCreateRequest(fs *flag.FlagSet, options *createRequestOptions) (*superkey.CreateRequest, error) {
+	req := &superkey.CreateRequest{}
+	raw, err := readRequest(fs, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = superkey.ValidateRequest(*options.schemaVersion, "create_application", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if *options.localSteps {
+		return req, nil
+	}
+
+	req.IdentityHeader = *options.identity
+	req.OrgIdHeader = *options.orgId
+
+	err = req.ResolveSteps(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// plan prints the resources that forging a create request would create, along with their substituted payloads.
+func plan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the plan as JSON")
+	options := createRequestFlags(fs)
+	_ = fs.Parse(args)
+
+	req, err := prepareCreateRequest(fs, options)
+	if err != nil {
+		return err
+	}
+
+	steps, err := provider.Plan(req)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(steps)
+	}
+
+	for _, step := range steps {
+		fmt.Printf("%s: %s\n", step.Step, step.Resource)
+
+		if step.Payload == "" {
+			continue
+		}
+
+		// The payloads are usually JSON documents, which are easier to review indented.
+		payload := bytes.Buffer{}
+		if json.Indent(&payload, []byte(step.Payload), "    ", "  ") != nil {
+			payload.Reset()
+			payload.WriteString(step.Payload)
+		}
+		fmt.Printf("    %s\n", payload.String())
+	}
+
+	return nil
+}
+
+// produce produces a request to Kafka, along with the headers the worker expects.
+func produce(args []string) error {
+	fs := flag.NewFlagSet("produce", flag.ExitOnError)
+	brokers := fs.String("brokers", "localhost:9092", "comma separated list of the Kafka brokers")
+	topic := fs.String("topic", "platform.sources.superkey-requests", "the topic to produce the request to")
+	eventType := fs.String("event-type", "create_application", `the event type of the request: "create_application", "update_application" or "destroy_application"`)
+	schemaVersion := fs.String("schema-version", superkey.DefaultSchemaVersion, "the schema version of the request")
+	identity := fs.String("identity", "", `the "x-rh-identity" header of the request`)
+	orgId := fs.String("org-id", "", `the "x-rh-sources-org-id" header of the request`)
+	key := fs.String("key", "", "the key of the message")
+	_ = fs.Parse(args)
+
+	if *identity == "" && *orgId == "" {
+		return errors.New(`either the "-identity" or the "-org-id" are required, otherwise the worker skips the request`)
+	}
+
+	raw, err := readInput(fs)
+	if err != nil {
+		return fmt.Errorf("unable to read the request: %w", err)
+	}
+
+	// Catch the invalid requests before the worker does.
+	err = superkey.ValidateRequest(*schemaVersion, *eventType, raw)
+	if err != nil {
+		return err
+	}
+
+	w := &kafka.Writer{
+		Addr:  kafka.TCP(strings.Split(*brokers, ",")...),
+		Topic: *topic,
+	}
+	defer w.Close()
+
+	msg := kafka.Message{
+		Value: raw,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(*eventType)},
+			{Key: "schema_version", Value: []byte(*schemaVersion)},
+			{Key: "x-rh-identity", Value: []byte(*identity)},
+			{Key: "x-rh-sources-org-id", Value: []byte(*orgId)},
+		},
+	}
+	if *key != "" {
+		msg.Key = []byte(*key)
+	}
+
+	err = w.WriteMessages(context.Background(), msg)
+	if err != nil {
+		return fmt.Errorf(`unable to produce the request to "%s": %w`, *topic, err)
+	}
+
+	fmt.Printf("Produced the \"%s\" request to \"%s\"\n", *eventType, *topic)
+
+	return nil
+}
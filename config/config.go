@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	clowder "github.com/redhatinsights/app-common-go/pkg/api/v1"
 	"github.com/spf13/viper"
@@ -27,6 +29,57 @@ type SuperKeyWorkerConfig struct {
 	SourcesPort                int
 	SourcesPSK                 string
 	SourcesRequestsMaxAttempts int
+	SourcesRequestTimeout      time.Duration
+	SourcesRetryBaseDelay      time.Duration
+	SourcesRetryMaxDelay       time.Duration
+	SourcesBreakerThreshold    int
+	SourcesBreakerOpenDuration time.Duration
+	SourcesCAPath              string
+	SourcesClientCertPath      string
+	SourcesClientKeyPath       string
+	SourcesProxyURL            string
+	SourcesMaxIdleConns        int
+	SourcesIdleConnTimeout     time.Duration
+	SourcesBulkCreate          bool
+	SourcesStatusTopic         string
+	ProcessedMessagesTTL       time.Duration
+	ProcessedMessagesPath      string
+	AwsRateLimits              map[string]AwsRateLimit
+	KafkaLanes                 []KafkaLane
+	JournalPath                string
+	JournalRetention           time.Duration
+	TeardownQueuePath          string
+	PendingExtrasPath          string
+	TeardownRetryBaseDelay     time.Duration
+	TeardownRetryMaxDelay      time.Duration
+	TeardownRetryMaxAge        time.Duration
+	TeardownEventsTopic        string
+	StatusApiPSK               string
+	StatusHistorySize          int
+	AdminApiPSK                string
+	AdminApiPort               int
+	ReplayFile                 string
+	AwsEndpoint                string
+	AuditLogPath               string
+	AuditTopic                 string
+	ReportProgress             bool
+	SuperKeyMetadataTTL        time.Duration
+}
+
+// KafkaLane represents an input topic the superkey requests are consumed from. Every lane gets its own consumer with
+// the given number of concurrent workers, and the lanes with a higher priority take precedence over the lower ones
+// when both have requests to process. The topic gets resolved through the Clowder topic mappings.
+type KafkaLane struct {
+	Topic       string `json:"topic"`
+	Priority    int    `json:"priority"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// AwsRateLimit holds the token bucket settings used to rate limit the calls made to an AWS service. Every tenant and
+// every AWS account gets its own bucket with these settings.
+type AwsRateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
 }
 
 // Get - returns the config parsed from runtime vars
@@ -47,6 +100,11 @@ func Get() *SuperKeyWorkerConfig {
 		options.SetDefault("LogGroup", cfg.Logging.Cloudwatch.LogGroup)
 		options.SetDefault("MetricsPort", cfg.MetricsPort)
 
+		// The admin API is only reachable from inside the cluster, through Clowder's private port.
+		if cfg.PrivatePort != nil {
+			options.SetDefault("AdminApiPort", *cfg.PrivatePort)
+		}
+
 	} else {
 		options.SetDefault("AwsRegion", "us-east-1")
 		options.SetDefault("AwsAccessKeyId", os.Getenv("CW_AWS_ACCESS_KEY_ID"))
@@ -96,6 +154,198 @@ func Get() *SuperKeyWorkerConfig {
 
 	options.SetDefault("SourcesRequestsMaxAttempts", sourcesRequestsMaxAttempts)
 
+	// Get how long each attempt of the requests sent to Sources can take, and the delays the failed attempts are
+	// retried after, which grow exponentially from the base delay up to the max delay.
+	options.SetDefault("SourcesRequestTimeout", getDuration("SOURCES_REQUEST_TIMEOUT", 10*time.Second))
+	options.SetDefault("SourcesRetryBaseDelay", getDuration("SOURCES_RETRY_BASE_DELAY", time.Second))
+	options.SetDefault("SourcesRetryMaxDelay", getDuration("SOURCES_RETRY_MAX_DELAY", 30*time.Second))
+
+	// Get after how many consecutive failures the circuit breaker around Sources opens, and for how long it stays open
+	// before a trial request is let through.
+	sourcesBreakerThreshold := 5
+	if raw := os.Getenv("SOURCES_BREAKER_THRESHOLD"); raw != "" {
+		threshold, err := strconv.Atoi(raw)
+		if err != nil || threshold < 1 {
+			log.Printf(`Warning: the provided circuit breaker threshold \"%s\" is not a positive integer. Setting default value of 5.`, raw)
+		} else {
+			sourcesBreakerThreshold = threshold
+		}
+	}
+
+	options.SetDefault("SourcesBreakerThreshold", sourcesBreakerThreshold)
+	options.SetDefault("SourcesBreakerOpenDuration", getDuration("SOURCES_BREAKER_OPEN_DURATION", 30*time.Second))
+
+	// Get the TLS settings for talking to Sources: the CA bundle the server certificate is verified with, which
+	// defaults to the one Clowder provides, and the client certificate for mutual TLS.
+	sourcesCAPath := os.Getenv("SOURCES_CA_PATH")
+	if sourcesCAPath == "" && clowder.IsClowderEnabled() && clowder.LoadedConfig.TlsCAPath != nil {
+		sourcesCAPath = *clowder.LoadedConfig.TlsCAPath
+	}
+
+	options.SetDefault("SourcesCAPath", sourcesCAPath)
+	options.SetDefault("SourcesClientCertPath", os.Getenv("SOURCES_CLIENT_CERT_PATH"))
+	options.SetDefault("SourcesClientKeyPath", os.Getenv("SOURCES_CLIENT_KEY_PATH"))
+	options.SetDefault("SourcesProxyURL", os.Getenv("SOURCES_PROXY_URL"))
+
+	// Get how many idle connections to Sources are kept around for reuse, and for how long.
+	sourcesMaxIdleConns := 20
+	if raw := os.Getenv("SOURCES_MAX_IDLE_CONNS"); raw != "" {
+		conns, err := strconv.Atoi(raw)
+		if err != nil || conns < 1 {
+			log.Printf(`Warning: the provided max idle connections \"%s\" is not a positive integer. Setting default value of 20.`, raw)
+		} else {
+			sourcesMaxIdleConns = conns
+		}
+	}
+
+	options.SetDefault("SourcesMaxIdleConns", sourcesMaxIdleConns)
+	options.SetDefault("SourcesIdleConnTimeout", getDuration("SOURCES_IDLE_CONN_TIMEOUT", 90*time.Second))
+
+	// Get whether the authentications get registered in Sources through the bulk create endpoint, which creates them
+	// along with their link to the application in a single transaction, or through separate calls. It is opt-in until
+	// the bulk create payload has been verified against Sources.
+	options.SetDefault("SourcesBulkCreate", os.Getenv("SOURCES_BULK_CREATE") == "true")
+
+	// Get the Sources topic the availability status updates get published to instead of being sent through the REST
+	// API, which is only used as a fallback then. The updates are only sent through the REST API when not given.
+	options.SetDefault("SourcesStatusTopic", os.Getenv("SOURCES_STATUS_TOPIC"))
+
+	// Get for how long we want to remember the messages we processed, so that redeliveries can be skipped.
+	processedMessagesTTL := time.Hour
+	if raw := os.Getenv("PROCESSED_MESSAGES_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			log.Printf(`Warning: the provided processed messages TTL \"%s\" is not a valid positive duration. Setting default value of 1h.`, raw)
+		} else {
+			processedMessagesTTL = ttl
+		}
+	}
+
+	options.SetDefault("ProcessedMessagesTTL", processedMessagesTTL)
+
+	// Get the file the processed messages are stored in, so that they are still recognized after a restart. They are
+	// only remembered in memory when empty.
+	options.SetDefault("ProcessedMessagesPath", os.Getenv("PROCESSED_MESSAGES_PATH"))
+
+	// Get the rate limits for the calls we make to each AWS service.
+	awsRateLimits := map[string]AwsRateLimit{
+		"iam":         getAwsRateLimit("IAM", 5, 5),
+		"s3":          getAwsRateLimit("S3", 20, 20),
+		"cost_report": getAwsRateLimit("COST_REPORT", 1, 2),
+		"sts":         getAwsRateLimit("STS", 5, 5),
+	}
+
+	// Get the lanes the superkey requests are consumed from. By default, there is a single lane for the superkey
+	// requests topic.
+	kafkaLanes := []KafkaLane{{Topic: "platform.sources.superkey-requests", Priority: 0, Concurrency: 1}}
+	if raw := os.Getenv("SUPERKEY_REQUEST_LANES"); raw != "" {
+		var lanes []KafkaLane
+		err := json.Unmarshal([]byte(raw), &lanes)
+		if err != nil || len(lanes) == 0 {
+			log.Printf(`Warning: the provided request lanes \"%s\" are not a valid JSON list of lanes. Using the default superkey requests lane.`, raw)
+		} else {
+			for i := range lanes {
+				if lanes[i].Concurrency < 1 {
+					log.Printf(`Warning: the concurrency of the lane for the topic \"%s\" is lower than 1. Setting default value of 1.`, lanes[i].Topic)
+					lanes[i].Concurrency = 1
+				}
+			}
+
+			kafkaLanes = lanes
+		}
+	}
+
+	// Get where the operation journal lives, and for how long the finished operations are kept in it. The journal is
+	// disabled when no path is given.
+	options.SetDefault("JournalPath", os.Getenv("OPERATION_JOURNAL_PATH"))
+
+	journalRetention := 24 * time.Hour
+	if raw := os.Getenv("OPERATION_JOURNAL_RETENTION"); raw != "" {
+		retention, err := time.ParseDuration(raw)
+		if err != nil || retention <= 0 {
+			log.Printf(`Warning: the provided journal retention \"%s\" is not a valid positive duration. Setting default value of 24h.`, raw)
+		} else {
+			journalRetention = retention
+		}
+	}
+
+	options.SetDefault("JournalRetention", journalRetention)
+
+	// Get where the failed teardowns are queued for retrying, and how they are retried. The retries are disabled when
+	// no path is given.
+	options.SetDefault("TeardownQueuePath", os.Getenv("TEARDOWN_RETRY_QUEUE_PATH"))
+	options.SetDefault("TeardownRetryBaseDelay", getDuration("TEARDOWN_RETRY_BASE_DELAY", time.Minute))
+	options.SetDefault("TeardownRetryMaxDelay", getDuration("TEARDOWN_RETRY_MAX_DELAY", time.Hour))
+	options.SetDefault("TeardownRetryMaxAge", getDuration("TEARDOWN_RETRY_MAX_AGE", 72*time.Hour))
+
+	// Get the file the application extras that could not be stored in Sources are kept in, so that they still get
+	// sent after a restart. They are only kept in memory when empty.
+	options.SetDefault("PendingExtrasPath", os.Getenv("PENDING_EXTRAS_PATH"))
+
+	// Get the topic the teardown results are published to when the application no longer exists in Sources. The
+	// results are only logged when no topic is given.
+	options.SetDefault("TeardownEventsTopic", os.Getenv("SUPERKEY_TEARDOWN_EVENTS_TOPIC"))
+
+	// Get the PSK the operation status API is protected with, and how many finished operations it remembers. The API
+	// is disabled when no PSK is given.
+	options.SetDefault("StatusApiPSK", os.Getenv("STATUS_API_PSK"))
+
+	statusHistorySize := 500
+	if raw := os.Getenv("STATUS_HISTORY_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			log.Printf(`Warning: the provided status history size \"%s\" is not a positive integer. Setting default value of 500.`, raw)
+		} else {
+			statusHistorySize = size
+		}
+	}
+
+	options.SetDefault("StatusHistorySize", statusHistorySize)
+
+	// Get for how long the superkey metadata of the application types is cached before being fetched again.
+	options.SetDefault("SuperKeyMetadataTTL", getDuration("SUPERKEY_METADATA_TTL", 5*time.Minute))
+
+	// Get whether the progress of the create operations gets reported to their applications in Sources. It is off by
+	// default, since it changes the availability status the applications go through.
+	options.SetDefault("ReportProgress", os.Getenv("SUPERKEY_REPORT_PROGRESS") == "true")
+
+	// Get the PSK the admin API is protected with. The API is disabled when no PSK is given.
+	options.SetDefault("AdminApiPSK", os.Getenv("ADMIN_API_PSK"))
+
+	// Get the port the admin API is served on, apart from the metrics, when Clowder does not provide a private port.
+	if !options.IsSet("AdminApiPort") {
+		adminApiPort := 10000
+		if raw := os.Getenv("ADMIN_API_PORT"); raw != "" {
+			port, err := strconv.Atoi(raw)
+			if err != nil {
+				log.Printf(`Warning: the provided admin API port \"%s\" is not an integer. Setting default value of 10000.`, raw)
+			} else {
+				adminApiPort = port
+			}
+		}
+
+		options.SetDefault("AdminApiPort", adminApiPort)
+	}
+
+	// Get the file to replay the messages from instead of consuming them from Kafka, "-" being the standard input,
+	// and the endpoint of the AWS APIs to use instead of the real ones. Both are meant to reproduce issues locally.
+	options.SetDefault("ReplayFile", os.Getenv("SUPERKEY_REPLAY_FILE"))
+
+	// The AWS endpoint is only honoured when replaying or running outside of Clowder, so that a stray value can never
+	// send the customers' credentials somewhere else in a deployed environment.
+	awsEndpoint := os.Getenv("SUPERKEY_AWS_ENDPOINT")
+	if awsEndpoint != "" && os.Getenv("SUPERKEY_REPLAY_FILE") == "" && clowder.IsClowderEnabled() {
+		log.Printf(`Warning: the provided AWS endpoint \"%s\" is only used when replaying messages or running outside of Clowder. Using the AWS endpoints instead.`, awsEndpoint)
+		awsEndpoint = ""
+	}
+
+	options.SetDefault("AwsEndpoint", awsEndpoint)
+
+	// Get where the audit records of the calls that mutate the customers' cloud resources get written to: either a
+	// Kafka topic, which takes precedence, or a JSONL file. The records are not kept anywhere when neither is given.
+	options.SetDefault("AuditLogPath", os.Getenv("AUDIT_LOG_PATH"))
+	options.SetDefault("AuditTopic", os.Getenv("AUDIT_LOG_TOPIC"))
+
 	hostname, _ := os.Hostname()
 	options.SetDefault("Hostname", hostname)
 
@@ -125,7 +375,87 @@ func Get() *SuperKeyWorkerConfig {
 		SourcesPort:                options.GetInt("SourcesPort"),
 		SourcesPSK:                 options.GetString("SourcesPSK"),
 		SourcesRequestsMaxAttempts: options.GetInt("SourcesRequestsMaxAttempts"),
+		SourcesRequestTimeout:      options.GetDuration("SourcesRequestTimeout"),
+		SourcesRetryBaseDelay:      options.GetDuration("SourcesRetryBaseDelay"),
+		SourcesRetryMaxDelay:       options.GetDuration("SourcesRetryMaxDelay"),
+		SourcesBreakerThreshold:    options.GetInt("SourcesBreakerThreshold"),
+		SourcesBreakerOpenDuration: options.GetDuration("SourcesBreakerOpenDuration"),
+		SourcesCAPath:              options.GetString("SourcesCAPath"),
+		SourcesClientCertPath:      options.GetString("SourcesClientCertPath"),
+		SourcesClientKeyPath:       options.GetString("SourcesClientKeyPath"),
+		SourcesProxyURL:            options.GetString("SourcesProxyURL"),
+		SourcesMaxIdleConns:        options.GetInt("SourcesMaxIdleConns"),
+		SourcesIdleConnTimeout:     options.GetDuration("SourcesIdleConnTimeout"),
+		SourcesBulkCreate:          options.GetBool("SourcesBulkCreate"),
+		SourcesStatusTopic:         options.GetString("SourcesStatusTopic"),
+		ProcessedMessagesTTL:       options.GetDuration("ProcessedMessagesTTL"),
+		ProcessedMessagesPath:      options.GetString("ProcessedMessagesPath"),
+		AwsRateLimits:              awsRateLimits,
+		KafkaLanes:                 kafkaLanes,
+		JournalPath:                options.GetString("JournalPath"),
+		JournalRetention:           options.GetDuration("JournalRetention"),
+		TeardownQueuePath:          options.GetString("TeardownQueuePath"),
+		PendingExtrasPath:          options.GetString("PendingExtrasPath"),
+		TeardownRetryBaseDelay:     options.GetDuration("TeardownRetryBaseDelay"),
+		TeardownRetryMaxDelay:      options.GetDuration("TeardownRetryMaxDelay"),
+		TeardownRetryMaxAge:        options.GetDuration("TeardownRetryMaxAge"),
+		TeardownEventsTopic:        options.GetString("TeardownEventsTopic"),
+		StatusApiPSK:               options.GetString("StatusApiPSK"),
+		StatusHistorySize:          options.GetInt("StatusHistorySize"),
+		AdminApiPSK:                options.GetString("AdminApiPSK"),
+		AdminApiPort:               options.GetInt("AdminApiPort"),
+		ReplayFile:                 options.GetString("ReplayFile"),
+		AwsEndpoint:                options.GetString("AwsEndpoint"),
+		AuditLogPath:               options.GetString("AuditLogPath"),
+		AuditTopic:                 options.GetString("AuditTopic"),
+		ReportProgress:             options.GetBool("ReportProgress"),
+		SuperKeyMetadataTTL:        options.GetDuration("SuperKeyMetadataTTL"),
+	}
+}
+
+// getAwsRateLimit reads the "AWS_<SERVICE>_RATE_LIMIT" and "AWS_<SERVICE>_RATE_BURST" env vars, falling back to the
+// given defaults when they are not set or are not valid.
+func getAwsRateLimit(service string, defaultRequestsPerSecond float64, defaultBurst int) AwsRateLimit {
+	rateLimit := AwsRateLimit{RequestsPerSecond: defaultRequestsPerSecond, Burst: defaultBurst}
+
+	rateVar := "AWS_" + service + "_RATE_LIMIT"
+	if raw := os.Getenv(rateVar); raw != "" {
+		requestsPerSecond, err := strconv.ParseFloat(raw, 64)
+		if err != nil || requestsPerSecond <= 0 {
+			log.Printf(`Warning: the provided "%s" value \"%s\" is not a positive number. Setting default value of %v.`, rateVar, raw, defaultRequestsPerSecond)
+		} else {
+			rateLimit.RequestsPerSecond = requestsPerSecond
+		}
+	}
+
+	burstVar := "AWS_" + service + "_RATE_BURST"
+	if raw := os.Getenv(burstVar); raw != "" {
+		burst, err := strconv.Atoi(raw)
+		if err != nil || burst < 1 {
+			log.Printf(`Warning: the provided "%s" value \"%s\" is not a positive integer. Setting default value of %d.`, burstVar, raw, defaultBurst)
+		} else {
+			rateLimit.Burst = burst
+		}
 	}
+
+	return rateLimit
+}
+
+// getDuration reads the given env var as a duration, falling back to the given default when it is not set or is not a
+// valid positive duration.
+func getDuration(envVar string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration <= 0 {
+		log.Printf(`Warning: the provided "%s" value \"%s\" is not a valid positive duration. Setting default value of %s.`, envVar, raw, defaultValue)
+		return defaultValue
+	}
+
+	return duration
 }
 
 func (s *SuperKeyWorkerConfig) KafkaTopic(topic string) string {
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/RedHatInsights/sources-api-go/kafka"
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
 	"github.com/redhatinsights/sources-superkey-worker/sources"
 	kafkago "github.com/segmentio/kafka-go"
@@ -23,49 +22,55 @@ const (
 // Health state tracking
 type healthTracker struct {
 	mu                sync.RWMutex
-	reader            *kafka.Reader
-	brokerAddr        string // Store broker address for offset queries
-	topic             string // Store topic name
+	brokerAddr        string   // Store broker address for offset queries
+	topics            []string // Store the topic names of every lane
 	lastMessageTime   time.Time
-	partitionOffsets  map[int32]int64
+	partitionOffsets  map[topicPartition]int64
 	messagesProcessed uint64
 	healthy           bool
 	started           bool
 	apiHealthy        bool
 }
 
+// topicPartition identifies a partition of one of the consumed topics.
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
 // newHealthTracker creates a new health tracker instance
 func newHealthTracker() *healthTracker {
 	return &healthTracker{
-		partitionOffsets: make(map[int32]int64),
+		partitionOffsets: make(map[topicPartition]int64),
 	}
 }
 
 // ===== Message Tracking =====
 
-func (h *healthTracker) start(reader *kafka.Reader, brokerAddr string, topic string) {
+func (h *healthTracker) start(brokerAddr string, topics []string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.reader = reader
 	h.brokerAddr = brokerAddr
-	h.topic = topic
+	h.topics = topics
 	h.started = true
 	h.lastMessageTime = time.Now()
 	l.Log.Info("Consumer started")
 }
 
-func (h *healthTracker) recordMessage(partition int32, offset int64) {
+func (h *healthTracker) recordMessage(topic string, partition int32, offset int64) {
+	tp := topicPartition{topic: topic, partition: partition}
+
 	h.mu.Lock()
-	_, exists := h.partitionOffsets[partition]
+	_, exists := h.partitionOffsets[tp]
 	h.lastMessageTime = time.Now()
-	h.partitionOffsets[partition] = offset
+	h.partitionOffsets[tp] = offset
 	h.messagesProcessed++
 	partitionCount := len(h.partitionOffsets)
 	h.mu.Unlock()
 
 	// Log when we discover a new partition
 	if !exists {
-		l.Log.Infof("Discovered new partition %d (total partitions assigned: %d)", partition, partitionCount)
+		l.Log.Infof("Discovered new partition %d of topic %s (total partitions assigned: %d)", partition, topic, partitionCount)
 	}
 }
 
@@ -75,20 +80,19 @@ func (h *healthTracker) recordMessage(partition int32, offset int64) {
 func (h *healthTracker) calculateLag(ctx context.Context) (int64, error) {
 	h.mu.RLock()
 	broker := h.brokerAddr
-	topic := h.topic
-	partitions := make(map[int32]int64, len(h.partitionOffsets))
-	for p, offset := range h.partitionOffsets {
-		partitions[p] = offset
+	partitions := make(map[topicPartition]int64, len(h.partitionOffsets))
+	for tp, offset := range h.partitionOffsets {
+		partitions[tp] = offset
 	}
 	h.mu.RUnlock()
 
-	if broker == "" || topic == "" || len(partitions) == 0 {
+	if broker == "" || len(partitions) == 0 {
 		return 0, nil
 	}
 
 	var totalLag int64
-	for partition, committed := range partitions {
-		lag, err := h.getPartitionLag(ctx, broker, topic, partition, committed)
+	for tp, committed := range partitions {
+		lag, err := h.getPartitionLag(ctx, broker, tp.topic, tp.partition, committed)
 		if err != nil {
 			return 0, err
 		}
@@ -135,6 +139,13 @@ func (h *healthTracker) isConsumerHealthy(lag int64, lagErr error) bool {
 		return true
 	}
 
+	// The consumer is paused on purpose while the Sources API circuit breaker is not closed, so the waiting messages
+	// do not mean it is stuck.
+	if state := sources.CircuitState(); state != sources.CircuitClosed {
+		l.Log.Debugf("Consumer paused by the Sources API circuit breaker: circuit=%s lag=%d", state, lag)
+		return true
+	}
+
 	idleTime := time.Since(lastMsg)
 
 	// Only unhealthy if messages are available but we're not processing them
@@ -222,9 +233,10 @@ func monitorConsumerHealth(h *healthTracker, stop chan struct{}) {
 
 			h.mu.RLock()
 			partitionCount := len(h.partitionOffsets)
-			l.Log.Debugf("Health: overall=%v api=%v partitions=%d msgs=%d lag=%d idle=%v",
+			l.Log.Debugf("Health: overall=%v api=%v circuit=%s partitions=%d msgs=%d lag=%d idle=%v",
 				h.healthy,
 				h.apiHealthy,
+				sources.CircuitState(),
 				partitionCount,
 				h.messagesProcessed,
 				lag,
@@ -232,10 +244,11 @@ func monitorConsumerHealth(h *healthTracker, stop chan struct{}) {
 
 			// Log partition info at INFO level every 10 health checks (5 minutes by default)
 			if checkCount%10 == 0 {
-				l.Log.Infof("Consumer health summary: partitions=%d msgs_processed=%d lag=%d",
+				l.Log.Infof("Consumer health summary: partitions=%d msgs_processed=%d lag=%d circuit=%s",
 					partitionCount,
 					h.messagesProcessed,
-					lag)
+					lag,
+					sources.CircuitState())
 			}
 			h.mu.RUnlock()
 
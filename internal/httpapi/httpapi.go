@@ -0,0 +1,60 @@
+// Package httpapi holds what the HTTP APIs the worker serves have in common.
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// PSKHeader is the header the clients of the APIs authenticate with.
+const PSKHeader = "x-rh-sources-psk"
+
+// ErrInvalidPSK is what the requests that do not carry the expected PSK get rejected with.
+var ErrInvalidPSK = errors.New("the request does not carry the expected PSK")
+
+// Authenticator rejects the requests that do not carry its PSK with an "unauthorized" response.
+type Authenticator struct {
+	PSK string
+	// Verify, when set, performs additional checks on the requests that carry the PSK.
+	Verify func(r *http.Request) error
+	// Rejected, when set, gets called with every rejected request and the reason it was rejected for.
+	Rejected func(r *http.Request, err error)
+}
+
+// Authenticated returns a handler that only passes the authenticated requests on to the given handler.
+func (a Authenticator) Authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(PSKHeader)), []byte(a.PSK)) != 1 {
+			err = ErrInvalidPSK
+		} else if a.Verify != nil {
+			err = a.Verify(r)
+		}
+
+		if err != nil {
+			if a.Rejected != nil {
+				a.Rejected(r, err)
+			}
+
+			WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// WriteJSON writes the given body as JSON with the given status code.
+func WriteJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		l.Log.Errorf("Unable to write the API response: %s", err)
+	}
+}
@@ -0,0 +1,305 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	bolt "go.etcd.io/bbolt"
+)
+
+// operationsBucket is the bucket the operations are stored in, keyed by the forged application's GUID and the
+// operation's event type, so that an update or a destroy does not overwrite the record of the creation.
+var operationsBucket = []byte("operations")
+
+// The outcomes an entry can have.
+const (
+	OutcomeIntent    = "intent"
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+// Open opens, or creates, the journal database file at the given path.
+func Open(path string) (*BoltJournal, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf(`unable to open the journal file "%s": %w`, path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(operationsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf(`unable to initialize the journal file "%s": %w`, path, err)
+	}
+
+	return &BoltJournal{db: db}, nil
+}
+
+// Close closes the journal's database file.
+func (j *BoltJournal) Close() error {
+	return j.db.Close()
+}
+
+// Begin registers a new operation for the forged application.
+func (j *BoltJournal) Begin(f *superkey.ForgedApplication, eventType string) error {
+	now := time.Now()
+
+	// The identity header holds the user's identity and the extra might hold secrets such as the customer's external
+	// ID, none of which must end up on disk. The organization ID is enough for the recovery to talk to Sources, which
+	// the secrets get restored from.
+	var request *superkey.CreateRequest
+	if f.Request != nil {
+		stored := *f.Request
+		stored.IdentityHeader = ""
+		stored.Extra = withoutSensitiveKeys(f.Request.Extra)
+		request = &stored
+	}
+
+	op := &Operation{
+		GUID:      f.GUID,
+		EventType: eventType,
+		Phase:     superkey.InitialPhase(eventType),
+		Request:   request,
+		Product:   f.Product,
+		Initial:   copySteps(f.StepsCompleted),
+		Entries:   make([]Entry, 0),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	return j.put(op)
+}
+
+// RecordIntent records that the action is about to be performed on the step.
+func (j *BoltJournal) RecordIntent(f *superkey.ForgedApplication, action, step string, data map[string]string) error {
+	return j.update(operationKey(f.GUID, f.Operation), func(op *Operation) {
+		op.Entries = append(op.Entries, Entry{Action: action, Step: step, Outcome: OutcomeIntent, Data: copyData(data), Time: time.Now()})
+	})
+}
+
+// RecordOutcome records the outcome of the action performed on the step.
+func (j *BoltJournal) RecordOutcome(f *superkey.ForgedApplication, action, step string, data map[string]string, stepErr error) error {
+	return j.update(operationKey(f.GUID, f.Operation), func(op *Operation) {
+		entry := Entry{Action: action, Step: step, Outcome: OutcomeSucceeded, Data: copyData(data), Time: time.Now()}
+		if stepErr != nil {
+			entry.Outcome = OutcomeFailed
+			entry.Error = stepErr.Error()
+		}
+
+		op.Entries = append(op.Entries, entry)
+	})
+}
+
+// SetPhase records that the operation moved to the given phase, along with the application's payload at that point.
+func (j *BoltJournal) SetPhase(f *superkey.ForgedApplication, phase string) error {
+	return j.update(operationKey(f.GUID, f.Operation), func(op *Operation) {
+		op.Phase = phase
+		op.Product = f.Product
+	})
+}
+
+// Finish marks the operation as finished.
+func (j *BoltJournal) Finish(f *superkey.ForgedApplication, phase string, opErr error) error {
+	return j.update(operationKey(f.GUID, f.Operation), func(op *Operation) {
+		now := time.Now()
+
+		op.Phase = phase
+		op.FinishedAt = &now
+		if opErr != nil {
+			op.Error = opErr.Error()
+		}
+	})
+}
+
+// Unfinished returns the operations that were interrupted before they could finish.
+func (j *BoltJournal) Unfinished() ([]*Operation, error) {
+	unfinished := make([]*Operation, 0)
+
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).ForEach(func(_, raw []byte) error {
+			op := &Operation{}
+			if err := json.Unmarshal(raw, op); err != nil {
+				return err
+			}
+
+			if op.FinishedAt == nil {
+				unfinished = append(unfinished, op)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the unfinished operations from the journal: %w", err)
+	}
+
+	return unfinished, nil
+}
+
+// PurgeFinished removes the operations that finished before the given retention period.
+func (j *BoltJournal) PurgeFinished(retention time.Duration) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(operationsBucket).Cursor()
+		for key, raw := cursor.First(); key != nil; key, raw = cursor.Next() {
+			op := &Operation{}
+			if err := json.Unmarshal(raw, op); err != nil {
+				return err
+			}
+
+			if op.FinishedAt != nil && time.Since(*op.FinishedAt) > retention {
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// State returns the steps that exist according to the journal: the initial steps of the operation plus the steps
+// that were successfully created or updated, minus the ones that were successfully deleted.
+func (op *Operation) State() map[string]map[string]string {
+	steps := copySteps(op.Initial)
+
+	for _, entry := range op.Entries {
+		if entry.Outcome != OutcomeSucceeded {
+			continue
+		}
+
+		switch entry.Action {
+		case superkey.ActionCreate, superkey.ActionUpdate:
+			steps[entry.Step] = copyData(entry.Data)
+		case superkey.ActionDelete:
+			delete(steps, entry.Step)
+		}
+	}
+
+	return steps
+}
+
+// PendingIntent returns the last recorded intent when no outcome was recorded for it, which means that the worker
+// stopped while the action was being performed. It returns nil otherwise.
+func (op *Operation) PendingIntent() *Entry {
+	if len(op.Entries) == 0 {
+		return nil
+	}
+
+	last := op.Entries[len(op.Entries)-1]
+	if last.Outcome != OutcomeIntent {
+		return nil
+	}
+
+	return &last
+}
+
+// ForgedApplication rebuilds the forged application from the journal. When the worker stopped while creating a
+// resource, the resource might exist, so it is included in the completed steps to make sure it gets torn down.
+func (op *Operation) ForgedApplication() *superkey.ForgedApplication {
+	steps := op.State()
+
+	pending := op.PendingIntent()
+	if pending != nil && pending.Action == superkey.ActionCreate && steps[pending.Step] == nil {
+		// A policy created without knowing the customer's account only has its name recorded, which the provider
+		// looks it up by before tearing it down.
+		if pending.Data["output"] != "" || pending.Step == "bind_role" || (pending.Step == "policy" && pending.Data["name"] != "") {
+			steps[pending.Step] = copyData(pending.Data)
+		}
+	}
+
+	request := op.Request
+	if request == nil {
+		request = &superkey.CreateRequest{}
+	}
+
+	return &superkey.ForgedApplication{
+		Product:        op.Product,
+		StepsCompleted: steps,
+		Request:        request,
+		GUID:           op.GUID,
+		Operation:      op.EventType,
+	}
+}
+
+// put stores the operation in the journal.
+func (j *BoltJournal) put(op *Operation) error {
+	raw, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the operation: %w", err)
+	}
+
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).Put([]byte(operationKey(op.GUID, op.EventType)), raw)
+	})
+}
+
+// update applies the given modification to the stored operation in a single transaction.
+func (j *BoltJournal) update(key string, modify func(op *Operation)) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(operationsBucket)
+
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return fmt.Errorf(`operation "%s" not found in the journal`, key)
+		}
+
+		op := &Operation{}
+		if err := json.Unmarshal(raw, op); err != nil {
+			return fmt.Errorf("unable to unmarshal the operation: %w", err)
+		}
+
+		modify(op)
+		op.UpdatedAt = time.Now()
+
+		updated, err := json.Marshal(op)
+		if err != nil {
+			return fmt.Errorf("unable to marshal the operation: %w", err)
+		}
+
+		return bucket.Put([]byte(key), updated)
+	})
+}
+
+// operationKey returns the key the operation of the given event type on the forged application is stored under.
+func operationKey(guid, eventType string) string {
+	return guid + "/" + eventType
+}
+
+func copySteps(steps map[string]map[string]string) map[string]map[string]string {
+	copied := make(map[string]map[string]string, len(steps))
+	for name, data := range steps {
+		copied[name] = copyData(data)
+	}
+
+	return copied
+}
+
+func copyData(data map[string]string) map[string]string {
+	copied := make(map[string]string, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+// withoutSensitiveKeys returns a copy of the extra without the keys whose values must not be stored in plain text.
+func withoutSensitiveKeys(extra map[string]string) map[string]string {
+	if extra == nil {
+		return nil
+	}
+
+	stripped := make(map[string]string, len(extra))
+	for k, v := range extra {
+		if !l.IsSensitiveKey(k) {
+			stripped[k] = v
+		}
+	}
+
+	return stripped
+}
@@ -0,0 +1,39 @@
+package journal
+
+import (
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltJournal is a superkey.Journal backed by an embedded bbolt database file, which is expected to live on a volume
+// that survives the worker's restarts.
+type BoltJournal struct {
+	db *bolt.DB
+}
+
+// Operation represents an operation performed on a forged application, as recorded in the journal.
+type Operation struct {
+	GUID       string                       `json:"guid"`
+	EventType  string                       `json:"event_type"`
+	Phase      string                       `json:"phase"`
+	Request    *superkey.CreateRequest      `json:"request"`
+	Product    *superkey.App                `json:"product"`
+	Initial    map[string]map[string]string `json:"initial_steps"`
+	Entries    []Entry                      `json:"entries"`
+	StartedAt  time.Time                    `json:"started_at"`
+	UpdatedAt  time.Time                    `json:"updated_at"`
+	FinishedAt *time.Time                   `json:"finished_at,omitempty"`
+	Error      string                       `json:"error,omitempty"`
+}
+
+// Entry is a single record of the intent or the outcome of an action performed on a step.
+type Entry struct {
+	Action  string            `json:"action"`
+	Step    string            `json:"step"`
+	Outcome string            `json:"outcome"`
+	Data    map[string]string `json:"data,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Time    time.Time         `json:"time"`
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"flag"
+	"net/http"
 	"os"
 	"time"
 
@@ -58,11 +59,21 @@ func (f *CustomLoggerFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	}
 
 	for k, v := range entry.Data {
+		if IsSensitiveKey(k) {
+			data[k] = RedactedValue
+			continue
+		}
+
 		switch v := v.(type) {
 		case error:
 			data[k] = v.Error()
 		case Marshaler:
-			data[k] = v.MarshalLog()
+			// The marshaled value can still hold nested maps with sensitive keys.
+			data[k] = redactValue(v.MarshalLog())
+		case http.Header:
+			data[k] = RedactHeaders(v)
+		case map[string]string, map[string]map[string]string, map[string]interface{}, []interface{}:
+			data[k] = redactValue(v)
 		default:
 			data[k] = v
 		}
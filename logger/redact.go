@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RedactedValue replaces the sensitive values in the logs.
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys are the keys, in lowercase, of the fields, the JSON properties and the headers whose values are
+// redacted from the logs, on top of the ones that mention a secret or a password.
+var sensitiveKeys = map[string]bool{
+	"access_key":        true,
+	"aws_access_key_id": true,
+	"authorization":     true,
+	"external_id":       true,
+	"identity_header":   true,
+	"psk":               true,
+	"super_key":         true,
+	"x-rh-identity":     true,
+	"x-rh-sources-psk":  true,
+}
+
+// IsSensitiveKey returns true when the values of the field, JSON property or header with the given key must not be
+// logged in plain text.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+
+	return sensitiveKeys[key] || strings.Contains(key, "secret") || strings.Contains(key, "password")
+}
+
+// String returns the redacted value, so that the secret does not end up in the logs when formatted.
+func (s Secret) String() string {
+	return RedactedValue
+}
+
+// GoString returns the redacted value, so that the secret does not end up in the logs when formatted with "%#v".
+func (s Secret) GoString() string {
+	return RedactedValue
+}
+
+// MarshalJSON marshals the redacted value.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RedactedValue)
+}
+
+// Reveal returns the secret in plain text.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// RedactJSON returns the given JSON document with the values of the sensitive properties redacted, at any depth.
+// Documents that cannot be parsed are not returned at all, since there is no telling what they hold.
+func RedactJSON(raw []byte) string {
+	var document interface{}
+
+	err := json.Unmarshal(raw, &document)
+	if err != nil {
+		return fmt.Sprintf("[REDACTED unparseable payload of %d bytes]", len(raw))
+	}
+
+	redacted, err := json.Marshal(redactValue(document))
+	if err != nil {
+		return fmt.Sprintf("[REDACTED unparseable payload of %d bytes]", len(raw))
+	}
+
+	return string(redacted)
+}
+
+// RedactMap returns a copy of the given map with the values of the sensitive keys redacted.
+func RedactMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(values))
+	for key, value := range values {
+		if IsSensitiveKey(key) {
+			value = RedactedValue
+		}
+
+		redacted[key] = value
+	}
+
+	return redacted
+}
+
+// RedactNestedMap returns a copy of the given map of maps, such as the completed superkey steps, with the values of
+// the sensitive keys redacted at both levels.
+func RedactNestedMap(values map[string]map[string]string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(values))
+	for key, nested := range values {
+		if IsSensitiveKey(key) {
+			redacted[key] = RedactedValue
+			continue
+		}
+
+		redacted[key] = RedactMap(nested)
+	}
+
+	return redacted
+}
+
+// RedactHeaders returns a copy of the given headers with the values of the sensitive ones redacted.
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header, len(headers))
+	for key, values := range headers {
+		if IsSensitiveKey(key) {
+			redacted[key] = []string{RedactedValue}
+			continue
+		}
+
+		redacted[key] = values
+	}
+
+	return redacted
+}
+
+// redactValue returns a copy of the given value with the sensitive properties redacted, at any depth. Besides the
+// decoded JSON values, it redacts the string maps and the maps of string maps the log fields are usually made of.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			if IsSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+
+			redacted[key] = redactValue(nested)
+		}
+
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, nested := range v {
+			redacted[i] = redactValue(nested)
+		}
+
+		return redacted
+	case map[string]string:
+		return RedactMap(v)
+	case map[string]map[string]string:
+		return RedactNestedMap(v)
+	default:
+		return v
+	}
+}
@@ -10,3 +10,6 @@ type CustomLoggerFormatter struct {
 type Marshaler interface {
 	MarshalLog() map[string]interface{}
 }
+
+// Secret is a string that is never printed in plain text, neither in the logs nor when formatted or marshalled.
+type Secret string
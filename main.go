@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,25 +16,50 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redhatinsights/sources-superkey-worker/admin"
+	"github.com/redhatinsights/sources-superkey-worker/audit"
 	"github.com/redhatinsights/sources-superkey-worker/config"
+	"github.com/redhatinsights/sources-superkey-worker/journal"
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/messaging"
+	"github.com/redhatinsights/sources-superkey-worker/progress"
 	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/status"
 	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	"github.com/redhatinsights/sources-superkey-worker/teardownqueue"
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	superkeyRequestedTopic = "platform.sources.superkey-requests"
-)
-
 var (
 	// DisableCreation disabled processing `create_application` sk requests
 	DisableCreation = os.Getenv("DISABLE_RESOURCE_CREATION")
 	// DisableDeletion disabled processing `destroy_application` sk requests
 	DisableDeletion = os.Getenv("DISABLE_RESOURCE_DELETION")
+	// DisableUpdate disabled processing `update_application` sk requests
+	DisableUpdate = os.Getenv("DISABLE_RESOURCE_UPDATE")
+
+	conf = config.Get()
+
+	// teardownEventsWriter publishes the teardown results of the applications that no longer exist in Sources.
+	teardownEventsWriter *kafka.Writer
+
+	// operationTracker keeps track of the operations in flight and the recently finished ones for the status API.
+	operationTracker = status.NewTracker(conf.StatusHistorySize)
+
+	// processedMessages keeps track of the requests we already processed, so that redelivered messages are skipped. It
+	// only remembers them in memory unless a file is configured for them.
+	processedMessages = messaging.NewDedupStore(conf.ProcessedMessagesTTL)
+
+	// applicationLocks serializes the requests and the teardown retries of the same source's applications, so that
+	// they do not act on their resources at the same time.
+	applicationLocks = messaging.NewKeyLocks()
+
+	// errNoLockKey is returned when a request carries nothing to serialize the work done for its application on.
+	errNoLockKey = errors.New("the request carries neither the source's identifier nor the GUID of the resources")
 
-	conf          = config.Get()
-	superkeyTopic = conf.KafkaTopic(superkeyRequestedTopic)
+	// pendingRegistrations keeps the resources whose registration in Sources has to wait for the Sources API.
+	pendingRegistrations = newPendingRegistrations()
 
 	// Metrics
 	successfulResourcesCreationCounter = promauto.NewCounter(prometheus.CounterOpts{
@@ -43,6 +70,26 @@ var (
 		Name: "sources_superkey_unsuccessful_creation_requests",
 		Help: "The number of unsuccessful resources creation requests",
 	})
+	skippedDuplicateRequestsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_skipped_duplicate_requests",
+		Help: "The number of redelivered requests that were skipped because they had already been processed",
+	})
+	skippedStaleRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_skipped_stale_requests",
+		Help: "The number of create requests that were skipped because of the state of their application in Sources, by reason",
+	}, []string{"reason"})
+	invalidRequestsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_invalid_requests",
+		Help: "The number of requests that were skipped because they did not conform to their JSON schema",
+	})
+	successfulResourcesUpdateCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_successful_update_requests",
+		Help: "The number of successful resources update requests",
+	})
+	unsuccessfulResourcesUpdateCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_unsuccessful_update_requests",
+		Help: "The number of unsuccessful resources update requests",
+	})
 	successfulResourcesDeletionCounter = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "sources_superkey_successful_deletion_requests",
 		Help: "The number of successful resources deletion requests",
@@ -51,21 +98,57 @@ var (
 		Name: "sources_superkey_unsuccessful_deletion_requests",
 		Help: "The number of unsuccessful resources deletion requests",
 	})
+	queuedTeardownsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_queued_teardowns",
+		Help: "The number of resources that could not be torn down and were queued for retrying",
+	})
+	successfulTeardownRetriesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_successful_teardown_retries",
+		Help: "The number of queued resources that were torn down, or found to be already deleted, when retried",
+	})
+	failedTeardownRetriesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_failed_teardown_retries",
+		Help: "The number of retries of queued teardowns that failed again",
+	})
+	abandonedTeardownsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_abandoned_teardowns",
+		Help: "The number of queued resources that were given up on after reaching the maximum retry age",
+	})
+	teardownResourcesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_teardown_resources",
+		Help: "The number of resources processed by teardowns, by step, status and class of the error",
+	}, []string{"step", "status", "error_class"})
+	teardownQueueSizeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sources_superkey_teardown_queue_size",
+		Help: "The number of resources waiting for their teardown to be retried",
+	})
 )
 
 func main() {
 	l.InitLogger(conf)
 
 	initMetrics()
+	initAdminApi()
+
+	// Replaying the messages from a file does not involve Kafka at all, which allows reproducing issues locally.
+	replaying := conf.ReplayFile != ""
+
+	// Set up the client shared by every call to Sources up front, so that a broken TLS setup stops the worker right
+	// away instead of failing every request.
+	if _, err := sources.Client(); err != nil {
+		l.Log.Fatalf(`could not set up the Sources client: %s`, err)
+	}
 
 	// Create health tracker instance
 	health := newHealthTracker()
 
 	// Start the health monitoring goroutine that tracks consumer health
 	// and manages the Kubernetes probe health file based on consumer activity.
-	stopHealthMonitor := make(chan struct{})
-	defer close(stopHealthMonitor)
-	go monitorConsumerHealth(health, stopHealthMonitor)
+	if !replaying {
+		stopHealthMonitor := make(chan struct{})
+		defer close(stopHealthMonitor)
+		go monitorConsumerHealth(health, stopHealthMonitor)
+	}
 
 	var brokers strings.Builder
 	for i, broker := range conf.KafkaBrokerConfig {
@@ -75,73 +158,298 @@ func main() {
 		}
 	}
 
-	l.Log.Infof("Listening to Kafka at: %s, topic: %v", brokers.String(), superkeyTopic)
-	l.Log.Infof("Talking to Sources API at: [%v]", fmt.Sprintf("%v://%v:%v", conf.SourcesScheme, conf.SourcesHost, conf.SourcesPort))
+	// Remember the processed messages across restarts, since the redeliveries are caused by the crashes and the
+	// rebalances that would otherwise make the worker forget about them.
+	if conf.ProcessedMessagesPath != "" && !replaying {
+		store, err := messaging.OpenDedupStore(conf.ProcessedMessagesPath, conf.ProcessedMessagesTTL)
+		if err != nil {
+			l.Log.Fatalf(`could not open the processed messages store: %s`, err)
+		}
+		defer store.Close()
 
-	reader, err := kafka.GetReader(&kafka.Options{
-		BrokerConfig: conf.KafkaBrokerConfig,
-		Topic:        superkeyTopic,
-		GroupID:      &conf.KafkaGroupID,
-		Logger:       l.Log.WithField("kafka", ""),
-	})
-	if err != nil {
-		l.Log.Fatalf(`could not get Kafka reader: %s`, err)
+		processedMessages = store
 	}
 
+	// The teardown results of the applications that no longer exist in Sources get published to their own topic.
+	if conf.TeardownEventsTopic != "" && !replaying {
+		teardownEventsTopic := conf.KafkaTopic(conf.TeardownEventsTopic)
+		writer, err := kafka.GetWriter(&kafka.Options{
+			BrokerConfig: conf.KafkaBrokerConfig,
+			Topic:        teardownEventsTopic,
+			Logger:       l.Log.WithFields(logrus.Fields{"kafka": "", "topic": teardownEventsTopic}),
+		})
+		if err != nil {
+			l.Log.Fatalf(`could not get Kafka writer for topic "%s": %s`, teardownEventsTopic, err)
+		}
+		teardownEventsWriter = writer
+	}
+
+	// Publish the availability status updates to the Sources status topic, so that the failures still get recorded when
+	// the Sources API is unhealthy.
+	var statusWriter *kafka.Writer
+	if conf.SourcesStatusTopic != "" && !replaying {
+		statusTopic := conf.KafkaTopic(conf.SourcesStatusTopic)
+		writer, err := kafka.GetWriter(&kafka.Options{
+			BrokerConfig: conf.KafkaBrokerConfig,
+			Topic:        statusTopic,
+			Logger:       l.Log.WithFields(logrus.Fields{"kafka": "", "topic": statusTopic}),
+		})
+		if err != nil {
+			l.Log.Fatalf(`could not get Kafka writer for topic "%s": %s`, statusTopic, err)
+		}
+
+		statusWriter = writer
+		sources.SetStatusWriter(writer)
+	}
+
+	// Keep an audit trail of every call that mutates the customers' cloud resources, apart from the logs.
+	var auditWriter *kafka.Writer
+	var auditSink *audit.KafkaSink
+	switch {
+	case conf.AuditTopic != "" && !replaying:
+		auditTopic := conf.KafkaTopic(conf.AuditTopic)
+		writer, err := kafka.GetWriter(&kafka.Options{
+			BrokerConfig: conf.KafkaBrokerConfig,
+			Topic:        auditTopic,
+			Logger:       l.Log.WithFields(logrus.Fields{"kafka": "", "topic": auditTopic}),
+		})
+		if err != nil {
+			l.Log.Fatalf(`could not get Kafka writer for topic "%s": %s`, auditTopic, err)
+		}
+
+		auditWriter = writer
+		auditSink = audit.NewKafkaSink(writer)
+		audit.SetSink(auditSink)
+	case conf.AuditLogPath != "":
+		sink, err := audit.NewFileSink(conf.AuditLogPath)
+		if err != nil {
+			l.Log.Fatalf(`could not open the audit log: %s`, err)
+		}
+		defer sink.Close()
+
+		audit.SetSink(sink)
+	default:
+		l.Log.Warn("No audit log configured, the audit records of the cloud mutations will not be kept")
+	}
+
+	// Queue the failed teardowns, and retry them in the background. Neither the queue nor the journal are used when
+	// replaying, since their pending work would run against the real AWS and Sources.
+	if conf.TeardownQueuePath != "" && !replaying {
+		queue, err := teardownqueue.Open(conf.TeardownQueuePath)
+		if err != nil {
+			l.Log.Fatalf(`could not open the teardown retry queue: %s`, err)
+		}
+		defer queue.Close()
+
+		teardownQueue = queue
+		updateTeardownQueueSize()
+	}
+
+	// Keep the application extras that could not be stored on disk, so that a restart does not lose them.
+	if conf.PendingExtrasPath != "" && !replaying {
+		closePendingExtras, err := sources.PersistPendingExtras(conf.PendingExtrasPath)
+		if err != nil {
+			l.Log.Fatalf(`could not open the pending application extras: %s`, err)
+		}
+		defer closePendingExtras()
+	}
+
+	// Cache the superkey steps of the application types, so that Sources does not get asked for them on every request.
+	superkey.SetStepsCache(superkey.NewStepsCache(conf.SuperKeyMetadataTTL))
+
+	// Report the progress of the operations to their applications in Sources, apart from the status API.
+	journals := []superkey.Journal{operationTracker}
+	if conf.ReportProgress {
+		journals = append(journals, progress.NewReporter())
+	}
+
+	superkey.SetJournal(journals...)
+
+	// Resume or roll back the operations that a crash or a restart interrupted, before processing any new request.
+	if conf.JournalPath != "" && !replaying {
+		operationJournal, err := journal.Open(conf.JournalPath)
+		if err != nil {
+			l.Log.Fatalf(`could not open the operation journal: %s`, err)
+		}
+		defer operationJournal.Close()
+
+		superkey.SetJournal(append([]superkey.Journal{operationJournal}, journals...)...)
+
+		err = operationJournal.PurgeFinished(conf.JournalRetention)
+		if err != nil {
+			l.Log.Errorf("Unable to purge the finished operations from the journal: %s", err)
+		}
+
+		recoverInterruptedOperations(operationJournal)
+	}
+
+	consumerCtx, cancelConsumer := context.WithCancel(context.Background())
+
+	if teardownQueue != nil {
+		go retryFailedTeardowns(consumerCtx)
+	}
+
+	// Forget about the processed messages once their TTL expires.
+	go processedMessages.PurgePeriodically(consumerCtx)
+
+	// Store the application extras that could not be stored while the Sources API was down.
+	go sources.RetryPendingExtras(consumerCtx)
+
+	l.Log.Infof("Talking to Sources API at: [%v]", fmt.Sprintf("%v://%v:%v", conf.SourcesScheme, conf.SourcesHost, conf.SourcesPort))
+
 	// Build broker address for health checks
 	var brokerAddr string
 	if len(conf.KafkaBrokerConfig) > 0 {
 		brokerAddr = fmt.Sprintf("%s:%d", conf.KafkaBrokerConfig[0].Hostname, *conf.KafkaBrokerConfig[0].Port)
 	}
 
-	go func() {
-		l.Log.Info("SuperKey Worker started.")
+	// Either replay the messages from a file, or consume them from Kafka.
+	var source messaging.MessageSource
+	var handler messaging.Handler
+	if replaying {
+		l.Log.Infof("Replaying the messages from: %s", conf.ReplayFile)
+
+		source = messaging.NewFileSource(conf.ReplayFile)
+		handler = processSuperkeyRequest
+	} else {
+		// Every lane gets its own reader, but they all share the same priority gate so that the lanes with a higher
+		// priority get their messages processed first.
+		gate := messaging.NewPriorityGate()
+		lanes := make([]messaging.KafkaLane, 0, len(conf.KafkaLanes))
+		topics := make([]string, 0, len(conf.KafkaLanes))
+		for _, lane := range conf.KafkaLanes {
+			topic := conf.KafkaTopic(lane.Topic)
+
+			l.Log.Infof("Listening to Kafka at: %s, topic: %v, priority: %d, concurrency: %d", brokers.String(), topic, lane.Priority, lane.Concurrency)
+
+			reader, err := kafka.GetReader(&kafka.Options{
+				BrokerConfig: conf.KafkaBrokerConfig,
+				Topic:        topic,
+				GroupID:      &conf.KafkaGroupID,
+				Logger:       l.Log.WithFields(logrus.Fields{"kafka": "", "topic": topic}),
+			})
+			if err != nil {
+				l.Log.Fatalf(`could not get Kafka reader for topic "%s": %s`, topic, err)
+			}
+
+			lanes = append(lanes, messaging.KafkaLane{
+				Topic:   topic,
+				Reader:  reader,
+				Options: messaging.LaneOptions{Priority: lane.Priority, Concurrency: lane.Concurrency, Gate: gate, Ready: sources.WaitUntilAvailable},
+			})
+			topics = append(topics, topic)
+		}
+
+		source = messaging.NewKafkaSource(lanes)
+		handler = func(msg messaging.Message) error {
+			health.recordMessage(msg.Topic, int32(msg.Partition), msg.Offset)
 
-		health.start(reader, brokerAddr, superkeyTopic)
+			return processSuperkeyRequest(msg)
+		}
 
-		kafka.Consume(
-			reader,
-			func(msg kafka.Message) {
-				health.recordMessage(int32(msg.Partition), msg.Offset)
+		health.start(brokerAddr, topics)
+	}
 
-				processSuperkeyRequest(msg)
-			},
-		)
+	sourceDone := make(chan error, 1)
+	go func() {
+		sourceDone <- source.Run(consumerCtx, handler)
 	}()
 
+	l.Log.Info("SuperKey Worker started.")
+
 	interrupts := make(chan os.Signal, 1)
 	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
 
 	// wait for a signal from the OS, gracefully terminating the consumer
-	// if/when that comes in
-	s := <-interrupts
+	// if/when that comes in, or for the source to run out of messages
+	exitCode := 0
+	select {
+	case s := <-interrupts:
+		l.Log.Infof("Received %v, exiting", s)
+	case err := <-sourceDone:
+		if err != nil {
+			l.Log.Errorf("Unable to process the messages: %s", err)
+			exitCode = 1
+		} else {
+			l.Log.Info("No more messages to process, exiting")
+		}
+	}
 
-	l.Log.Infof("Received %v, exiting", s)
-	kafka.CloseReader(reader, "superkey reader")
-	os.Exit(0)
+	cancelConsumer()
+	source.Close()
+	if teardownEventsWriter != nil {
+		kafka.CloseWriter(teardownEventsWriter, "teardown events writer")
+	}
+	if auditWriter != nil {
+		// Flush the buffered audit records before the writer goes away.
+		auditSink.Close()
+		kafka.CloseWriter(auditWriter, "audit writer")
+	}
+	if statusWriter != nil {
+		kafka.CloseWriter(statusWriter, "availability status writer")
+	}
+	os.Exit(exitCode)
 }
 
 // processSuperkeyRequest - processes messages.
-func processSuperkeyRequest(msg kafka.Message) {
+// returns: an error when the request could not be processed because the Sources API is unavailable, so that the
+// message gets delivered again instead of being committed.
+func processSuperkeyRequest(msg messaging.Message) error {
 	eventType := msg.GetHeader("event_type")
 	identityHeader := msg.GetHeader("x-rh-identity")
 	orgIdHeader := msg.GetHeader("x-rh-sources-org-id")
+	schemaVersion := msg.GetHeader("schema_version")
 
 	if identityHeader == "" && orgIdHeader == "" {
-		l.Log.WithFields(logrus.Fields{"kafka_message": string(msg.Value), "message_key": string(msg.Key)}).Error(`Skipping Superkey request because no "x-rh-identity" or "x-rh-sources-org-id" headers were found`)
+		l.Log.WithFields(logrus.Fields{"kafka_message": l.RedactJSON(msg.Value), "message_key": string(msg.Key)}).Error(`Skipping Superkey request because no "x-rh-identity" or "x-rh-sources-org-id" headers were found`)
 
-		return
+		return nil
 	}
 
-	l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Debugf(`Processing Kafka message: %s`, string(msg.Value))
+	l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Debugf(`Processing Kafka message: %s`, l.RedactJSON(msg.Value))
+
+	// The requests of every type carry the source of the application, apart from the destroy requests sent once the
+	// source is gone, which still carry the GUID of the resources.
+	target := struct {
+		SourceID string `json:"source_id"`
+		GUID     string `json:"guid"`
+	}{}
+	// The type mismatches are left to the validation, which reports them to the application.
+	err := msg.ParseTo(&target)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Errorf(`Skipping "%s" request because it could not be parsed: %s`, eventType, err)
+		return nil
+	}
+
+	lockKey, err := applicationLockKey(target.SourceID, target.GUID)
+	if err != nil {
+		l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Errorf(`Skipping "%s" request: %s`, eventType, err)
+		return nil
+	}
+
+	unlock := applicationLocks.Lock(lockKey)
+	defer unlock()
 
 	switch eventType {
 	case "create_application":
+		// Nothing gets reported to Sources when the creation is disabled, not even the validation errors.
+		if DisableCreation == "true" {
+			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Info(`Skipping "create_application" request because the the resource creation was disabled by the env var`)
+			l.Log.Debugf(`Skipped "create_application" Kafka message: %s`, l.RedactJSON(msg.Value))
+			return nil
+		}
+
+		// Validate the request before parsing it, so that type mismatches get reported with the offending field.
+		validationErr := superkey.ValidateRequest(schemaVersion, eventType, msg.Value)
+
+		// An invalid request might still be partially parsed, which gives us the chance of reporting the validation
+		// errors back to the application.
 		req := &superkey.CreateRequest{}
 		err := msg.ParseTo(req)
-		if err != nil {
-			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Error parsing "create_application" request "%s": %s`, string(msg.Value), err)
-			return
+		if err != nil && validationErr == nil {
+			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Error parsing "create_application" request "%s": %s`, l.RedactJSON(msg.Value), err)
+			return nil
 		}
 		req.IdentityHeader = identityHeader
 		req.OrgIdHeader = orgIdHeader
@@ -152,102 +460,445 @@ func processSuperkeyRequest(msg kafka.Message) {
 		ctx = l.WithApplicationId(ctx, req.ApplicationID)
 		ctx = l.WithApplicationType(ctx, req.ApplicationType)
 
-		if DisableCreation == "true" {
-			l.LogWithContext(ctx).Info(`Skipping "create_application" request because the the resource creation was disabled by the env var`)
-			l.LogWithContext(ctx).Debugf(`Skipped "create_application" Kafka message: %s`, string(msg.Value))
-			return
+		if validationErr != nil {
+			l.LogWithContext(ctx).Errorf(`Skipping "create_application" request because it does not conform to the schema version "%s": %s`, schemaVersion, validationErr)
+			invalidRequestsCounter.Inc()
+
+			if req.ApplicationID == "" {
+				return nil
+			}
+
+			err := req.MarkRequestInvalid(ctx, validationErr)
+			if err != nil {
+				l.LogWithContext(ctx).Errorf(`Error while reporting the validation errors to the application in Sources: %s`, err)
+			}
+
+			return nil
+		}
+
+		dedupKey := messaging.DedupKey(eventType, msg, req.ApplicationID)
+		if processedMessages.IsProcessed(dedupKey) {
+			l.LogWithContext(ctx).Warn(`Skipping "create_application" request because it has already been processed`)
+			skippedDuplicateRequestsCounter.Inc()
+			return nil
 		}
 
 		l.LogWithContext(ctx).Info(`Processing "create_application" request`)
 
-		createResources(ctx, req)
+		// Only the successful requests are remembered, so that a redelivered failed request gets another chance.
+		err = createResources(ctx, req)
+		if isRetryable(err) {
+			return err
+		}
+
+		if err == nil || errors.Is(err, admin.ErrSkipped) {
+			processedMessages.MarkProcessed(dedupKey)
+		}
 
 		l.LogWithContext(ctx).Info(`Finished processing "create_application"`)
 
 	case "destroy_application":
+		// Destroy requests do not carry the application's identifier, so the validation errors can only be logged.
+		validationErr := superkey.ValidateRequest(schemaVersion, eventType, msg.Value)
+		if validationErr != nil {
+			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader, "message_key": string(msg.Key)}).Errorf(`Skipping "destroy_application" request because it does not conform to the schema version "%s": %s`, schemaVersion, validationErr)
+			invalidRequestsCounter.Inc()
+			return nil
+		}
+
 		req := &superkey.DestroyRequest{}
 		err := msg.ParseTo(req)
 		if err != nil {
-			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Error parsing "destroy_application" request "%s": %s`, string(msg.Value), err)
-			return
+			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Error parsing "destroy_application" request "%s": %s`, l.RedactJSON(msg.Value), err)
+			return nil
 		}
+		req.IdentityHeader = identityHeader
+		req.OrgIdHeader = orgIdHeader
 
 		// Define the log context with the fields we want to log.
 		ctx := l.WithTenantId(context.Background(), req.TenantID)
+		ctx = l.WithSourceId(ctx, req.SourceID)
+		ctx = l.WithApplicationId(ctx, req.ApplicationID)
 
 		if DisableDeletion == "true" {
 			l.LogWithContext(ctx).Info(`Skipping "create_application"" request because the the resource creation was disabled by the env var`)
-			l.LogWithContext(ctx).Debugf(`Skipping destroy_application request: %s`, string(msg.Value))
-			return
+			l.LogWithContext(ctx).Debugf(`Skipping destroy_application request: %s`, l.RedactJSON(msg.Value))
+			return nil
+		}
+
+		// Destroy requests do not carry the application's identifier, but the GUID identifies its resources just as well.
+		dedupKey := messaging.DedupKey(eventType, msg, req.GUID)
+		if processedMessages.IsProcessed(dedupKey) {
+			l.LogWithContext(ctx).Warn(`Skipping "destroy_application" request because it has already been processed`)
+			skippedDuplicateRequestsCounter.Inc()
+			return nil
 		}
 
 		l.LogWithContext(ctx).Info(`Processing "destroy_application" request`)
 
-		destroyResources(ctx, req)
+		report, err := destroyResources(ctx, req)
+		if err == nil && report.Succeeded() {
+			processedMessages.MarkProcessed(dedupKey)
+		}
 
 		l.LogWithContext(ctx).Info(`Finished processing "destroy_application" request`)
 
+	case "update_application":
+		validationErr := superkey.ValidateRequest(schemaVersion, eventType, msg.Value)
+
+		req := &superkey.UpdateRequest{}
+		err := msg.ParseTo(req)
+		if err != nil && validationErr == nil {
+			l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Error parsing "update_application" request "%s": %s`, l.RedactJSON(msg.Value), err)
+			return nil
+		}
+		req.IdentityHeader = identityHeader
+		req.OrgIdHeader = orgIdHeader
+
+		// Define the log context with the fields we want to log.
+		ctx := l.WithTenantId(context.Background(), req.TenantID)
+		ctx = l.WithSourceId(ctx, req.SourceID)
+		ctx = l.WithApplicationId(ctx, req.ApplicationID)
+		ctx = l.WithApplicationType(ctx, req.ApplicationType)
+
+		if validationErr != nil {
+			l.LogWithContext(ctx).Errorf(`Skipping "update_application" request because it does not conform to the schema version "%s": %s`, schemaVersion, validationErr)
+			invalidRequestsCounter.Inc()
+			return nil
+		}
+
+		if DisableUpdate == "true" {
+			l.LogWithContext(ctx).Info(`Skipping "update_application" request because the resource update was disabled by the env var`)
+			l.LogWithContext(ctx).Debugf(`Skipped "update_application" Kafka message: %s`, l.RedactJSON(msg.Value))
+			return nil
+		}
+
+		dedupKey := messaging.DedupKey(eventType, msg, req.ApplicationID)
+		if processedMessages.IsProcessed(dedupKey) {
+			l.LogWithContext(ctx).Warn(`Skipping "update_application" request because it has already been processed`)
+			skippedDuplicateRequestsCounter.Inc()
+			return nil
+		}
+
+		l.LogWithContext(ctx).Info(`Processing "update_application" request`)
+
+		err = updateResources(ctx, req)
+		if isRetryable(err) {
+			return err
+		}
+
+		if err == nil {
+			processedMessages.MarkProcessed(dedupKey)
+		}
+
+		l.LogWithContext(ctx).Info(`Finished processing "update_application" request`)
+
 	default:
 		l.Log.WithFields(logrus.Fields{"org_id": orgIdHeader}).Errorf(`Unknown event type "%s" received in the header, skipping request...`, eventType)
 	}
+
+	return nil
+}
+
+// isRetryable returns true when the error comes from the Sources API being unavailable, or from a lookup that failed
+// before anything was registered, in which case the request is left as is so that it can be processed again.
+func isRetryable(err error) bool {
+	return errors.Is(err, sources.ErrCircuitOpen) || errors.Is(err, superkey.ErrApplicationLookup)
+}
+
+// applicationLockKey returns the key of the lock that serializes the work done for the applications of a source. The
+// source is the only identifier that the create, update and destroy requests of an application all carry, since the
+// create requests do not have a GUID yet and the destroy requests do not carry the application's identifier. The
+// GUID of the resources is only used for the destroy requests of the sources that are gone.
+// returns: the lock key, or an error when the request does not carry any of them.
+func applicationLockKey(sourceId, guid string) (string, error) {
+	switch {
+	case sourceId != "":
+		return "source/" + sourceId, nil
+	case guid != "":
+		return "guid/" + guid, nil
+	default:
+		return "", errNoLockKey
+	}
 }
 
-func createResources(ctx context.Context, req *superkey.CreateRequest) {
-	l.LogWithContext(ctx).Debugf("Forging request: %v", req)
+// createResources forges the resources of the request and registers them in Sources, rolling everything back when
+// something fails.
+// returns: the error that made the creation fail, if any, or an error wrapping "admin.ErrSkipped" when the request was
+// skipped because of the state of its application.
+func createResources(ctx context.Context, req *superkey.CreateRequest) error {
+	// The resources of a previous delivery of the request are already forged, so only their registration is left. The
+	// pre-forge checks are skipped for them, since the superkey data they might have stored already makes the
+	// application look provisioned.
+	pendingApp := pendingRegistrations.take(req.ApplicationID)
+	if pendingApp != nil {
+		l.LogWithContext(ctx).Info("Resuming the registration of the resources forged by a previous delivery of the request")
+		return registerForgedResources(ctx, pendingApp, true)
+	}
+
+	// A delayed request must not forge resources for an application that changed in the meantime.
+	skip, err := req.CheckBeforeForging(ctx)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to check the state of the application in Sources before forging: %s`, err)
+		if !isRetryable(err) {
+			unsuccessfulResourcesCreationCounter.Inc()
+		}
+
+		return err
+	}
+
+	// The skipped requests are not failures, but the admin API still needs to tell that nothing was done.
+	if skip != nil {
+		l.LogWithContext(ctx).WithField("skip_reason", skip.Code).Warnf(`Skipping "create_application" request because %s`, skip.Message)
+		skippedStaleRequestsCounter.WithLabelValues(skip.Code).Inc()
+		return fmt.Errorf("%w: %s", admin.ErrSkipped, skip.Message)
+	}
+
+	// The steps come from the application type, so that the fixes to its superkey metadata apply to the queued requests.
+	err = req.ResolveSteps(ctx)
+	if isRetryable(err) {
+		l.LogWithContext(ctx).Errorf(`Unable to resolve the superkey steps of the request: %s`, err)
+		return err
+	}
+
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to resolve the superkey steps of the request: %s`, err)
+
+		markErr := req.MarkSourceUnavailable(ctx, err, nil)
+		if markErr != nil {
+			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, markErr)
+		}
+
+		unsuccessfulResourcesCreationCounter.Inc()
+		return err
+	}
+
+	l.LogWithContext(ctx).WithField("request", req).Debug("Forging request")
 
 	newApp, err := provider.Forge(ctx, req)
 	if err != nil {
-		l.LogWithContext(ctx).Errorf(`Tearing down Superkey request due to an error while forging the request \"%v\": %s`, req, err)
+		l.LogWithContext(ctx).WithField("request", req).Errorf(`Tearing down Superkey request due to an error while forging the request: %s`, err)
 
-		errors := provider.TearDown(ctx, newApp)
-		if len(errors) != 0 {
-			for _, err := range errors {
-				l.LogWithContext(ctx).Errorf(`Unable to tear down application: %s`, err)
-			}
+		report := provider.TearDown(ctx, newApp)
+		recordTeardownReport(ctx, newApp, report)
+
+		markErr := req.MarkSourceUnavailable(ctx, err, newApp)
+		if markErr != nil {
+			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, markErr)
 		}
 
-		err := req.MarkSourceUnavailable(ctx, err, newApp)
-		if err != nil {
-			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, err)
+		if newApp != nil {
+			newApp.FinishOperation(ctx, superkey.PhaseRolledBack, err)
 		}
 
 		unsuccessfulResourcesCreationCounter.Inc()
-		return
+		return err
 	}
 
 	l.LogWithContext(ctx).Debug("Finished forging request")
 
-	err = newApp.CreateInSourcesAPI(ctx)
+	return registerForgedResources(ctx, newApp, false)
+}
+
+// registerForgedResources registers the forged resources in Sources, and tears them down when the registration fails.
+// When the Sources API is unavailable the resources are kept instead, so that the redelivered request registers them
+// rather than forging new ones. A resumed registration might have gone through before the API became unavailable, so
+// it is only repeated when nothing is registered yet.
+// returns: the error that made the registration fail, if any.
+func registerForgedResources(ctx context.Context, newApp *superkey.ForgedApplication, resumed bool) error {
+	newApp.SetPhase(ctx, superkey.PhaseRegistering)
+
+	var registered bool
+	var err error
+	if resumed {
+		registered, err = newApp.IsRegistered(ctx)
+	}
+
+	if err == nil && !registered {
+		err = newApp.CreateInSourcesAPI(ctx)
+	}
+
+	if isRetryable(err) {
+		l.LogWithContext(ctx).Warnf(`Unable to register the resources in Sources, keeping them until the request gets delivered again: %s`, err)
+		pendingRegistrations.put(newApp)
+		return err
+	}
+
 	if err != nil {
 		l.LogWithContext(ctx).Errorf(`Error while creating or updating the resources in Sources: %s`, err)
-		provider.TearDown(ctx, newApp)
+		report := provider.TearDown(ctx, newApp)
+		recordTeardownReport(ctx, newApp, report)
+
+		markErr := newApp.Request.MarkSourceUnavailable(ctx, err, newApp)
+		if markErr != nil {
+			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, markErr)
+		}
+
+		newApp.FinishOperation(ctx, superkey.PhaseRolledBack, err)
 		unsuccessfulResourcesCreationCounter.Inc()
-		return
+		return err
 	}
 
+	newApp.FinishOperation(ctx, superkey.PhaseCompleted, nil)
 	successfulResourcesCreationCounter.Inc()
+
+	return nil
 }
 
-func destroyResources(ctx context.Context, req *superkey.DestroyRequest) {
-	l.LogWithContext(ctx).Debugf(`Unforging request "%v"`, req)
+// updateResources reconciles the resources of the request, and stores the reconciled state in Sources.
+// returns: the error that made the update fail, if any.
+func updateResources(ctx context.Context, req *superkey.UpdateRequest) error {
+	l.LogWithContext(ctx).WithField("request", req).Debug("Reconciling request")
+
+	updatedApp, err := provider.Update(ctx, req)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Error while reconciling the application's resources: %s`, err)
+
+		// Store how far the reconciliation got, so that the application's extra keeps pointing to the resources
+		// that actually exist.
+		markErr := updatedApp.Request.MarkUpdateFailed(ctx, err, updatedApp)
+		if markErr != nil {
+			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, markErr)
+		}
+
+		updatedApp.FinishOperation(ctx, superkey.PhaseFailed, err)
+		unsuccessfulResourcesUpdateCounter.Inc()
+		return err
+	}
+
+	l.LogWithContext(ctx).Debug("Finished reconciling request")
+
+	updatedApp.SetPhase(ctx, superkey.PhaseRegistering)
+
+	err = updatedApp.UpdateInSourcesAPI(ctx)
+	if isRetryable(err) {
+		// Reconciling again is safe, so the redelivered request simply starts over.
+		l.LogWithContext(ctx).Warnf(`Unable to store the reconciled resources in Sources, waiting for the request to be delivered again: %s`, err)
+		updatedApp.FinishOperation(ctx, superkey.PhaseFailed, err)
+		return err
+	}
+
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Error while storing the reconciled resources in Sources: %s`, err)
+		updatedApp.FinishOperation(ctx, superkey.PhaseFailed, err)
+		unsuccessfulResourcesUpdateCounter.Inc()
+		return err
+	}
 
-	errors := provider.TearDown(ctx, superkey.ReconstructForgedApplication(req))
-	if len(errors) != 0 {
-		for _, err := range errors {
-			l.LogWithContext(ctx).Errorf(`Error during teardown: %s"`, err)
+	updatedApp.FinishOperation(ctx, superkey.PhaseCompleted, nil)
+	successfulResourcesUpdateCounter.Inc()
+
+	return nil
+}
+
+// destroyResources tears down the resources of the request, and reports the results back to Sources.
+// returns: the teardown report, or an error when the teardown could not be started.
+func destroyResources(ctx context.Context, req *superkey.DestroyRequest) (*superkey.TeardownReport, error) {
+	l.LogWithContext(ctx).WithField("request", req).Debug("Unforging request")
+
+	// Sources does not send the application's ID along with the destroy requests, so it gets looked up in the source
+	// for the teardown results to be stored in the application. When the application is gone, they get published.
+	if req.ApplicationID == "" && req.SourceID != "" && req.GUID != "" {
+		appId, err := superkey.FindApplicationByGUID(ctx, req.IdentityHeader, req.OrgIdHeader, req.SourceID, req.GUID)
+		switch {
+		case errors.Is(err, sources.ErrNotFound):
+			l.LogWithContext(ctx).Debug("The source no longer exists in Sources")
+		case err != nil:
+			l.LogWithContext(ctx).Warnf("Unable to look the application up in Sources: %s", err)
+		case appId != "":
+			req.ApplicationID = appId
+			ctx = l.WithApplicationId(ctx, appId)
 		}
+	}
+
+	forgedApp := superkey.ReconstructForgedApplication(req)
+
+	err := forgedApp.BeginOperation(ctx, "destroy_application")
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to tear down the resources: %s`, err)
+		unsuccessfulResourcesDeletionCounter.Inc()
+		return nil, err
+	}
 
+	report := provider.TearDown(ctx, forgedApp)
+	recordTeardownReport(ctx, forgedApp, report)
+	reportTeardownResults(ctx, forgedApp, report)
+
+	if !report.Succeeded() {
+		forgedApp.FinishOperation(ctx, superkey.PhaseFailed, report.Err())
 		unsuccessfulResourcesDeletionCounter.Inc()
 	} else {
+		forgedApp.FinishOperation(ctx, superkey.PhaseCompleted, nil)
 		successfulResourcesDeletionCounter.Inc()
 	}
 
 	l.LogWithContext(ctx).Info("Finished destroying resources")
+
+	return report, nil
+}
+
+// recordTeardownReport logs the resources that could not be torn down, updates the teardown metrics and queues the
+// unfinished resources for retrying.
+func recordTeardownReport(ctx context.Context, f *superkey.ForgedApplication, report *superkey.TeardownReport) {
+	for _, result := range report.Results {
+		teardownResourcesCounter.WithLabelValues(result.Step, result.Status, result.ErrorClass).Inc()
+
+		switch result.Status {
+		case superkey.TeardownFailed:
+			l.LogWithContext(ctx).Errorf(`Unable to tear down the "%s" resource "%s" (%s error): %s`, result.Step, result.Resource, result.ErrorClass, result.Err)
+		case superkey.TeardownSkipped:
+			l.LogWithContext(ctx).Errorf(`Skipped the teardown of the "%s" resource "%s": %s`, result.Step, result.Resource, result.Err)
+		}
+	}
+
+	if len(report.Results) != 0 {
+		l.LogWithContext(ctx).Infof("Teardown finished: %s", report.Summary())
+	}
+
+	queueFailedTeardowns(ctx, f, report)
+}
+
+// reportTeardownResults stores which resources were removed and which remain in the application in Sources. When the
+// application is unknown or does not exist anymore, the results get published as an event instead.
+func reportTeardownResults(ctx context.Context, f *superkey.ForgedApplication, report *superkey.TeardownReport) {
+	summary := f.TeardownSummary(report)
+
+	if f.Request.ApplicationID != "" {
+		err := f.StoreTeardownInSourcesAPI(ctx, summary)
+		if err == nil {
+			return
+		}
+
+		if errors.Is(err, sources.ErrNotFound) {
+			l.LogWithContext(ctx).Info("The application no longer exists in Sources, publishing the teardown results instead")
+		} else {
+			l.LogWithContext(ctx).Errorf("Unable to store the teardown results in Sources, publishing them instead: %s", err)
+		}
+	}
+
+	if teardownEventsWriter == nil {
+		l.LogWithContext(ctx).WithField("teardown_summary", summary).Warn("No teardown events topic configured, the teardown results are only logged")
+		return
+	}
+
+	err := messaging.Publish(ctx, teardownEventsWriter, f.GUID, "superkey_teardown_results", summary)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf("Unable to publish the teardown results: %s", err)
+		return
+	}
+
+	l.LogWithContext(ctx).Info("Teardown results published")
 }
 
 func initMetrics() {
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
+
+		// The operation status API is served next to the metrics, as long as it is protected by a PSK.
+		if conf.StatusApiPSK != "" {
+			status.RegisterHandlers(http.DefaultServeMux, operationTracker, conf.StatusApiPSK)
+		}
+
 		err := http.ListenAndServe(fmt.Sprintf(":%d", conf.MetricsPort), nil)
 		if err != nil {
 			l.Log.Errorf("Metrics init error: %s", err)
@@ -0,0 +1,148 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/sources-api-go/kafka"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// redeliveryDelay is how long a message that failed for a transient reason waits before it gets delivered again.
+const redeliveryDelay = 5 * time.Second
+
+// Consume fetches messages from the given reader and hands them to the lane's workers. Unlike the shared
+// "kafka.Consume" helper, which commits the offsets as soon as the message is read, the offsets are explicitly
+// committed only after the handler has finished processing the message. That way a crash in the middle of processing
+// a message makes Kafka redeliver it instead of silently losing it.
+//
+// Messages are dispatched to the workers by partition, so that the messages of a partition are processed and
+// committed in order even when the lane has more than one worker.
+//
+// The function blocks until the given context is canceled or the reader is closed.
+func Consume(ctx context.Context, reader *kafka.Reader, lane LaneOptions, handler Handler) {
+	concurrency := lane.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	gate := lane.Gate
+	if gate == nil {
+		gate = NewPriorityGate()
+	}
+
+	var wg sync.WaitGroup
+	workers := make([]chan kafkago.Message, concurrency)
+	for i := range workers {
+		workers[i] = make(chan kafkago.Message)
+
+		wg.Add(1)
+		go func(messages <-chan kafkago.Message) {
+			defer wg.Done()
+
+			for kafkaMsg := range messages {
+				gate.Enter(lane.Priority)
+				processAndCommit(ctx, reader, lane, kafkaMsg, handler)
+				gate.Leave(lane.Priority)
+			}
+		}(workers[i])
+	}
+
+	defer func() {
+		for _, worker := range workers {
+			close(worker)
+		}
+
+		wg.Wait()
+	}()
+
+	for {
+		// Hold off fetching while the lane is paused. The messages are left in the topic, so nothing gets lost.
+		if lane.Ready != nil {
+			err := lane.Ready(ctx)
+			if err != nil {
+				l.Log.Infof("Stopping consumption while paused: %s", err)
+				return
+			}
+		}
+
+		kafkaMsg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				l.Log.Info("Consumer context canceled, stopping consumption")
+			} else {
+				l.Log.Warnf("Stopping consumption after failing to fetch a message: %s", err)
+			}
+
+			return
+		}
+
+		// The message counts as pending from now on, so that the lower priority lanes do not start new work while
+		// it waits for a worker.
+		gate.Queue(lane.Priority)
+		workers[kafkaMsg.Partition%concurrency] <- kafkaMsg
+	}
+}
+
+// processAndCommit hands the message to the handler and commits its offset afterwards. When the handler fails for a
+// transient reason the message is delivered again once the lane is ready, without committing anything in between, so
+// that the message is neither lost nor overtaken by the next messages of its partition. When the consumer is stopped
+// in the meantime the offset is left uncommitted, and Kafka delivers the message again after the restart.
+func processAndCommit(ctx context.Context, reader *kafka.Reader, lane LaneOptions, kafkaMsg kafkago.Message, handler Handler) {
+	msg := Message{
+		Topic:     kafkaMsg.Topic,
+		Partition: kafkaMsg.Partition,
+		Offset:    kafkaMsg.Offset,
+		Key:       kafkaMsg.Key,
+		Value:     kafkaMsg.Value,
+		Headers:   kafkaMsg.Headers,
+	}
+
+	logFields := logrus.Fields{"topic": kafkaMsg.Topic, "partition": kafkaMsg.Partition, "offset": kafkaMsg.Offset}
+
+	for {
+		err := handler(msg)
+		if err == nil {
+			break
+		}
+
+		l.Log.WithFields(logFields).Warnf("Unable to process the message, delivering it again in %s: %s", redeliveryDelay, err)
+
+		if !waitForRedelivery(ctx, lane) {
+			l.Log.WithFields(logFields).Info("Consumer stopped before the message could be delivered again, leaving its offset uncommitted")
+			return
+		}
+	}
+
+	// The message has been processed at this point, so we still want to commit it if we are shutting down.
+	err := reader.CommitMessages(context.WithoutCancel(ctx), kafkaMsg)
+	if err != nil {
+		l.Log.WithFields(logFields).Errorf("Unable to commit the message's offset: %s", err)
+		return
+	}
+
+	l.Log.WithFields(logFields).Debug("Message offset committed")
+}
+
+// waitForRedelivery waits before a message gets delivered again, until the lane is ready.
+// returns: false when the consumer got stopped in the meantime.
+func waitForRedelivery(ctx context.Context, lane LaneOptions) bool {
+	timer := time.NewTimer(redeliveryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return false
+	}
+
+	if lane.Ready != nil && lane.Ready(ctx) != nil {
+		return false
+	}
+
+	return ctx.Err() == nil
+}
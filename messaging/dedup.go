@@ -0,0 +1,190 @@
+package messaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// processedBucket is the bucket the processed messages are stored in, keyed by their dedup key, along with the time
+// they were processed at.
+var processedBucket = []byte("processed_messages")
+
+// dedupPurgeInterval is how often the expired processed messages get purged.
+const dedupPurgeInterval = 5 * time.Minute
+
+// NewDedupStore returns a processed messages store whose entries expire after the given TTL. The store only lives in
+// memory, so it only recognizes the redeliveries that happen within the same process.
+func NewDedupStore(ttl time.Duration) *DedupStore {
+	return &DedupStore{
+		ttl:       ttl,
+		processed: make(map[string]time.Time),
+	}
+}
+
+// OpenDedupStore opens, or creates, a processed messages store backed by the database file at the given path, so that
+// the processed messages are still recognized after the crash or the rebalance that made Kafka redeliver them.
+func OpenDedupStore(path string, ttl time.Duration) (*DedupStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf(`unable to open the processed messages file "%s": %w`, path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(processedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf(`unable to initialize the processed messages file "%s": %w`, path, err)
+	}
+
+	return &DedupStore{ttl: ttl, db: db}, nil
+}
+
+// DedupKey builds the key used to identify a processed request from its event type, the message key, the application
+// it targets and a hash of its payload. The target is the application's identifier, or the GUID of its resources for
+// the requests that do not carry it. Nothing depends on the message's position in the topic, so that a request Sources
+// publishes again is recognized too, while a new request for the same application, such as a second update, is not
+// mistaken for a redelivery.
+func DedupKey(eventType string, msg Message, target string) string {
+	sum := sha256.Sum256(msg.Value)
+
+	return fmt.Sprintf("%s/%s/%s/%s", eventType, msg.Key, target, hex.EncodeToString(sum[:]))
+}
+
+// Close closes the store's database file, if any.
+func (d *DedupStore) Close() error {
+	if d.db == nil {
+		return nil
+	}
+
+	return d.db.Close()
+}
+
+// IsProcessed returns true when the given key was marked as processed and the entry has not expired yet.
+func (d *DedupStore) IsProcessed(key string) bool {
+	if d.db != nil {
+		var processedAt time.Time
+		err := d.db.View(func(tx *bolt.Tx) error {
+			raw := tx.Bucket(processedBucket).Get([]byte(key))
+			if len(raw) == 8 {
+				processedAt = time.Unix(0, int64(binary.BigEndian.Uint64(raw)))
+			}
+
+			return nil
+		})
+		if err != nil {
+			l.Log.Errorf("Unable to read the processed messages file: %s", err)
+			return false
+		}
+
+		return !processedAt.IsZero() && time.Since(processedAt) <= d.ttl
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	processedAt, ok := d.processed[key]
+	if !ok {
+		return false
+	}
+
+	if time.Since(processedAt) > d.ttl {
+		delete(d.processed, key)
+		return false
+	}
+
+	return true
+}
+
+// MarkProcessed records the given key as processed. The expired entries are left to "PurgeExpired".
+func (d *DedupStore) MarkProcessed(key string) {
+	now := time.Now()
+
+	if d.db != nil {
+		err := d.db.Update(func(tx *bolt.Tx) error {
+			value := make([]byte, 8)
+			binary.BigEndian.PutUint64(value, uint64(now.UnixNano()))
+
+			return tx.Bucket(processedBucket).Put([]byte(key), value)
+		})
+		if err != nil {
+			l.Log.Errorf("Unable to record the processed message: %s", err)
+		}
+
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.processed[key] = now
+}
+
+// PurgeExpired removes the entries whose TTL has expired.
+func (d *DedupStore) PurgeExpired() error {
+	now := time.Now()
+
+	if d.db != nil {
+		return d.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(processedBucket)
+
+			var expired [][]byte
+			err := bucket.ForEach(func(k, v []byte) error {
+				if len(v) != 8 || now.Sub(time.Unix(0, int64(binary.BigEndian.Uint64(v)))) > d.ttl {
+					expired = append(expired, k)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, k := range expired {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, processedAt := range d.processed {
+		if now.Sub(processedAt) > d.ttl {
+			delete(d.processed, k)
+		}
+	}
+
+	return nil
+}
+
+// PurgePeriodically removes the expired entries every so often, until the given context is done, so that recording a
+// processed message does not cost a scan of the whole store.
+func (d *DedupStore) PurgePeriodically(ctx context.Context) {
+	ticker := time.NewTicker(dedupPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.PurgeExpired()
+			if err != nil {
+				l.Log.Errorf("Unable to purge the expired processed messages: %s", err)
+			}
+		}
+	}
+}
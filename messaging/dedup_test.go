@@ -0,0 +1,47 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDedupKey tests that a request published again at another offset gets the same key, while another request for
+// the same application does not.
+func TestDedupKey(t *testing.T) {
+	msg := Message{Key: []byte("key"), Value: []byte(`{"application_id": "1"}`), Topic: "topic", Partition: 1, Offset: 10}
+
+	republished := msg
+	republished.Partition = 2
+	republished.Offset = 42
+
+	if DedupKey("create_application", msg, "1") != DedupKey("create_application", republished, "1") {
+		t.Error("want the republished request to get the same key")
+	}
+
+	other := msg
+	other.Value = []byte(`{"application_id": "1", "extra": {}}`)
+
+	if DedupKey("update_application", msg, "1") == DedupKey("update_application", other, "1") {
+		t.Error("want another request for the same application to get another key")
+	}
+}
+
+// TestDedupStorePurgeExpired tests that only the expired entries get purged.
+func TestDedupStorePurgeExpired(t *testing.T) {
+	store := NewDedupStore(time.Minute)
+	store.MarkProcessed("fresh")
+	store.processed["expired"] = time.Now().Add(-time.Hour)
+
+	err := store.PurgeExpired()
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if _, ok := store.processed["expired"]; ok {
+		t.Error("want the expired entry to be purged")
+	}
+
+	if !store.IsProcessed("fresh") {
+		t.Error("want the fresh entry to be kept")
+	}
+}
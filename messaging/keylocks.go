@@ -0,0 +1,32 @@
+package messaging
+
+// NewKeyLocks returns a set of locks that serializes the work done for the same key.
+func NewKeyLocks() *KeyLocks {
+	return &KeyLocks{locks: make(map[string]*keyLock)}
+}
+
+// Lock blocks until no other work holds the lock of the given key, and returns the function that releases it. The
+// locks are dropped once nobody holds or waits for them, so that the set does not grow with every key seen.
+func (k *KeyLocks) Lock(key string) func() {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		k.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
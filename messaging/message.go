@@ -0,0 +1,21 @@
+package messaging
+
+import (
+	"encoding/json"
+)
+
+// GetHeader returns the value of the given header, or an empty string if the message does not carry it.
+func (m *Message) GetHeader(key string) string {
+	for _, header := range m.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+
+	return ""
+}
+
+// ParseTo unmarshals the message's value into the given target.
+func (m *Message) ParseTo(target interface{}) error {
+	return json.Unmarshal(m.Value, target)
+}
@@ -0,0 +1,59 @@
+package messaging
+
+import (
+	"sync"
+)
+
+// NewPriorityGate returns a gate that lets the lanes with the highest priority process their messages first.
+func NewPriorityGate() *PriorityGate {
+	g := &PriorityGate{pending: make(map[int]int)}
+	g.cond = sync.NewCond(&g.mu)
+
+	return g
+}
+
+// Queue registers a message of the given priority as pending as soon as it has been fetched, so that the lanes with a
+// lower priority hold off even while the message waits for a worker of its own lane.
+func (g *PriorityGate) Queue(priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pending[priority]++
+}
+
+// Enter blocks while a lane with a higher priority than the given one has messages pending, either waiting for a
+// worker or being processed. The messages that are already being processed are never preempted.
+func (g *PriorityGate) Enter(priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for g.higherPriorityPending(priority) {
+		g.cond.Wait()
+	}
+}
+
+// Leave unregisters a pending message of the given priority once it has been processed, waking up the lanes that
+// were waiting for it to finish.
+func (g *PriorityGate) Leave(priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pending[priority]--
+	if g.pending[priority] <= 0 {
+		delete(g.pending, priority)
+	}
+
+	g.cond.Broadcast()
+}
+
+// higherPriorityPending returns true when a message with a priority higher than the given one is pending. The caller
+// must hold the gate's lock.
+func (g *PriorityGate) higherPriorityPending(priority int) bool {
+	for p, count := range g.pending {
+		if p > priority && count > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,29 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Publish writes a message to the writer's topic with the given key, the given event type in the "event_type" header
+// and the JSON encoded value as its body.
+func Publish(ctx context.Context, writer *kafkago.Writer, key, eventType string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf(`unable to marshal the "%s" event: %w`, eventType, err)
+	}
+
+	err = writer.WriteMessages(ctx, kafkago.Message{
+		Key:     []byte(key),
+		Value:   body,
+		Headers: []kafkago.Header{{Key: "event_type", Value: []byte(eventType)}},
+	})
+	if err != nil {
+		return fmt.Errorf(`unable to publish the "%s" event: %w`, eventType, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package messaging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/RedHatInsights/sources-api-go/kafka"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// maxReplayLineSize is the size of the biggest message a FileSource is able to replay.
+const maxReplayLineSize = 10 * 1024 * 1024
+
+// NewKafkaSource returns a message source that consumes the given lanes.
+func NewKafkaSource(lanes []KafkaLane) *KafkaSource {
+	return &KafkaSource{lanes: lanes}
+}
+
+// Run consumes every lane until the given context is canceled or the readers are closed.
+func (k *KafkaSource) Run(ctx context.Context, handler Handler) error {
+	var wg sync.WaitGroup
+	for _, lane := range k.lanes {
+		wg.Add(1)
+		go func(lane KafkaLane) {
+			defer wg.Done()
+
+			Consume(ctx, lane.Reader, lane.Options, handler)
+		}(lane)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// Close closes the readers of every lane.
+func (k *KafkaSource) Close() error {
+	for _, lane := range k.lanes {
+		kafka.CloseReader(lane.Reader, fmt.Sprintf("superkey reader for topic %s", lane.Topic))
+	}
+
+	return nil
+}
+
+// NewFileSource returns a message source that replays the messages recorded in the given file, or in the standard
+// input when the path is "-".
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Run hands the recorded messages to the handler one after the other, in the order they were recorded. The lines
+// that cannot be parsed are logged and skipped.
+func (s *FileSource) Run(ctx context.Context, handler Handler) error {
+	var input io.Reader = os.Stdin
+	if s.path != "-" {
+		file, err := os.Open(s.path)
+		if err != nil {
+			return fmt.Errorf("unable to open the replay file: %w", err)
+		}
+		defer file.Close()
+
+		input = file
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReplayLineSize)
+
+	line := 0
+	replayed := 0
+	for scanner.Scan() {
+		line++
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record := ReplayRecord{}
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			l.Log.Errorf("Skipping line %d of the replay file because it is not a valid record: %s", line, err)
+			continue
+		}
+
+		msg, err := record.Message()
+		if err != nil {
+			l.Log.Errorf("Skipping line %d of the replay file: %s", line, err)
+			continue
+		}
+
+		// There is nobody to redeliver the replayed messages, so the transient failures are just reported.
+		err = handler(msg)
+		if err != nil {
+			l.Log.Errorf("Unable to replay line %d of the replay file: %s", line, err)
+			continue
+		}
+		replayed++
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return fmt.Errorf("unable to read the replay file: %w", err)
+	}
+
+	l.Log.Infof("Replayed %d messages", replayed)
+
+	return nil
+}
+
+// Close does nothing, since the file is closed as soon as it has been replayed.
+func (s *FileSource) Close() error {
+	return nil
+}
+
+// Message returns the message the record holds.
+func (r *ReplayRecord) Message() (Message, error) {
+	msg := Message{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Key:       []byte(r.Key),
+		Value:     r.Value,
+	}
+
+	// The value is a string when the request was dumped as the message's raw payload.
+	var value string
+	if json.Unmarshal(r.Value, &value) == nil {
+		msg.Value = []byte(value)
+	}
+
+	if len(msg.Value) == 0 {
+		return Message{}, errors.New("the record does not have a value")
+	}
+
+	// Sort the headers so that the messages are replayed the same way every time.
+	keys := make([]string, 0, len(r.Headers))
+	for key := range r.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		msg.Headers = append(msg.Headers, kafkago.Header{Key: key, Value: []byte(r.Headers[key])})
+	}
+
+	return msg, nil
+}
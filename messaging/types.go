@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/sources-api-go/kafka"
+	kafkago "github.com/segmentio/kafka-go"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Message represents a single superkey request message, decoupled from the client that consumed it so that the
+// worker can process it regardless of where it came from.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []kafkago.Header
+}
+
+// Handler processes a single message. The message's offset only gets committed once the handler returns, which means
+// that the handler is responsible for dealing with any processing errors. The handler only returns an error when the
+// message could not be processed for a transient reason, in which case its offset is not committed and it gets
+// delivered again once the lane is ready.
+type Handler func(msg Message) error
+
+// MessageSource delivers the superkey request messages to a handler, regardless of where they come from.
+type MessageSource interface {
+	// Run hands the messages to the handler, and blocks until the source runs out of messages or the given context is
+	// canceled.
+	Run(ctx context.Context, handler Handler) error
+	// Close releases the resources held by the source.
+	Close() error
+}
+
+// KafkaLane is a lane of a KafkaSource: a reader for one of the topics, along with how its messages get consumed.
+type KafkaLane struct {
+	Topic   string
+	Reader  *kafka.Reader
+	Options LaneOptions
+}
+
+// KafkaSource is the message source that consumes the messages from the Kafka lanes.
+type KafkaSource struct {
+	lanes []KafkaLane
+}
+
+// FileSource is the message source that replays the messages recorded in a JSONL file, one message per line. It is
+// meant to reproduce issues with messages dumped from the topics.
+type FileSource struct {
+	path string
+}
+
+// ReplayRecord is a message as recorded in the files replayed by a FileSource. The value can either be the request
+// itself as a JSON object, or a string holding it.
+type ReplayRecord struct {
+	Topic     string            `json:"topic"`
+	Partition int               `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       string            `json:"key"`
+	Headers   map[string]string `json:"headers"`
+	Value     json.RawMessage   `json:"value"`
+}
+
+// LaneOptions configures how the messages of a single lane get consumed.
+type LaneOptions struct {
+	// Priority of the lane. Lanes with a higher priority take precedence over the ones with a lower priority.
+	Priority int
+	// Concurrency is the number of workers processing the lane's messages. Messages from the same partition are
+	// always processed by the same worker, so that their offsets get committed in order.
+	Concurrency int
+	// Gate is the priority gate shared by all the lanes.
+	Gate *PriorityGate
+	// Ready blocks until the lane is allowed to fetch the next message, which lets the consumer pause while the
+	// messages are doomed to fail. Consumption stops when it returns an error.
+	Ready func(ctx context.Context) error
+}
+
+// PriorityGate coordinates the lanes so that the ones with a lower priority hold off while the ones with a higher
+// priority have messages pending, from the moment they are fetched until they have been processed.
+type PriorityGate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[int]int
+}
+
+// KeyLocks holds a lock for every key that has work in flight, e.g. for every application, so that the messages and the
+// retries of the same application are not processed at the same time.
+type KeyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock of a single key, along with the number of goroutines holding or waiting for it.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// DedupStore keeps track of the messages that were already processed, so that redeliveries can be recognized and
+// skipped. Entries expire after the configured TTL. The entries are kept in the database file when the store was
+// opened from one, and in memory otherwise.
+type DedupStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	processed map[string]time.Time
+	db        *bolt.DB
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// registrationsInWait holds the forged applications whose registration in Sources could not be attempted because the
+// Sources API was unavailable, by application, until their requests get delivered again.
+type registrationsInWait struct {
+	mu   sync.Mutex
+	apps map[string]*superkey.ForgedApplication
+}
+
+// newPendingRegistrations returns an empty set of pending registrations.
+func newPendingRegistrations() *registrationsInWait {
+	return &registrationsInWait{apps: make(map[string]*superkey.ForgedApplication)}
+}
+
+// put keeps the forged application until its request gets delivered again.
+func (r *registrationsInWait) put(f *superkey.ForgedApplication) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apps[f.Request.ApplicationID] = f
+}
+
+// take removes and returns the forged application pending for the given application, if any.
+func (r *registrationsInWait) take(applicationId string) *superkey.ForgedApplication {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.apps[applicationId]
+	delete(r.apps, applicationId)
+
+	return f
+}
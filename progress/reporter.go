@@ -0,0 +1,270 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// extraKey is the key of the application's extra the progress is stored in.
+const extraKey = "_superkey_progress"
+
+// The statuses of the steps.
+const (
+	StepPending    = "pending"
+	StepInProgress = "in_progress"
+	StepCompleted  = "completed"
+	StepRemoved    = "removed"
+	StepFailed     = "failed"
+)
+
+// The availability statuses the applications get while their resources are being forged, and once they were
+// registered.
+const (
+	availabilityInProgress = "in_progress"
+	availabilityAvailable  = "available"
+)
+
+// stepNouns are the human-readable names of the resources each step creates.
+var stepNouns = map[string]string{
+	"s3":          "bucket",
+	"cost_report": "cost and usage report",
+	"policy":      "policy",
+	"role":        "role",
+}
+
+// actionVerbs are the human-readable names of the actions performed on the steps.
+var actionVerbs = map[string]string{
+	superkey.ActionCreate: "creating",
+	superkey.ActionUpdate: "updating",
+	superkey.ActionDelete: "removing",
+}
+
+// NewReporter returns a reporter without any operation in progress.
+func NewReporter() *Reporter {
+	return &Reporter{operations: make(map[string]*Progress)}
+}
+
+// Begin starts reporting the progress of the create operations, listing every requested step as pending. The other
+// operations are not reported.
+func (r *Reporter) Begin(f *superkey.ForgedApplication, eventType string) error {
+	if eventType != "create_application" || f.Request.ApplicationID == "" {
+		return nil
+	}
+
+	p := &Progress{
+		GUID:    f.GUID,
+		Phase:   superkey.PhaseForging,
+		Message: "forging the resources",
+	}
+
+	for _, step := range f.Request.SuperKeySteps {
+		p.Steps = append(p.Steps, StepProgress{Step: step.Name, Status: StepPending, Message: describe(superkey.ActionCreate, step.Name)})
+	}
+
+	r.mu.Lock()
+	r.operations[f.GUID] = p
+	r.mu.Unlock()
+
+	r.report(f, availabilityInProgress)
+
+	return nil
+}
+
+// RecordIntent records the step that is about to be acted on. The steps are not reported one by one, so that forging
+// the resources does not cost a request to Sources per step: they get reported along with the next phase change.
+func (r *Reporter) RecordIntent(f *superkey.ForgedApplication, action, step string, _ map[string]string) error {
+	r.update(f, func(p *Progress) {
+		p.Message = describe(action, step)
+
+		s := p.step(step)
+		s.Status = StepInProgress
+		s.Message = p.Message
+		s.Error = ""
+	})
+
+	return nil
+}
+
+// RecordOutcome records the outcome of the action performed on the step, which gets reported along with the next
+// phase change.
+func (r *Reporter) RecordOutcome(f *superkey.ForgedApplication, action, step string, _ map[string]string, stepErr error) error {
+	r.update(f, func(p *Progress) {
+		s := p.step(step)
+
+		switch {
+		case stepErr != nil:
+			s.Status = StepFailed
+			s.Error = stepErr.Error()
+			p.Message = fmt.Sprintf("failed while %s", s.Message)
+		case action == superkey.ActionDelete:
+			s.Status = StepRemoved
+		default:
+			s.Status = StepCompleted
+		}
+	})
+
+	return nil
+}
+
+// SetPhase reports the phase the operation moved to, along with the steps recorded since the last report. Setting the
+// phase the operation is already in reports nothing.
+func (r *Reporter) SetPhase(f *superkey.ForgedApplication, phase string) error {
+	changed := false
+	r.update(f, func(p *Progress) {
+		if p.Phase == phase {
+			return
+		}
+
+		changed = true
+		p.Phase = phase
+		if phase == superkey.PhaseRegistering {
+			p.Message = "registering the resources in Sources"
+		}
+	})
+
+	if changed {
+		r.report(f, "")
+	}
+
+	return nil
+}
+
+// Finish reports the final state of the operation, along with the summary of every step, and stops reporting it. The
+// applications of the completed operations are marked as available, after which the availability check requested by
+// the registration reports its own result. The applications of the failed operations are marked as unavailable by
+// the worker along with the error, so only the summary gets reported for them.
+func (r *Reporter) Finish(f *superkey.ForgedApplication, phase string, opErr error) error {
+	ok := r.update(f, func(p *Progress) {
+		p.Phase = phase
+
+		switch {
+		case phase == superkey.PhaseCompleted:
+			p.Message = "the resources were created"
+		case phase == superkey.PhaseRolledBack:
+			p.Message = "the resources were rolled back after a failure"
+		default:
+			p.Message = "the operation failed"
+		}
+
+		if opErr != nil {
+			p.Error = opErr.Error()
+		}
+	})
+
+	if ok {
+		availabilityStatus := ""
+		if phase == superkey.PhaseCompleted {
+			availabilityStatus = availabilityAvailable
+		}
+
+		r.report(f, availabilityStatus)
+	}
+
+	r.mu.Lock()
+	delete(r.operations, f.GUID)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// update applies the given change to the progress of the operation.
+// returns: false when the operation's progress is not being reported.
+func (r *Reporter) update(f *superkey.ForgedApplication, change func(p *Progress)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.operations[f.GUID]
+	if !ok {
+		return false
+	}
+
+	change(p)
+	p.UpdatedAt = time.Now()
+
+	return true
+}
+
+// report stores the current progress of the operation in the application's extra, and sets the given availability
+// status unless it is empty. The status goes through the Sources status topic when it is enabled, like every other
+// availability status update. Failing to report the progress does not stop the operation, so the failures only get
+// logged.
+func (r *Reporter) report(f *superkey.ForgedApplication, availabilityStatus string) {
+	r.mu.Lock()
+	p, ok := r.operations[f.GUID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+
+	snapshot := *p
+	snapshot.Steps = append([]StepProgress(nil), p.Steps...)
+	r.mu.Unlock()
+
+	ctx := l.WithTenantId(context.Background(), f.Request.TenantID)
+	ctx = l.WithApplicationId(ctx, f.Request.ApplicationID)
+
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		l.LogWithContext(ctx).Errorf("Unable to report the progress of the operation to Sources: %s", err)
+		return
+	}
+
+	extra := map[string]interface{}{extraKey: snapshot}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
+
+	if availabilityStatus != "" {
+		err = sourcesClient.UpdateApplicationAvailability(ctx, authData, f.Request.ApplicationID, availabilityStatus, "", extra)
+	} else {
+		err = sourcesClient.ResetApplicationExtra(ctx, authData, f.Request.ApplicationID, extra)
+	}
+
+	if err != nil {
+		l.LogWithContext(ctx).Errorf("Unable to report the progress of the operation to Sources: %s", err)
+		return
+	}
+
+	l.LogWithContext(ctx).Debugf(`Progress reported to Sources: %s`, snapshot.Message)
+}
+
+// step returns the progress of the given step, adding it when the step was not requested, as it happens with the
+// teardowns of the steps that are no longer requested.
+func (p *Progress) step(name string) *StepProgress {
+	for i := range p.Steps {
+		if p.Steps[i].Step == name {
+			return &p.Steps[i]
+		}
+	}
+
+	p.Steps = append(p.Steps, StepProgress{Step: name, Status: StepPending})
+
+	return &p.Steps[len(p.Steps)-1]
+}
+
+// describe returns the human-readable description of the given action performed on the step, such as
+// "creating bucket".
+func describe(action, step string) string {
+	switch {
+	case step == "bind_role" && action == superkey.ActionDelete:
+		return "unbinding role"
+	case step == "bind_role":
+		return "binding role"
+	case step == "s3" && action == superkey.ActionUpdate:
+		return "attaching bucket policy"
+	}
+
+	noun, ok := stepNouns[step]
+	if !ok {
+		noun = step
+	}
+
+	return fmt.Sprintf("%s %s", actionVerbs[action], noun)
+}
@@ -0,0 +1,33 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Reporter reports the progress of the create operations to their applications in Sources, so that the users can
+// follow which resource is being forged, and see which step failed. It records the operations by being set as one of
+// the superkey journals.
+type Reporter struct {
+	mu         sync.Mutex
+	operations map[string]*Progress
+}
+
+// Progress is the progress of a create operation, as stored in the "_superkey_progress" key of the application's
+// extra.
+type Progress struct {
+	GUID      string         `json:"guid"`
+	Phase     string         `json:"phase"`
+	Message   string         `json:"message"`
+	Steps     []StepProgress `json:"steps"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// StepProgress is the progress of a single superkey step.
+type StepProgress struct {
+	Step    string `json:"step"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
@@ -3,10 +3,13 @@ package provider
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path"
+	"reflect"
 	"strings"
 
 	"github.com/redhatinsights/sources-superkey-worker/amazon"
@@ -34,116 +37,600 @@ func (a *AmazonProvider) ForgeApplication(ctx context.Context, request *superkey
 		Client:         a,
 		GUID:           guid,
 	}
+	a.setAuditScope(f)
 
+	err = f.BeginOperation(ctx, "create_application")
+	if err != nil {
+		return f, err
+	}
+
+	for _, step := range request.SuperKeySteps {
+		err := a.forgeStep(ctx, f, step)
+		if err != nil {
+			return f, err
+		}
+	}
+
+	createAmazonPayload(f)
+
+	return f, nil
+}
+
+// setAuditScope makes the client record its mutating calls for the forged application in the audit log.
+func (a *AmazonProvider) setAuditScope(f *superkey.ForgedApplication) {
+	a.Client.SetAuditScope(f.Request.OrgIdHeader, f.Request.ApplicationID, f.GUID)
+}
+
+// createAmazonPayload creates the payload that gets posted to Sources for the forged application.
+func createAmazonPayload(f *superkey.ForgedApplication) {
+	// Set the username to the role ARN since that is what is needed for this provider.
+	username := f.StepsCompleted["role"]["arn"]
+	appType := path.Base(f.Request.ApplicationType)
+	// Create the payload struct
+	f.CreatePayload(&username, nil, &appType)
+}
+
+// planAmazonApplication computes the resources that forging the request with the given GUID would create, along with
+// their substituted payloads.
+func planAmazonApplication(request *superkey.CreateRequest, guid string) ([]superkey.PlannedStep, error) {
+	f := &superkey.ForgedApplication{
+		StepsCompleted: make(map[string]map[string]string),
+		Request:        request,
+		GUID:           guid,
+	}
+
+	plan := make([]superkey.PlannedStep, 0, len(request.SuperKeySteps))
 	for _, step := range request.SuperKeySteps {
+		planned := superkey.PlannedStep{Step: step.Name}
+
 		switch step.Name {
 		case "s3":
-			name := fmt.Sprintf("%v-bucket-%v", getShortName(f.Request.ApplicationType), f.GUID)
+			planned.Resource = resourceName(f, "bucket")
+			if step.Payload == "\"create_cost_policy\"" {
+				planned.Payload = substiteInPayload(amazon.CostS3Policy, f, step.Substitutions)
+			}
 
-			err := a.Client.CreateS3Bucket(name)
+			// The following steps might refer to the bucket's name in their substitutions.
+			f.StepsCompleted["s3"] = map[string]string{"output": planned.Resource}
+
+		case "cost_report":
+			planned.Payload = substiteInPayload(step.Payload, f, step.Substitutions)
+			costReport := amazon.CostReport{}
+
+			err := json.Unmarshal([]byte(planned.Payload), &costReport)
 			if err != nil {
-				return f, fmt.Errorf(`failed to create S3 bucket "%s": %w`, name, err)
+				return nil, fmt.Errorf(`failed to build cost report with payload "%s": %w`, planned.Payload, err)
 			}
 
-			f.MarkCompleted("s3", map[string]string{"output": name})
+			planned.Resource = costReportName(f, costReport.ReportName)
 
-			l.LogWithContext(ctx).Infof(`S3 bucket "%s" created`, name)
+		case "policy":
+			planned.Resource = resourceName(f, "policy")
+			planned.Payload = substiteInPayload(step.Payload, f, step.Substitutions)
 
-			// Cost reporting requires a policy so the Reporting job can
-			// put things into the S3 bucket.
-			if step.Payload == "\"create_cost_policy\"" {
-				l.LogWithContext(ctx).Debugf(`Creating S3 bucket "%s"`, name)
+		case "role":
+			planned.Resource = resourceName(f, "role")
+			planned.Payload = substiteInPayload(step.Payload, f, step.Substitutions)
 
-				payload := substiteInPayload(amazon.CostS3Policy, f, step.Substitutions)
+		case "bind_role":
+			planned.Resource = fmt.Sprintf("%s:%s", resourceName(f, "role"), resourceName(f, "policy"))
 
-				err := a.Client.AttachBucketPolicy(name, payload)
-				if err != nil {
-					return f, fmt.Errorf(`failed to attach bucket policy to S3 bucket "%s": %w`, name, err)
-				}
+		default:
+			return nil, fmt.Errorf(`superkey step "%s" not implemented`, step.Name)
+		}
+
+		plan = append(plan, planned)
+	}
+
+	return plan, nil
+}
+
+// forgeStep creates the resources for the given superkey step, and marks the step as completed.
+func (a *AmazonProvider) forgeStep(ctx context.Context, f *superkey.ForgedApplication, step superkey.Step) error {
+	switch step.Name {
+	case "s3":
+		name := resourceName(f, "bucket")
+
+		err := f.RecordIntent(ctx, superkey.ActionCreate, "s3", map[string]string{"output": name})
+		if err != nil {
+			return err
+		}
+
+		err = a.Client.CreateS3Bucket(name)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionCreate, "s3", err)
+			return fmt.Errorf(`failed to create S3 bucket "%s": %w`, name, err)
+		}
+
+		f.MarkCompleted("s3", map[string]string{"output": name})
+		f.RecordOutcome(ctx, superkey.ActionCreate, "s3", nil)
+
+		l.LogWithContext(ctx).Infof(`S3 bucket "%s" created`, name)
 
-				l.LogWithContext(ctx).Infof(`S3 bucket policy attached to bucket "%s"`, name)
+		// Cost reporting requires a policy so the Reporting job can
+		// put things into the S3 bucket.
+		if step.Payload == "\"create_cost_policy\"" {
+			l.LogWithContext(ctx).Debugf(`Creating S3 bucket "%s"`, name)
+
+			payload := substiteInPayload(amazon.CostS3Policy, f, step.Substitutions)
+
+			err := f.RecordIntent(ctx, superkey.ActionUpdate, "s3", map[string]string{"output": name})
+			if err != nil {
+				return err
 			}
 
-		case "cost_report":
-			payload := substiteInPayload(step.Payload, f, step.Substitutions)
-			costReport := amazon.CostReport{}
+			err = a.Client.AttachBucketPolicy(name, payload)
+			if err != nil {
+				f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", err)
+				return fmt.Errorf(`failed to attach bucket policy to S3 bucket "%s": %w`, name, err)
+			}
+
+			f.MarkCompleted("s3", map[string]string{"output": name, "checksum": checksum(payload)})
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", nil)
+
+			l.LogWithContext(ctx).Infof(`S3 bucket policy attached to bucket "%s"`, name)
+		}
+
+	case "cost_report":
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+		costReport := amazon.CostReport{}
+
+		err := json.Unmarshal([]byte(payload), &costReport)
+		if err != nil {
+			return fmt.Errorf(`failed to build cost report with payload "%s": %w`, payload, err)
+		}
+
+		costReport.ReportName = costReportName(f, costReport.ReportName)
+
+		l.LogWithContext(ctx).Debugf(`Creating cost and usage report "%s"`, costReport.ReportName)
+
+		err = f.RecordIntent(ctx, superkey.ActionCreate, "cost_report", map[string]string{"output": costReport.ReportName})
+		if err != nil {
+			return err
+		}
+
+		err = a.Client.CreateCostAndUsageReport(&costReport)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionCreate, "cost_report", err)
+			return fmt.Errorf(`failed to create cost and usage report "%s": %w`, costReport.ReportName, err)
+		}
+
+		f.MarkCompleted("cost_report", map[string]string{"output": costReport.ReportName, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionCreate, "cost_report", nil)
+
+		l.LogWithContext(ctx).Infof(`Cost and usage report "%s" created`, costReport.ReportName)
+
+	case "policy":
+		name := resourceName(f, "policy")
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+
+		l.LogWithContext(ctx).Debugf(`Creating policy "%s"`, name)
+
+		// The policy's ARN is only known in advance when we know the customer's account it is created in. Without it,
+		// a policy created right before a crash gets looked up by its name when rolling it back.
+		intent := map[string]string{"name": name}
+		if arn, err := a.Client.PolicyArn(ctx, name); err == nil {
+			intent["output"] = arn
+		}
+
+		err := f.RecordIntent(ctx, superkey.ActionCreate, "policy", intent)
+		if err != nil {
+			return err
+		}
+
+		arn, err := a.Client.CreatePolicy(name, payload)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionCreate, "policy", err)
+			return fmt.Errorf(`failed to create policy "%s": %w`, name, err)
+		}
+
+		f.MarkCompleted("policy", map[string]string{"output": *arn, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionCreate, "policy", nil)
+
+		l.LogWithContext(ctx).Infof(`Policy "%s" created`, name)
+
+	case "role":
+		name := resourceName(f, "role")
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+
+		l.LogWithContext(ctx).Debugf(`Creating role "%s"`, name)
+
+		err := f.RecordIntent(ctx, superkey.ActionCreate, "role", map[string]string{"output": name})
+		if err != nil {
+			return err
+		}
+
+		roleArn, err := a.Client.CreateRole(name, payload)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionCreate, "role", err)
+			return fmt.Errorf(`failed to create role "%s": %w`, name, err)
+		}
+
+		// Store the Role ARN since that is what we need to return for the Authentication object.
+		f.MarkCompleted("role", map[string]string{"output": name, "arn": *roleArn, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionCreate, "role", nil)
+
+		l.LogWithContext(ctx).Infof(`Role "%s" created`, name)
+
+	case "bind_role":
+		roleName := f.StepsCompleted["role"]["output"]
+		policyArn := f.StepsCompleted["policy"]["output"]
+
+		if roleName == "" || policyArn == "" {
+			return errors.New(`the "bind_role" step requires the "policy" and the "role" steps to be completed first`)
+		}
+
+		l.LogWithContext(ctx).Debugf(`Binding role "%s" to policy "%s"`, roleName, policyArn)
+
+		err := f.RecordIntent(ctx, superkey.ActionCreate, "bind_role", map[string]string{})
+		if err != nil {
+			return err
+		}
+
+		err = a.Client.BindPolicyToRole(policyArn, roleName)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionCreate, "bind_role", err)
+			return fmt.Errorf(`failed to bind policy "%s" to role "%s": %w`, policyArn, roleName, err)
+		}
+
+		f.MarkCompleted("bind_role", map[string]string{})
+		f.RecordOutcome(ctx, superkey.ActionCreate, "bind_role", nil)
+
+		l.LogWithContext(ctx).Infof(`Bound role "%s" to policy "%s"`, roleName, policyArn)
+
+	default:
+		return fmt.Errorf(`superkey step "%s" not implemented`, step.Name)
+	}
+
+	return nil
+}
+
+// UpdateApplication reconciles the resources of an already forged application with the superkey steps of the
+// request: the steps that are missing get created, the steps whose payloads changed get updated in place and the
+// steps that are no longer requested get removed.
+func (a *AmazonProvider) UpdateApplication(ctx context.Context, f *superkey.ForgedApplication) error {
+	a.setAuditScope(f)
+
+	requestedSteps := make(map[string]bool)
+	for _, step := range f.Request.SuperKeySteps {
+		requestedSteps[step.Name] = true
+	}
+
+	// The binding needs both the policy and the role, so it cannot be kept or created without them. Rejecting the
+	// request before touching anything keeps the existing resources as they are.
+	if requestedSteps["bind_role"] && (!requestedSteps["policy"] || !requestedSteps["role"]) {
+		return errors.New(`the "bind_role" step requires both the "policy" and the "role" steps`)
+	}
+
+	// -----------------
+	// unbind the role first when the binding, the role or the policy got dropped, since the binding cannot outlive
+	// any of them.
+	// -----------------
+	if f.StepsCompleted["bind_role"] != nil && (!requestedSteps["bind_role"] || !requestedSteps["policy"] || !requestedSteps["role"]) {
+		policyArn := f.StepsCompleted["policy"]["output"]
+		role := f.StepsCompleted["role"]["output"]
+
+		err := f.RecordIntent(ctx, superkey.ActionDelete, "bind_role", map[string]string{})
+		if err != nil {
+			return err
+		}
+
+		err = a.Client.UnBindPolicyToRole(policyArn, role)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionDelete, "bind_role", err)
+			return fmt.Errorf(`failed to unbind policy "%s" from role "%s": %w`, policyArn, role, err)
+		}
+
+		delete(f.StepsCompleted, "bind_role")
+		f.RecordOutcome(ctx, superkey.ActionDelete, "bind_role", nil)
+
+		l.LogWithContext(ctx).Infof(`Policy "%s" unbound from role "%s"`, policyArn, role)
+	}
+
+	// -----------------
+	// remove the rest of the dropped steps.
+	// -----------------
+	dropped := &superkey.ForgedApplication{
+		StepsCompleted: make(map[string]map[string]string),
+		Request:        f.Request,
+		GUID:           f.GUID,
+	}
+
+	for name, data := range f.StepsCompleted {
+		if !requestedSteps[name] {
+			dropped.StepsCompleted[name] = data
+		}
+	}
+
+	if len(dropped.StepsCompleted) != 0 {
+		report := a.TearDown(ctx, dropped)
+		if !report.Succeeded() {
+			return fmt.Errorf("failed to remove the dropped superkey steps: %w", report.Err())
+		}
+
+		for name := range dropped.StepsCompleted {
+			delete(f.StepsCompleted, name)
+		}
+	}
 
-			err := json.Unmarshal([]byte(payload), &costReport)
+	// -----------------
+	// create the missing steps and update the ones that changed, in the order they were requested.
+	// -----------------
+	for _, step := range f.Request.SuperKeySteps {
+		if f.StepsCompleted[step.Name] == nil {
+			err := a.forgeStep(ctx, f, step)
 			if err != nil {
-				return f, fmt.Errorf(`failed to build cost report with payload "%s": %w`, payload, err)
+				return err
 			}
 
-			costReport.ReportName = fmt.Sprintf("%v-%v", costReport.ReportName, f.GUID)
+			continue
+		}
+
+		err := a.updateStep(ctx, f, step)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set the username to the role ARN since that is what is needed for this provider.
+	username := f.StepsCompleted["role"]["arn"]
+	appType := path.Base(f.Request.ApplicationType)
+	f.CreatePayload(&username, nil, &appType)
 
-			l.LogWithContext(ctx).Debugf(`Creating cost and usage report "%s"`, costReport.ReportName)
+	return nil
+}
 
-			err = a.Client.CreateCostAndUsageReport(&costReport)
+// updateStep updates the resources of an already completed step in place, when the step's substituted payload
+// differs from the one the resources were created or last updated with.
+func (a *AmazonProvider) updateStep(ctx context.Context, f *superkey.ForgedApplication, step superkey.Step) error {
+	completed := f.StepsCompleted[step.Name]
+
+	switch step.Name {
+	case "s3":
+		bucket := completed["output"]
+
+		if step.Payload != "\"create_cost_policy\"" {
+			// The buckets without a policy have no checksum either, so the live bucket is the only way of telling
+			// them apart from the ones that got their policy before the checksums were stored.
+			if completed["checksum"] == "" {
+				upToDate, err := a.legacyStepUpToDate(f, "s3", "")
+				if err != nil || upToDate {
+					return err
+				}
+			}
+
+			err := f.RecordIntent(ctx, superkey.ActionUpdate, "s3", completed)
 			if err != nil {
-				return f, fmt.Errorf(`failed to create cost and usage report "%s": %w`, costReport.ReportName, err)
+				return err
 			}
 
-			f.MarkCompleted("cost_report", map[string]string{"output": costReport.ReportName})
+			err = a.Client.DeleteBucketPolicy(bucket)
+			if err != nil {
+				f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", err)
+				return fmt.Errorf(`failed to remove the bucket policy from S3 bucket "%s": %w`, bucket, err)
+			}
 
-			l.LogWithContext(ctx).Infof(`Cost and usage report "%s" created`, costReport.ReportName)
+			f.MarkCompleted("s3", map[string]string{"output": bucket})
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", nil)
 
-		case "policy":
-			name := fmt.Sprintf("%v-policy-%v", getShortName(f.Request.ApplicationType), f.GUID)
-			payload := substiteInPayload(step.Payload, f, step.Substitutions)
+			l.LogWithContext(ctx).Infof(`S3 bucket policy removed from bucket "%s"`, bucket)
+			return nil
+		}
 
-			l.LogWithContext(ctx).Debugf(`Creating policy "%s"`, name)
+		payload := substiteInPayload(amazon.CostS3Policy, f, step.Substitutions)
+		if completed["checksum"] == checksum(payload) {
+			return nil
+		}
 
-			arn, err := a.Client.CreatePolicy(name, payload)
+		if completed["checksum"] == "" {
+			upToDate, err := a.legacyStepUpToDate(f, "s3", payload)
 			if err != nil {
-				return f, fmt.Errorf(`failed to create policy "%s": %w`, name, err)
+				return err
 			}
 
-			f.MarkCompleted("policy", map[string]string{"output": *arn})
+			if upToDate {
+				f.MarkCompleted("s3", map[string]string{"output": bucket, "checksum": checksum(payload)})
+				return nil
+			}
+		}
 
-			l.LogWithContext(ctx).Infof(`Policy "%s" created`, name)
+		err := f.RecordIntent(ctx, superkey.ActionUpdate, "s3", completed)
+		if err != nil {
+			return err
+		}
 
-		case "role":
-			name := fmt.Sprintf("%v-role-%v", getShortName(f.Request.ApplicationType), f.GUID)
-			payload := substiteInPayload(step.Payload, f, step.Substitutions)
+		err = a.Client.AttachBucketPolicy(bucket, payload)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", err)
+			return fmt.Errorf(`failed to update the bucket policy of S3 bucket "%s": %w`, bucket, err)
+		}
+
+		f.MarkCompleted("s3", map[string]string{"output": bucket, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionUpdate, "s3", nil)
 
-			l.LogWithContext(ctx).Debugf(`Creating role "%s"`, name)
+		l.LogWithContext(ctx).Infof(`S3 bucket policy updated on bucket "%s"`, bucket)
+
+	case "cost_report":
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+		if completed["checksum"] == checksum(payload) {
+			return nil
+		}
 
-			roleArn, err := a.Client.CreateRole(name, payload)
+		if completed["checksum"] == "" {
+			upToDate, err := a.legacyStepUpToDate(f, "cost_report", payload)
 			if err != nil {
-				return f, fmt.Errorf(`failed to create role "%s": %w`, name, err)
+				return err
 			}
 
-			// Store the Role ARN since that is what we need to return for the Authentication object.
-			f.MarkCompleted("role", map[string]string{"output": name, "arn": *roleArn})
+			if upToDate {
+				f.MarkCompleted("cost_report", map[string]string{"output": completed["output"], "checksum": checksum(payload)})
+				return nil
+			}
+		}
+
+		costReport := amazon.CostReport{}
+		err := json.Unmarshal([]byte(payload), &costReport)
+		if err != nil {
+			return fmt.Errorf(`failed to build cost report with payload "%s": %w`, payload, err)
+		}
+
+		// The report keeps its original name, since it is what identifies it.
+		costReport.ReportName = completed["output"]
 
-			l.LogWithContext(ctx).Infof(`Role "%s" created`, name)
+		err = f.RecordIntent(ctx, superkey.ActionUpdate, "cost_report", completed)
+		if err != nil {
+			return err
+		}
 
-		case "bind_role":
-			roleName := f.StepsCompleted["role"]["output"]
-			policyArn := f.StepsCompleted["policy"]["output"]
+		err = a.Client.ModifyCostAndUsageReport(&costReport)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "cost_report", err)
+			return fmt.Errorf(`failed to update cost and usage report "%s": %w`, costReport.ReportName, err)
+		}
 
-			l.LogWithContext(ctx).Debugf(`Binding role "%s" to policy "%s"`, roleName, policyArn)
+		f.MarkCompleted("cost_report", map[string]string{"output": costReport.ReportName, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionUpdate, "cost_report", nil)
 
-			err := a.Client.BindPolicyToRole(policyArn, roleName)
+		l.LogWithContext(ctx).Infof(`Cost and usage report "%s" updated`, costReport.ReportName)
+
+	case "policy":
+		policyArn := completed["output"]
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+		if completed["checksum"] == checksum(payload) {
+			return nil
+		}
+
+		if completed["checksum"] == "" {
+			upToDate, err := a.legacyStepUpToDate(f, "policy", payload)
 			if err != nil {
-				return f, fmt.Errorf(`failed to bind policy "%s" to role "%s": %w`, policyArn, roleName, err)
+				return err
 			}
 
-			f.MarkCompleted("bind_role", map[string]string{})
+			if upToDate {
+				f.MarkCompleted("policy", map[string]string{"output": policyArn, "checksum": checksum(payload)})
+				return nil
+			}
+		}
 
-			l.LogWithContext(ctx).Infof(`Bound role "%s" to policy "%s"`, roleName, policyArn)
+		err := f.RecordIntent(ctx, superkey.ActionUpdate, "policy", completed)
+		if err != nil {
+			return err
+		}
 
-		default:
-			return f, fmt.Errorf(`superkey step "%s" not implemented`, step.Name)
+		err = a.Client.UpdatePolicy(policyArn, payload)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "policy", err)
+			return fmt.Errorf(`failed to update policy "%s": %w`, policyArn, err)
+		}
+
+		f.MarkCompleted("policy", map[string]string{"output": policyArn, "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionUpdate, "policy", nil)
+
+		l.LogWithContext(ctx).Infof(`Policy "%s" updated`, policyArn)
+
+	case "role":
+		roleName := completed["output"]
+		payload := substiteInPayload(step.Payload, f, step.Substitutions)
+		if completed["checksum"] == checksum(payload) {
+			return nil
+		}
+
+		if completed["checksum"] == "" {
+			upToDate, err := a.legacyStepUpToDate(f, "role", payload)
+			if err != nil {
+				return err
+			}
+
+			if upToDate {
+				f.MarkCompleted("role", map[string]string{"output": roleName, "arn": completed["arn"], "checksum": checksum(payload)})
+				return nil
+			}
 		}
+
+		err := f.RecordIntent(ctx, superkey.ActionUpdate, "role", completed)
+		if err != nil {
+			return err
+		}
+
+		err = a.Client.UpdateRoleTrustPolicy(roleName, payload)
+		if err != nil {
+			f.RecordOutcome(ctx, superkey.ActionUpdate, "role", err)
+			return fmt.Errorf(`failed to update the trust policy of role "%s": %w`, roleName, err)
+		}
+
+		f.MarkCompleted("role", map[string]string{"output": roleName, "arn": completed["arn"], "checksum": checksum(payload)})
+		f.RecordOutcome(ctx, superkey.ActionUpdate, "role", nil)
+
+		l.LogWithContext(ctx).Infof(`Trust policy of role "%s" updated`, roleName)
+
+	case "bind_role":
+		// The binding has no payload, so there is nothing to update.
+
+	default:
+		return fmt.Errorf(`superkey step "%s" not implemented`, step.Name)
 	}
 
-	// Set the username to the role ARN since that is what is needed for this provider.
-	username := f.StepsCompleted["role"]["arn"]
-	appType := path.Base(f.Request.ApplicationType)
-	// Create the payload struct
-	f.CreatePayload(&username, nil, &appType)
+	return nil
+}
 
-	return f, nil
+// legacyStepUpToDate tells whether the resources of a step that was completed before the payload checksums were
+// stored already match the given payload, by comparing it with the document that is live in AWS. Without it, the
+// legacy steps would be updated on every reconciliation, whether their payload changed or not. An empty payload means
+// that the resource must not have any document, which only applies to the bucket policies.
+func (a *AmazonProvider) legacyStepUpToDate(f *superkey.ForgedApplication, step, payload string) (bool, error) {
+	resource := f.StepsCompleted[step]["output"]
+
+	var live string
+	var err error
+	switch step {
+	case "s3":
+		live, err = a.Client.BucketPolicy(resource)
+	case "policy":
+		live, err = a.Client.PolicyDocument(resource)
+	case "role":
+		live, err = a.Client.RoleTrustPolicy(resource)
+	case "cost_report":
+		// The report definitions are compared through their JSON representation, since they are not documents.
+		desired := amazon.CostReport{}
+		err = json.Unmarshal([]byte(payload), &desired)
+		if err != nil {
+			return false, fmt.Errorf(`failed to build cost report with payload "%s": %w`, payload, err)
+		}
+
+		desired.ReportName = resource
+		desiredRaw, _ := json.Marshal(desired)
+		payload = string(desiredRaw)
+
+		var costReport *amazon.CostReport
+		costReport, err = a.Client.DescribeCostAndUsageReport(resource)
+		if costReport != nil {
+			liveRaw, _ := json.Marshal(costReport)
+			live = string(liveRaw)
+		}
+	default:
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf(`failed to fetch the live state of the "%s" step: %w`, step, err)
+	}
+
+	return sameDocument(live, payload), nil
+}
+
+// sameDocument tells whether two JSON documents are equivalent, regardless of their formatting. The documents that
+// are not valid JSON are compared as they are.
+func sameDocument(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+
+	var decodedA, decodedB interface{}
+	if json.Unmarshal([]byte(a), &decodedA) != nil || json.Unmarshal([]byte(b), &decodedB) != nil {
+		return a == b
+	}
+
+	return reflect.DeepEqual(decodedA, decodedB)
 }
 
 // generateGUID() generates a short guid for resources
@@ -157,6 +644,24 @@ func generateGUID() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// checksum returns the hex encoded SHA-256 sum of a substituted payload, which gets stored along the completed step
+// so that changes in the payload can be detected when updating the application.
+func checksum(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// resourceName returns the name of the forged application's resource of the given kind.
+func resourceName(f *superkey.ForgedApplication, kind string) string {
+	return fmt.Sprintf("%v-%s-%v", getShortName(f.Request.ApplicationType), kind, f.GUID)
+}
+
+// costReportName returns the name of the forged application's cost and usage report with the given base name.
+func costReportName(f *superkey.ForgedApplication, reportName string) string {
+	return fmt.Sprintf("%v-%v", reportName, f.GUID)
+}
+
 // getShortName(string) generates a name off of the application type
 func getShortName(name string) string {
 	return fmt.Sprintf("redhat-%s", path.Base(name))
@@ -184,79 +689,137 @@ func substiteInPayload(payload string, f *superkey.ForgedApplication, substituti
 	return payload
 }
 
+// teardownOrder is the order the completed steps are torn down in. The role gets unbound first so that the policy and
+// the role can be cleanly deleted, and the s3 bucket goes last just in case other things depend on it.
+var teardownOrder = []string{"bind_role", "policy", "role", "cost_report", "s3"}
+
 // TearDown - provides amazon logic for tearing down a supported application
-// returns: error
+// returns: the report of what happened to each resource
 //
 // Basically the StepsCompleted field keeps track of what parts of the forge operation
 // went smoothly, and we just go through them in reverse and handle them.
-func (a *AmazonProvider) TearDown(ctx context.Context, f *superkey.ForgedApplication) []error {
-	errors := make([]error, 0)
-
-	// -----------------
-	// unbind the role first (if it happened) so we can cleanly delete the policy and the role.
-	// -----------------
-	if f.StepsCompleted["bind_role"] != nil {
-		policyArn := f.StepsCompleted["policy"]["output"]
-		role := f.StepsCompleted["role"]["output"]
+func (a *AmazonProvider) TearDown(ctx context.Context, f *superkey.ForgedApplication) *superkey.TeardownReport {
+	report := superkey.NewTeardownReport()
 
-		err := a.Client.UnBindPolicyToRole(policyArn, role)
-		if err != nil {
-			errors = append(errors, fmt.Errorf(`failed to unbind policy "%s" from role "%s": %w`, policyArn, role, err))
+	steps := make([]string, 0, len(teardownOrder))
+	for _, step := range teardownOrder {
+		if f.StepsCompleted[step] != nil {
+			steps = append(steps, step)
 		}
-
-		l.LogWithContext(ctx).Infof(`Policy "%s" unbound from role "%s"`, policyArn, role)
 	}
 
-	// -----------------
-	// role/policy/reporting can be deleted independently of each other.
-	// -----------------
-	if f.StepsCompleted["policy"] != nil {
-		policyArn := f.StepsCompleted["policy"]["output"]
+	for i, step := range steps {
+		result := a.TearDownStep(ctx, f, step)
+		report.Add(result)
 
-		err := a.Client.DestroyPolicy(policyArn)
-		if err != nil {
-			errors = append(errors, fmt.Errorf(`failed to destroy policy "%s": %w`, policyArn, err))
+		// The rest of the steps cannot be torn down safely if the journal is not able to record them.
+		if result.Status == superkey.TeardownSkipped {
+			report.SkipAll(f, steps[i+1:], result.Err)
+			break
 		}
-
-		l.LogWithContext(ctx).Infof(`Policy "%s" destroyed`, policyArn)
 	}
 
-	if f.StepsCompleted["role"] != nil {
-		roleName := f.StepsCompleted["role"]["output"]
+	return report
+}
+
+// TearDownStep - tears down the resource created by the given completed step.
+// A resource that does not exist anymore is reported as already absent.
+func (a *AmazonProvider) TearDownStep(ctx context.Context, f *superkey.ForgedApplication, step string) superkey.TeardownResult {
+	a.setAuditScope(f)
+
+	result := superkey.TeardownResult{Step: step, Resource: f.StepResource(step)}
 
-		err := a.Client.DestroyRole(roleName)
+	// A policy whose creation got interrupted before its ARN was known only has
+	// its name recorded, so it gets looked up by it.
+	if step == "policy" && result.Resource == "" && f.StepsCompleted["policy"]["name"] != "" {
+		arn, err := a.Client.PolicyArn(ctx, f.StepsCompleted["policy"]["name"])
 		if err != nil {
-			errors = append(errors, fmt.Errorf(`failed to destroy role "%s": %w`, roleName, err))
+			result.Status = superkey.TeardownFailed
+			result.ErrorClass = amazon.ClassifyError(err)
+			result.Err = fmt.Errorf(`failed to look the policy "%s" up: %w`, f.StepsCompleted["policy"]["name"], err)
+			return result
 		}
 
-		l.LogWithContext(ctx).Infof(`Role "%s" destroyed`, roleName)
+		f.StepsCompleted["policy"]["output"] = arn
+		result.Resource = arn
 	}
 
-	if f.StepsCompleted["cost_report"] != nil {
-		reportName := f.StepsCompleted["cost_report"]["output"]
+	var description string
+	switch step {
+	case "bind_role":
+		description = fmt.Sprintf(`binding of policy "%s" to role "%s"`, f.StepsCompleted["policy"]["output"], f.StepsCompleted["role"]["output"])
+	case "policy":
+		description = fmt.Sprintf(`policy "%s"`, result.Resource)
+	case "role":
+		description = fmt.Sprintf(`role "%s"`, result.Resource)
+	case "cost_report":
+		description = fmt.Sprintf(`cost and usage report "%s"`, result.Resource)
+	case "s3":
+		description = fmt.Sprintf(`S3 bucket "%s"`, result.Resource)
+	default:
+		result.Status = superkey.TeardownSkipped
+		result.Err = fmt.Errorf(`unsupported step "%s"`, step)
+		return result
+	}
 
-		err := a.Client.DestroyCostAndUsageReport(reportName)
-		if err != nil {
-			errors = append(errors, fmt.Errorf(`failed to destroy cost and usage report "%s": %w`, reportName, err))
-		}
+	err := f.RecordIntent(ctx, superkey.ActionDelete, step, f.StepsCompleted[step])
+	if err != nil {
+		result.Status = superkey.TeardownSkipped
+		result.Err = err
+		return result
+	}
 
-		l.LogWithContext(ctx).Infof(`Cost and usage report "%s" destroyed`, reportName)
+	switch step {
+	case "bind_role":
+		err = a.Client.UnBindPolicyToRole(f.StepsCompleted["policy"]["output"], f.StepsCompleted["role"]["output"])
+	case "policy":
+		err = a.Client.DestroyPolicy(result.Resource)
+	case "role":
+		err = a.Client.DestroyRole(result.Resource)
+	case "cost_report":
+		err = a.Client.DestroyCostAndUsageReport(result.Resource)
+	case "s3":
+		err = a.Client.DestroyS3Bucket(result.Resource)
 	}
 
-	// -----------------
-	// s3 bucket can probably be deleted earlier, but leave it to last just in case
-	// other things depend on it.
-	// -----------------
-	if f.StepsCompleted["s3"] != nil {
-		bucket := f.StepsCompleted["s3"]["output"]
+	switch {
+	case err == nil:
+		result.Status = superkey.TeardownDeleted
+		f.RecordOutcome(ctx, superkey.ActionDelete, step, nil)
+
+		l.LogWithContext(ctx).Infof(`The %s was destroyed`, description)
+	case amazon.IsNotFound(err):
+		result.Status = superkey.TeardownAlreadyAbsent
+		f.RecordOutcome(ctx, superkey.ActionDelete, step, nil)
+
+		l.LogWithContext(ctx).Infof(`The %s was already absent`, description)
+	default:
+		result.Status = superkey.TeardownFailed
+		result.ErrorClass = amazon.ClassifyError(err)
+		result.Err = fmt.Errorf(`failed to destroy the %s: %w`, description, err)
+		f.RecordOutcome(ctx, superkey.ActionDelete, step, err)
+	}
 
-		err := a.Client.DestroyS3Bucket(bucket)
-		if err != nil {
-			errors = append(errors, fmt.Errorf(`failed to destroy S3 bucket "%s": %w`, bucket, err))
-		}
+	return result
+}
 
-		l.LogWithContext(ctx).Infof(`S3 bucket "%s" destroyed`, bucket)
+// StepExists - checks whether the resource created by the given completed step
+// still exists in AWS.
+func (a *AmazonProvider) StepExists(ctx context.Context, f *superkey.ForgedApplication, step string) (bool, error) {
+	resource := f.StepResource(step)
+
+	switch step {
+	case "bind_role":
+		return a.Client.PolicyBoundToRole(f.StepsCompleted["policy"]["output"], f.StepsCompleted["role"]["output"])
+	case "policy":
+		return a.Client.PolicyExists(resource)
+	case "role":
+		return a.Client.RoleExists(resource)
+	case "cost_report":
+		return a.Client.CostAndUsageReportExists(resource)
+	case "s3":
+		return a.Client.S3BucketExists(resource)
+	default:
+		return false, fmt.Errorf(`unsupported step "%s"`, step)
 	}
-
-	return errors
 }
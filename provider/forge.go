@@ -3,6 +3,8 @@ package provider
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/redhatinsights/sources-superkey-worker/amazon"
 	"github.com/redhatinsights/sources-superkey-worker/config"
@@ -12,7 +14,7 @@ import (
 
 // Forge - creates the provider client based on provider and forges resources
 func Forge(ctx context.Context, request *superkey.CreateRequest) (*superkey.ForgedApplication, error) {
-	client, err := getProvider(ctx, request)
+	client, err := getProvider(ctx, request, nil, getStepNames(request.SuperKeySteps))
 	if err != nil {
 		return nil, fmt.Errorf("unable to get provider: %w", err)
 	}
@@ -24,18 +26,129 @@ func Forge(ctx context.Context, request *superkey.CreateRequest) (*superkey.Forg
 	return f, err
 }
 
+// Update - reconciles the resources of an already forged application with the
+// request's superkey steps
+// returns: the forged application with the reconciled state, which is returned
+// even on error so that the partial progress can be stored.
+func Update(ctx context.Context, request *superkey.UpdateRequest) (*superkey.ForgedApplication, error) {
+	f := superkey.ReconstructForgedApplicationForUpdate(request)
+
+	err := f.BeginOperation(ctx, "update_application")
+	if err != nil {
+		return f, err
+	}
+
+	return f, UpdateForgedApplication(ctx, f)
+}
+
+// UpdateForgedApplication - reconciles the resources of the given forged
+// application with its request's superkey steps
+func UpdateForgedApplication(ctx context.Context, f *superkey.ForgedApplication) error {
+	// The client needs to be able to talk to the APIs of both the requested steps and the steps that might need to
+	// be removed.
+	stepNames := getStepNames(f.Request.SuperKeySteps)
+	for name := range f.StepsCompleted {
+		stepNames = append(stepNames, name)
+	}
+
+	client, err := getProvider(ctx, f.Request, f.StepsCompleted, stepNames)
+	if err != nil {
+		return fmt.Errorf("unable to get provider: %w", err)
+	}
+	f.Client = client
+
+	return client.UpdateApplication(ctx, f)
+}
+
+// ReconstructForRegistration - rebuilds an application whose resources were
+// already forged, along with the payload that gets posted to Sources, so that
+// only its registration in Sources can be repeated
+func ReconstructForRegistration(request *superkey.CreateRequest, guid string, stepsCompleted map[string]map[string]string) (*superkey.ForgedApplication, error) {
+	f := &superkey.ForgedApplication{
+		StepsCompleted: stepsCompleted,
+		Request:        request,
+		GUID:           guid,
+	}
+
+	switch request.Provider {
+	case "amazon":
+		createAmazonPayload(f)
+	default:
+		return nil, fmt.Errorf(`unsupported auth provider "%s"`, request.Provider)
+	}
+
+	return f, nil
+}
+
+// ForgeWithCredentials - forges the request's resources with the given
+// credentials instead of the superkey stored in Sources
+// returns: the forged application, which is returned even on error so that
+// whatever got created can be torn down.
+func ForgeWithCredentials(ctx context.Context, request *superkey.CreateRequest, creds Credentials) (*superkey.ForgedApplication, error) {
+	client, err := newProvider(ctx, request, creds, nil, getStepNames(request.SuperKeySteps))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get provider: %w", err)
+	}
+
+	return client.ForgeApplication(ctx, request)
+}
+
+// TearDownWithCredentials - tears down the forged application with the given
+// credentials instead of the superkey stored in Sources
+// returns: the report of what happened to each resource.
+func TearDownWithCredentials(ctx context.Context, f *superkey.ForgedApplication, creds Credentials) (*superkey.TeardownReport, error) {
+	steps := make([]string, 0, len(f.StepsCompleted))
+	for step := range f.StepsCompleted {
+		steps = append(steps, step)
+	}
+
+	client, err := newProvider(ctx, f.Request, creds, f.StepsCompleted, steps)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get provider: %w", err)
+	}
+	f.Client = client
+
+	return client.TearDown(ctx, f), nil
+}
+
+// Plan - computes the resources that forging the request would create, along
+// with their substituted payloads, without calling the provider
+func Plan(request *superkey.CreateRequest) ([]superkey.PlannedStep, error) {
+	switch request.Provider {
+	case "amazon":
+		guid, err := generateGUID()
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate guid: %w", err)
+		}
+
+		return planAmazonApplication(request, guid)
+	default:
+		return nil, fmt.Errorf(`unsupported auth provider "%s"`, request.Provider)
+	}
+}
+
 // TearDown - tears down application that was forged
-// returns: array of errors if any were returned.
-func TearDown(ctx context.Context, f *superkey.ForgedApplication) []error {
+// returns: the report of what happened to each resource. Every resource is
+// reported as skipped when the provider could not be set up.
+func TearDown(ctx context.Context, f *superkey.ForgedApplication) *superkey.TeardownReport {
 	if f == nil {
-		return []error{}
+		return superkey.NewTeardownReport()
 	}
 
 	// the client is nil if it came from a destroy request
 	if f.Client == nil {
-		client, err := getProvider(ctx, f.Request)
+		client, err := getProvider(ctx, f.Request, f.StepsCompleted, getStepNames(f.Request.SuperKeySteps))
 		if err != nil {
-			return []error{err}
+			steps := make([]string, 0, len(f.StepsCompleted))
+			for step := range f.StepsCompleted {
+				steps = append(steps, step)
+			}
+			sort.Strings(steps)
+
+			report := superkey.NewTeardownReport()
+			report.SkipAll(f, steps, fmt.Errorf("unable to get provider: %w", err))
+
+			return report
 		}
 
 		f.Client = client
@@ -44,9 +157,43 @@ func TearDown(ctx context.Context, f *superkey.ForgedApplication) []error {
 	return f.Client.TearDown(ctx, f)
 }
 
-// getProvider returns a provider based on create request's provider + credentials
-func getProvider(ctx context.Context, request *superkey.CreateRequest) (superkey.Provider, error) {
-	sourcesRestClient := sources.NewSourcesClient(config.Get())
+// RetryTearDownStep - tears down the resource created by the given completed
+// step, unless it no longer exists
+// returns: the result of the teardown
+func RetryTearDownStep(ctx context.Context, f *superkey.ForgedApplication, step string) superkey.TeardownResult {
+	client, err := getProvider(ctx, f.Request, f.StepsCompleted, []string{step})
+	if err != nil {
+		return superkey.TeardownResult{Step: step, Resource: f.StepResource(step), Status: superkey.TeardownSkipped, Err: fmt.Errorf("unable to get provider: %w", err)}
+	}
+	f.Client = client
+
+	exists, err := client.StepExists(ctx, f, step)
+	if err != nil {
+		return superkey.TeardownResult{
+			Step:       step,
+			Resource:   f.StepResource(step),
+			Status:     superkey.TeardownFailed,
+			ErrorClass: amazon.ClassifyError(err),
+			Err:        fmt.Errorf(`unable to check whether the resource of the "%s" step still exists: %w`, step, err),
+		}
+	}
+
+	if !exists {
+		return superkey.TeardownResult{Step: step, Resource: f.StepResource(step), Status: superkey.TeardownAlreadyAbsent}
+	}
+
+	return client.TearDownStep(ctx, f, step)
+}
+
+// getProvider returns a provider based on create request's provider + credentials,
+// able to talk to the APIs the given steps need. The completed steps, if any, are
+// used to figure out the AWS account the calls get rate limited for.
+func getProvider(ctx context.Context, request *superkey.CreateRequest, stepsCompleted map[string]map[string]string, stepNames []string) (superkey.Provider, error) {
+	conf := config.Get()
+	sourcesRestClient, err := sources.Client()
+	if err != nil {
+		return nil, err
+	}
 
 	authData := sources.AuthenticationData{
 		IdentityHeader: request.IdentityHeader,
@@ -62,11 +209,22 @@ func getProvider(ctx context.Context, request *superkey.CreateRequest) (superkey
 		return nil, fmt.Errorf(`missing username or password from authentication ID "%s" and superkey credential "%s"`, auth.ID, request.SuperKey)
 	}
 
+	client, err := newProvider(ctx, request, Credentials{AccessKey: auth.Username, SecretKey: auth.Password, Endpoint: conf.AwsEndpoint}, stepsCompleted, stepNames)
+	if err != nil {
+		return nil, fmt.Errorf(`unable to create provider with authentication ID "%s": %w`, auth.ID, err)
+	}
+
+	return client, nil
+}
+
+// newProvider returns a provider based on the request's provider that uses the
+// given credentials, able to talk to the APIs the given steps need.
+func newProvider(ctx context.Context, request *superkey.CreateRequest, creds Credentials, stepsCompleted map[string]map[string]string, stepNames []string) (superkey.Provider, error) {
 	switch request.Provider {
 	case "amazon":
-		client, err := amazon.NewClient(ctx, auth.Username, auth.Password, getStepNames(request.SuperKeySteps)...)
+		client, err := amazon.NewClientForEndpoint(ctx, creds.AccessKey, creds.SecretKey, creds.Endpoint, request.TenantID, awsAccountId(stepsCompleted), stepNames...)
 		if err != nil {
-			return nil, fmt.Errorf(`unable to create Amazon client with authentication ID "%s": %w`, auth.ID, err)
+			return nil, fmt.Errorf("unable to create Amazon client: %w", err)
 		}
 
 		return &AmazonProvider{Client: client}, nil
@@ -75,6 +233,21 @@ func getProvider(ctx context.Context, request *superkey.CreateRequest) (superkey
 	}
 }
 
+// awsAccountId returns the customer's AWS account identifier taken from the
+// created role's ARN, or an empty one when no role was created yet, in which
+// case the client looks it up from the credentials. The request's extra
+// "account" is not used since it holds the Red Hat account that gets trusted by
+// the role.
+func awsAccountId(stepsCompleted map[string]map[string]string) string {
+	// arn:aws:iam::<account id>:role/<role name>
+	roleArn := strings.Split(stepsCompleted["role"]["arn"], ":")
+	if len(roleArn) > 4 {
+		return roleArn[4]
+	}
+
+	return ""
+}
+
 func getStepNames(steps []superkey.Step) []string {
 	names := make([]string, 0)
 	for _, step := range steps {
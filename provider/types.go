@@ -0,0 +1,23 @@
+package provider
+
+import (
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// Credentials are the provider credentials used instead of the ones stored in Sources, e.g. when forging resources
+// from the command line.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	// Endpoint is the endpoint the provider's APIs are reached at. When empty, the provider's default ones are used.
+	Endpoint string
+}
+
+// MarshalLog returns the endpoint the credentials are used with, without the credentials themselves.
+func (c Credentials) MarshalLog() map[string]interface{} {
+	return map[string]interface{}{
+		"access_key": l.RedactedValue,
+		"secret_key": l.RedactedValue,
+		"endpoint":   c.Endpoint,
+	}
+}
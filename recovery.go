@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redhatinsights/sources-superkey-worker/journal"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// errInterruptedOperation is the error reported to Sources for the applications whose creation had to be rolled back
+// because the worker stopped in the middle of it.
+var errInterruptedOperation = errors.New("the worker stopped before the resources could be created")
+
+// recoverInterruptedOperations resumes or rolls back the operations that the journal recorded as unfinished, which
+// means that they were interrupted by a crash or a restart of the worker.
+func recoverInterruptedOperations(j *journal.BoltJournal) {
+	operations, err := j.Unfinished()
+	if err != nil {
+		l.Log.Errorf("Unable to recover the interrupted operations: %s", err)
+		return
+	}
+
+	if len(operations) == 0 {
+		return
+	}
+
+	l.Log.Warnf("Recovering %d interrupted operations", len(operations))
+
+	for _, operation := range operations {
+		recoverOperation(operation)
+	}
+}
+
+// recoverOperation resumes the operation when the remaining work can be safely redone, and rolls it back otherwise.
+func recoverOperation(operation *journal.Operation) {
+	f := operation.ForgedApplication()
+
+	// Define the log context with the fields we want to log.
+	ctx := l.WithTenantId(context.Background(), f.Request.TenantID)
+	ctx = l.WithSourceId(ctx, f.Request.SourceID)
+	ctx = l.WithApplicationId(ctx, f.Request.ApplicationID)
+	ctx = l.WithApplicationType(ctx, f.Request.ApplicationType)
+
+	l.LogWithContext(ctx).Warnf(`Recovering the "%s" operation for the resources with GUID "%s", interrupted in the "%s" phase`, operation.EventType, operation.GUID, operation.Phase)
+
+	switch operation.EventType {
+	case "create_application":
+		// When every resource was forged, only the registration in Sources is left to do.
+		if operation.Phase == superkey.PhaseRegistering && f.Product != nil {
+			// The worker might have stopped after the registration went through but before the operation got finished.
+			registered, err := f.IsRegistered(ctx)
+			if err != nil {
+				// Rolling back a registration that might have gone through would leave Sources pointing to deleted
+				// resources, so the operation is left for the next start to recover.
+				l.LogWithContext(ctx).Errorf("Unable to check whether the forged resources were registered in Sources, leaving the operation to the next recovery: %s", err)
+				return
+			}
+
+			if !registered {
+				// The journal does not store the secrets of the request, such as the external ID the authentication
+				// gets created with, so they are restored from the application.
+				err = f.Request.RestoreSensitiveExtra(ctx)
+				if err == nil {
+					err = f.CreateInSourcesAPI(ctx)
+				}
+			}
+
+			if err == nil {
+				f.FinishOperation(ctx, superkey.PhaseCompleted, nil)
+				successfulResourcesCreationCounter.Inc()
+
+				l.LogWithContext(ctx).Info("Resumed the registration of the forged resources in Sources")
+				return
+			}
+
+			if isRetryable(err) {
+				l.LogWithContext(ctx).Errorf("Unable to resume the registration of the forged resources in Sources, leaving the operation to the next recovery: %s", err)
+				return
+			}
+
+			l.LogWithContext(ctx).Errorf("Unable to resume the registration of the forged resources in Sources, rolling back: %s", err)
+		}
+
+		f.SetPhase(ctx, superkey.PhaseTearingDown)
+
+		report := provider.TearDown(ctx, f)
+		recordTeardownReport(ctx, f, report)
+
+		err := f.Request.MarkSourceUnavailable(ctx, errInterruptedOperation, f)
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, err)
+		}
+
+		f.FinishOperation(ctx, superkey.PhaseRolledBack, errInterruptedOperation)
+		unsuccessfulResourcesCreationCounter.Inc()
+
+		l.LogWithContext(ctx).Info("Rolled back the interrupted creation of the resources")
+
+	case "update_application":
+		// Reconciling again is safe, since only the differences with the requested steps get acted upon.
+		err := provider.UpdateForgedApplication(ctx, f)
+		if err == nil {
+			f.SetPhase(ctx, superkey.PhaseRegistering)
+			err = f.UpdateInSourcesAPI(ctx)
+		}
+
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Unable to resume the reconciliation of the application's resources: %s`, err)
+
+			markErr := f.Request.MarkUpdateFailed(ctx, err, f)
+			if markErr != nil {
+				l.LogWithContext(ctx).Errorf(`Error while marking the source and application as "unavailable" in Sources: %s`, markErr)
+			}
+
+			f.FinishOperation(ctx, superkey.PhaseFailed, err)
+			unsuccessfulResourcesUpdateCounter.Inc()
+			return
+		}
+
+		f.FinishOperation(ctx, superkey.PhaseCompleted, nil)
+		successfulResourcesUpdateCounter.Inc()
+
+		l.LogWithContext(ctx).Info("Resumed the reconciliation of the application's resources")
+
+	default:
+		// The teardown only acts on the resources that have not been deleted yet, so it can be resumed as is.
+		report := provider.TearDown(ctx, f)
+		recordTeardownReport(ctx, f, report)
+		reportTeardownResults(ctx, f, report)
+
+		if !report.Succeeded() {
+			f.FinishOperation(ctx, superkey.PhaseFailed, report.Err())
+			unsuccessfulResourcesDeletionCounter.Inc()
+			return
+		}
+
+		f.FinishOperation(ctx, superkey.PhaseCompleted, nil)
+		successfulResourcesDeletionCounter.Inc()
+
+		l.LogWithContext(ctx).Info("Resumed the teardown of the resources")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,11 +17,19 @@ import (
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
 )
 
+// ErrNotFound is returned when the requested resource does not exist in Sources.
+var ErrNotFound = errors.New("resource not found in Sources")
+
 // sourcesClient holds the required information to be able to send requests back to the Sources API.
 type sourcesClient struct {
 	baseV31URL         *url.URL
 	baseV20InternalUrl *url.URL
 	config             *config.SuperKeyWorkerConfig
+	httpClient         *http.Client
+	// breaker stops the requests while the Sources API keeps on failing.
+	breaker *CircuitBreaker
+	// pendingExtras keeps the application extras that could not be stored, so that they get sent again.
+	pendingExtras *pendingExtras
 }
 
 // AuthenticationData holds the required authentication elements that need to be sent back to the Sources API when
@@ -30,6 +39,18 @@ type AuthenticationData struct {
 	OrgId          string
 }
 
+// MarshalLog returns the organization the requests are made for, without the identity header.
+func (a *AuthenticationData) MarshalLog() map[string]interface{} {
+	if a == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"org_id":          a.OrgId,
+		"identity_header": l.RedactedValue,
+	}
+}
+
 // PatchApplicationRequest represents the fields that we might want to update when updating the application's details.
 //
 // The AvailabilityStatus field represents the current application's availability status.
@@ -48,8 +69,78 @@ type PatchSourceRequest struct {
 	AvailabilityStatus *string `json:"availability_status"`
 }
 
-// NewSourcesClient initializes a new SourcesClient to be able to communicate with the Sources API.
-func NewSourcesClient(config *config.SuperKeyWorkerConfig) *sourcesClient {
+// ApplicationTypeResponse represents the fields of an application type that we read from the Sources API. The
+// SuperKeyMetadata field holds the superkey steps the applications of the type require.
+type ApplicationTypeResponse struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	SuperKeyMetadata json.RawMessage `json:"superkey_metadata"`
+}
+
+// BulkCreateRequest represents the resources to be created through the Sources' bulk create endpoint, which creates
+// all of them in a single transaction.
+type BulkCreateRequest struct {
+	Authentications []BulkCreateAuthentication `json:"authentications"`
+}
+
+// BulkCreateAuthentication represents an authentication to be created through the bulk create endpoint, which also
+// links it to the resource it belongs to.
+//
+// The ResourceName field identifies the resource the authentication belongs to, which is the application type's name
+// for the applications.
+type BulkCreateAuthentication struct {
+	model.AuthenticationCreateRequest
+	ResourceName string `json:"resource_name"`
+}
+
+// BulkCreateResponse represents the resources the bulk create endpoint created.
+type BulkCreateResponse struct {
+	Authentications            []model.AuthenticationResponse      `json:"authentications"`
+	ApplicationAuthentications []ApplicationAuthenticationResponse `json:"application_authentications"`
+}
+
+// ApplicationAuthenticationResponse represents the link between an application and an authentication in Sources.
+type ApplicationAuthenticationResponse struct {
+	ID               string `json:"id"`
+	ApplicationID    string `json:"application_id"`
+	AuthenticationID string `json:"authentication_id"`
+}
+
+// AuthenticationResponse represents the fields of an authentication that we read from the Sources API.
+type AuthenticationResponse struct {
+	ID           string `json:"id"`
+	AuthType     string `json:"authtype"`
+	Username     string `json:"username"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+}
+
+// ApplicationResponse represents the fields of an application that we read from the Sources API. The Extra field is
+// kept raw so that each consumer can decode the keys it cares about.
+type ApplicationResponse struct {
+	ID                string          `json:"id"`
+	SourceID          string          `json:"source_id"`
+	ApplicationTypeID string          `json:"application_type_id"`
+	Extra             json.RawMessage `json:"extra"`
+	PausedAt          *time.Time      `json:"paused_at,omitempty"`
+}
+
+// SourceResponse represents the fields of a source that we read from the Sources API.
+type SourceResponse struct {
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	AvailabilityStatus string     `json:"availability_status"`
+	PausedAt           *time.Time `json:"paused_at,omitempty"`
+}
+
+// NewSourcesClient initializes a new SourcesClient to be able to communicate with the Sources API. Prefer the shared
+// client returned by "Client", which reuses its connections.
+func NewSourcesClient(config *config.SuperKeyWorkerConfig) (*sourcesClient, error) {
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up the HTTP client for Sources: %w", err)
+	}
+
 	return &sourcesClient{
 		baseV20InternalUrl: &url.URL{
 			Host:   fmt.Sprintf("%s:%d", config.SourcesHost, config.SourcesPort),
@@ -61,8 +152,16 @@ func NewSourcesClient(config *config.SuperKeyWorkerConfig) *sourcesClient {
 			Path:   "/api/sources/v3.1",
 			Scheme: config.SourcesScheme,
 		},
-		config: config,
-	}
+		config:        config,
+		httpClient:    httpClient,
+		breaker:       NewCircuitBreaker(config.SourcesBreakerThreshold, config.SourcesBreakerOpenDuration),
+		pendingExtras: newPendingExtras(),
+	}, nil
+}
+
+// BulkCreateEnabled returns true when the authentications are meant to be created through the bulk create endpoint.
+func (sc *sourcesClient) BulkCreateEnabled() bool {
+	return sc.config.SourcesBulkCreate
 }
 
 func (sc *sourcesClient) TriggerSourceAvailabilityCheck(ctx context.Context, authData *AuthenticationData, sourceId string) error {
@@ -90,6 +189,32 @@ func (sc *sourcesClient) CreateAuthentication(ctx context.Context, authData *Aut
 	return createdAuthentication, nil
 }
 
+func (sc *sourcesClient) BulkCreate(ctx context.Context, authData *AuthenticationData, bulkCreateRequest *BulkCreateRequest) (*BulkCreateResponse, error) {
+	bulkCreateUrl := sc.baseV31URL.JoinPath("/bulk_create")
+
+	var bulkCreateResponse *BulkCreateResponse = nil
+	err := sc.sendRequest(ctx, http.MethodPost, bulkCreateUrl, authData, bulkCreateRequest, &bulkCreateResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error while bulk creating the resources: %w", err)
+	}
+
+	return bulkCreateResponse, nil
+}
+
+func (sc *sourcesClient) DeleteAuthentication(ctx context.Context, authData *AuthenticationData, authId string) error {
+	deleteAuthenticationUrl := sc.baseV31URL.JoinPath("/authentications/", url.PathEscape(authId))
+
+	// Set the logging fields.
+	ctx = l.WithAuthenticationId(ctx, authId)
+
+	err := sc.sendRequest(ctx, http.MethodDelete, deleteAuthenticationUrl, authData, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error while deleting the authentication: %w", err)
+	}
+
+	return nil
+}
+
 func (sc *sourcesClient) CreateApplicationAuthentication(ctx context.Context, authData *AuthenticationData, appAuthCreateRequest *model.ApplicationAuthenticationCreateRequest) error {
 	createApplicationAuthenticationUrl := sc.baseV31URL.JoinPath("/application_authentications")
 
@@ -111,7 +236,107 @@ func (sc *sourcesClient) PatchApplication(ctx context.Context, authData *Authent
 	// Set the logging fields.
 	ctx = l.WithApplicationId(ctx, appId)
 
-	return sc.sendRequest(ctx, http.MethodPatch, patchApplicationUrl, authData, patchApplicationRequest, nil)
+	err := sc.sendRequest(ctx, http.MethodPatch, patchApplicationUrl, authData, patchApplicationRequest, nil)
+	if err != nil {
+		return err
+	}
+
+	sc.pendingExtras.supersede(appId, patchApplicationRequest.Extra)
+
+	return nil
+}
+
+func (sc *sourcesClient) ResetApplicationExtra(ctx context.Context, authData *AuthenticationData, appId string, extra map[string]interface{}) error {
+	patchApplicationUrl := sc.baseV31URL.JoinPath("/applications/", url.PathEscape(appId))
+
+	// Set the logging fields.
+	ctx = l.WithApplicationId(ctx, appId)
+
+	// Unlike "PatchApplication", only the extra is sent, so that the availability status is left untouched.
+	body := map[string]interface{}{"extra": extra}
+
+	err := sc.sendRequest(ctx, http.MethodPatch, patchApplicationUrl, authData, body, nil)
+	if err != nil {
+		return fmt.Errorf("error while resetting the application's extra: %w", err)
+	}
+
+	sc.pendingExtras.supersede(appId, extra)
+
+	return nil
+}
+
+func (sc *sourcesClient) GetApplication(ctx context.Context, authData *AuthenticationData, appId string) (*ApplicationResponse, error) {
+	getApplicationUrl := sc.baseV31URL.JoinPath("/applications/", url.PathEscape(appId))
+
+	// Set the logging fields.
+	ctx = l.WithApplicationId(ctx, appId)
+
+	var application *ApplicationResponse = nil
+	err := sc.sendRequest(ctx, http.MethodGet, getApplicationUrl, authData, nil, &application)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching the application: %w", err)
+	}
+
+	return application, nil
+}
+
+func (sc *sourcesClient) ListSourceApplications(ctx context.Context, authData *AuthenticationData, sourceId string) ([]ApplicationResponse, error) {
+	listApplicationsUrl := sc.baseV31URL.JoinPath("/sources/", url.PathEscape(sourceId), "/applications")
+
+	// Set the logging fields.
+	ctx = l.WithSourceId(ctx, sourceId)
+
+	applications := struct {
+		Data []ApplicationResponse `json:"data"`
+	}{}
+
+	err := sc.sendRequest(ctx, http.MethodGet, listApplicationsUrl, authData, nil, &applications)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing the source's applications: %w", err)
+	}
+
+	return applications.Data, nil
+}
+
+func (sc *sourcesClient) ListApplicationAuthentications(ctx context.Context, authData *AuthenticationData, appId string) ([]AuthenticationResponse, error) {
+	listAuthenticationsUrl := sc.baseV31URL.JoinPath("/applications/", url.PathEscape(appId), "/authentications")
+
+	// Set the logging fields.
+	ctx = l.WithApplicationId(ctx, appId)
+
+	authentications := struct {
+		Data []AuthenticationResponse `json:"data"`
+	}{}
+
+	err := sc.sendRequest(ctx, http.MethodGet, listAuthenticationsUrl, authData, nil, &authentications)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing the application's authentications: %w", err)
+	}
+
+	return authentications.Data, nil
+}
+
+func (sc *sourcesClient) GetApplicationType(ctx context.Context, authData *AuthenticationData, name string) (*ApplicationTypeResponse, error) {
+	getApplicationTypesUrl := sc.baseV31URL.JoinPath("/application_types")
+	getApplicationTypesUrl.RawQuery = url.Values{"filter[name][eq]": []string{name}}.Encode()
+
+	// Set the logging fields.
+	ctx = l.WithApplicationType(ctx, name)
+
+	applicationTypes := struct {
+		Data []ApplicationTypeResponse `json:"data"`
+	}{}
+
+	err := sc.sendRequest(ctx, http.MethodGet, getApplicationTypesUrl, authData, nil, &applicationTypes)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching the application type: %w", err)
+	}
+
+	if len(applicationTypes.Data) == 0 {
+		return nil, fmt.Errorf(`%w: application type "%s"`, ErrNotFound, name)
+	}
+
+	return &applicationTypes.Data[0], nil
 }
 
 func (sc *sourcesClient) PatchSource(ctx context.Context, authData *AuthenticationData, sourceId string, patchSourceRequest *PatchSourceRequest) error {
@@ -123,6 +348,21 @@ func (sc *sourcesClient) PatchSource(ctx context.Context, authData *Authenticati
 	return sc.sendRequest(ctx, http.MethodPatch, patchSourceUrl, authData, patchSourceRequest, nil)
 }
 
+func (sc *sourcesClient) GetSource(ctx context.Context, authData *AuthenticationData, sourceId string) (*SourceResponse, error) {
+	getSourceUrl := sc.baseV31URL.JoinPath("/sources/", url.PathEscape(sourceId))
+
+	// Set the logging fields.
+	ctx = l.WithSourceId(ctx, sourceId)
+
+	var source *SourceResponse = nil
+	err := sc.sendRequest(ctx, http.MethodGet, getSourceUrl, authData, nil, &source)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching the source: %w", err)
+	}
+
+	return source, nil
+}
+
 func (sc *sourcesClient) GetInternalAuthentication(ctx context.Context, authData *AuthenticationData, authId string) (*model.AuthenticationInternalResponse, error) {
 	getInternalAuthUrl := sc.baseV20InternalUrl.JoinPath("/authentications/", url.PathEscape(authId), "/?expose_encrypted_attribute[]=password")
 
@@ -138,20 +378,19 @@ func (sc *sourcesClient) GetInternalAuthentication(ctx context.Context, authData
 // sendRequest sends a request with the provided method and body to the given url, performing a maximum number of
 // attempts and marshaling the incoming response's body. You can leave the body and the marshalTarget arguments empty
 // if you do not require them.
+//
+// Failed attempts are retried with an exponential backoff, or after the delay the "Retry-After" header asks for, as
+// long as the request is idempotent.
 func (sc *sourcesClient) sendRequest(ctx context.Context, httpMethod string, url *url.URL, authData *AuthenticationData, body interface{}, marshalTarget interface{}) error {
-	// Set up a timeout so that the requests don't hang up forever.
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
 	// When a body is specified, attempt to marshal it as JSON.
-	var requestBody *bytes.Buffer = nil
+	var bodyBytes []byte
 	if body != nil {
 		tmp, err := json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
 
-		requestBody = bytes.NewBuffer(tmp)
+		bodyBytes = tmp
 	}
 
 	// Build the URL for the request. Unfortunately, we cannot simply use "url.String()" because it does escape the
@@ -159,88 +398,91 @@ func (sc *sourcesClient) sendRequest(ctx context.Context, httpMethod string, url
 	// return a "Not found" response. Things like "[]" get escaped and therefore the URL does not match Sources'
 	// router, which causes issues.
 	urlRaw := fmt.Sprintf("%s://%s:%s%s", url.Scheme, url.Hostname(), url.Port(), url.Path)
-
-	// Store the body bytes so we can create a fresh reader for each retry attempt. The body is an io.Reader which
-	// gets exhausted after the first read.
-	var bodyBytes []byte
-	if requestBody != nil {
-		bodyBytes = requestBody.Bytes()
+	if url.RawQuery != "" {
+		urlRaw = fmt.Sprintf("%s?%s", urlRaw, url.RawQuery)
 	}
 
 	// Add the logging fields to the context.
 	ctx = l.WithHttpMethod(ctx, httpMethod)
 	ctx = l.WithURL(ctx, urlRaw)
 
+	// Requests that are not idempotent might have reached Sources even when they failed, so they are never retried
+	// here: the callers check whether the resources of a failed request got created before sending it again. The
+	// idempotency key still gets sent along so that Sources can recognize the replays.
+	key := idempotencyKey(ctx)
+	retryable := isIdempotentRequest(httpMethod, url.Path)
+	endpoint := endpointLabel(url.Path)
+
 	// Perform the actual request.
 	var response *http.Response
 	var responseBody []byte
 	var err error
-	for attempt := 0; attempt < sc.config.SourcesRequestsMaxAttempts; attempt++ {
-		// Create the request inside the loop so we get a fresh body reader for each attempt. Apparently a nil
-		// "*bytes.Buffer" counts as a body, which in turn makes the code panic when creating a new request. That is
-		// why we add another "if" statement to guard us against that.
-		var request *http.Request
-		var reqErr error
-		if bodyBytes != nil {
-			request, reqErr = http.NewRequestWithContext(ctx, httpMethod, urlRaw, bytes.NewReader(bodyBytes))
-		} else {
-			request, reqErr = http.NewRequestWithContext(ctx, httpMethod, urlRaw, nil)
+	var outcome string
+	for attempt := 1; ; attempt++ {
+		// Fail fast instead of hammering Sources while it is down.
+		if !sc.breaker.Allow() {
+			circuitRejectedRequestsCounter.Inc()
+			response, responseBody, err = nil, nil, ErrCircuitOpen
+			outcome = outcomeCircuitOpen
+
+			break
 		}
 
-		if reqErr != nil {
-			return fmt.Errorf(`failed to create request: %w`, reqErr)
+		response, responseBody, err = sc.attemptRequest(ctx, httpMethod, urlRaw, endpoint, authData, bodyBytes, key)
+
+		var statusCode int
+		if response != nil {
+			statusCode = response.StatusCode
 		}
 
-		// Include the headers in the request.
-		sc.addAuthenticationHeaders(request, authData)
+		var retry bool
+		outcome, retry = attemptOutcome(statusCode, err)
+		sc.breaker.recordOutcome(ctx, outcome)
 
-		response, err = http.DefaultClient.Do(request)
+		if err != nil {
+			l.LogWithContext(ctx).Debugf("Failed to send request. Cause: %s", err)
+		} else if outcome != outcomeSuccess {
+			l.LogWithContext(ctx).WithField("response_body", l.RedactJSON(responseBody)).Debugf(`Unexpected status code received. Want "2xx", got "%d"`, statusCode)
+		}
 
-		// The "err" check is to avoid nil dereference errors, since if we attempt checking for the status code
-		// or attempt reading the response body when an error has occurred, the "response" struct might be nil.
-		if err == nil {
-			// Declare an error variable to avoid shadowing the response body one.
-			var readErr error
+		if !retry || !retryable || attempt >= sc.config.SourcesRequestsMaxAttempts {
+			break
+		}
 
-			// Read the response body every time to ensure that the body is completely drained when retrying, or that
-			// it is available if it needs to be printed or used elsewhere. Draining the body is important so that the
-			// connection can be reused.
-			responseBody, readErr = io.ReadAll(response.Body)
-			if readErr != nil {
-				return fmt.Errorf(`failed to read response body: %w`, readErr)
+		// Honor the delay Sources asks for when it is overloaded, up to the max delay.
+		delay := retryDelay(attempt, sc.config.SourcesRetryBaseDelay, sc.config.SourcesRetryMaxDelay)
+		if response != nil {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"), time.Now()); ok {
+				delay = min(retryAfter, sc.config.SourcesRetryMaxDelay)
 			}
+		}
 
-			// Make sure to close the body to avoid memory leaks.
-			if closeErr := response.Body.Close(); closeErr != nil {
-				l.LogWithContext(ctx).Errorf("Failed to close incoming response's body: %s", closeErr)
-			}
+		sourcesRetriesCounter.WithLabelValues(endpoint, httpMethod, outcome).Inc()
+		l.LogWithContext(ctx).Warnf("Failed to send request. Retrying in %s...", delay)
 
-			// When the status code is "2xx", we can simply exit the loop. Otherwise, we need to keep retrying until we
-			// deplete the attempts.
-			if sc.isStatusCodeFamilyOf2xx(response.StatusCode) {
-				break
-			} else if sc.isStatusCodeFamilyOf4xx(response.StatusCode) && response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusRequestTimeout {
-				// 4xx errors (except 429 and 408) are client errors that won't be fixed by retrying.
-				l.LogWithContext(ctx).WithField("response_body", responseBody).Debugf(`Client error received, not retrying. Status code: "%d"`, response.StatusCode)
-				break
-			} else {
-				l.LogWithContext(ctx).WithField("response_body", responseBody).Debugf(`Unexpected status code received. Want "2xx", got "%d"`, response.StatusCode)
-			}
-		} else {
-			l.LogWithContext(ctx).Warn("Failed to send request. Retrying...")
-			l.LogWithContext(ctx).Debugf("Failed to send request. Retrying... Cause: %s", err)
-		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			sourcesRequestsCounter.WithLabelValues(endpoint, httpMethod, outcome).Inc()
 
-		// Sleep between retry attempts to avoid overwhelming the server.
-		time.Sleep(time.Second)
+			return fmt.Errorf("failed to send request: %w", ctx.Err())
+		}
 	}
 
+	sourcesRequestsCounter.WithLabelValues(endpoint, httpMethod, outcome).Inc()
+
 	// In the case in which we deplete all the attempts, we have to return the error and stop the execution here.
 	if err != nil || response == nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 
 	// Make sure that the status code is a "2xx" one.
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf(`%w. Response body: %s`, ErrNotFound, string(responseBody))
+	}
+
 	if !sc.isStatusCodeFamilyOf2xx(response.StatusCode) {
 		return fmt.Errorf(`unexpected status code received. Want "2xx", got "%d". Response body: %s`, response.StatusCode, string(responseBody))
 	}
@@ -256,6 +498,61 @@ func (sc *sourcesClient) sendRequest(ctx context.Context, httpMethod string, url
 	return nil
 }
 
+// attemptRequest sends a single attempt of the request, which times out on its own so that a hung attempt does not
+// eat up the time of the next ones.
+// returns: the response along with its fully read body, or an error if the request could not be sent or the response
+// could not be read.
+func (sc *sourcesClient) attemptRequest(ctx context.Context, httpMethod, urlRaw, endpoint string, authData *AuthenticationData, bodyBytes []byte, idempotencyKey string) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, sc.config.SourcesRequestTimeout)
+	defer cancel()
+
+	// Apparently a nil "*bytes.Reader" counts as a body, which in turn makes the code panic when creating a new
+	// request. That is why we add another "if" statement to guard us against that.
+	var request *http.Request
+	var err error
+	if bodyBytes != nil {
+		request, err = http.NewRequestWithContext(ctx, httpMethod, urlRaw, bytes.NewReader(bodyBytes))
+	} else {
+		request, err = http.NewRequestWithContext(ctx, httpMethod, urlRaw, nil)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf(`failed to create request: %w`, err)
+	}
+
+	// Include the headers in the request.
+	sc.addAuthenticationHeaders(request, authData)
+	if idempotencyKey != "" {
+		request.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+
+	start := time.Now()
+	defer func() {
+		sourcesRequestDuration.WithLabelValues(endpoint, httpMethod).Observe(time.Since(start).Seconds())
+	}()
+
+	response, err := sc.httpClient.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read the response body every time to ensure that the body is completely drained when retrying, or that it is
+	// available if it needs to be printed or used elsewhere. Draining the body is important so that the connection
+	// can be reused.
+	responseBody, err := io.ReadAll(response.Body)
+
+	// Make sure to close the body to avoid memory leaks.
+	if closeErr := response.Body.Close(); closeErr != nil {
+		l.LogWithContext(ctx).Errorf("Failed to close incoming response's body: %s", closeErr)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf(`failed to read response body: %w`, err)
+	}
+
+	return response, responseBody, nil
+}
+
 // isStatusCodeFamilyOf2xx returns true if the given status code is a 2xx status code.
 func (sc *sourcesClient) isStatusCodeFamilyOf2xx(statusCode int) bool {
 	return statusCode >= 200 && statusCode < 300
@@ -285,26 +582,36 @@ func (sc *sourcesClient) addAuthenticationHeaders(request *http.Request, authDat
 }
 
 // HealthCheck performs a lightweight health check against the Sources API
-// to verify connectivity and API availability
+// to verify connectivity and API availability. The result feeds the circuit
+// breaker, so a successful check closes it.
 func HealthCheck(ctx context.Context) error {
-	conf := config.Get()
-	reqURL, err := url.Parse(fmt.Sprintf(
-		"%v://%v:%v/api/sources/v3.1/openapi.json", conf.SourcesScheme, conf.SourcesHost, conf.SourcesPort,
-	))
+	sourcesClient, err := Client()
 	if err != nil {
-		return fmt.Errorf("invalid sources API configuration: %w", err)
+		return err
 	}
 
-	req := &http.Request{
-		Method: http.MethodGet,
-		URL:    reqURL,
+	err = sourcesClient.healthCheck(ctx)
+	if err != nil {
+		sourcesClient.breaker.RecordFailure()
+	} else {
+		sourcesClient.breaker.RecordSuccess()
 	}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	return err
+}
+
+// healthCheck performs the health check without reporting it to the circuit
+// breaker.
+func (sc *sourcesClient) healthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sc.baseV31URL.JoinPath("/openapi.json").String(), nil)
+	if err != nil {
+		return fmt.Errorf("invalid sources API configuration: %w", err)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := sc.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("sources API unreachable: %w", err)
 	}
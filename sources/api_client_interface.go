@@ -9,16 +9,40 @@ import (
 // RestClient represents the Sources' endpoints that are required for the Superkey to be able to talk to the Sources'
 // API.
 type RestClient interface {
+	// BulkCreateEnabled returns true when the authentications are meant to be created through the bulk create endpoint.
+	BulkCreateEnabled() bool
 	// TriggerSourceAvailabilityCheck triggers an availability status check in the Sources API for the given source.
 	TriggerSourceAvailabilityCheck(ctx context.Context, authData *AuthenticationData, sourceId string) error
 	// CreateAuthentication creates an authentication in Sources.
 	CreateAuthentication(ctx context.Context, authData *AuthenticationData, sourcesAuthentication *model.AuthenticationCreateRequest) (*model.AuthenticationResponse, error)
+	// BulkCreate creates the given resources in Sources in a single transaction.
+	BulkCreate(ctx context.Context, authData *AuthenticationData, bulkCreateRequest *BulkCreateRequest) (*BulkCreateResponse, error)
+	// DeleteAuthentication removes an authentication from Sources, along with its links to the applications.
+	DeleteAuthentication(ctx context.Context, authData *AuthenticationData, authId string) error
 	// CreateApplicationAuthentication links the created authentication with an application in Sources.
 	CreateApplicationAuthentication(ctx context.Context, authData *AuthenticationData, appAuthCreateRequest *model.ApplicationAuthenticationCreateRequest) error
 	// PatchApplication modifies an application in Sources.
 	PatchApplication(ctx context.Context, authData *AuthenticationData, appId string, patchApplicationRequest *PatchApplicationRequest) error
+	// ResetApplicationExtra replaces the extra of an application in Sources. The keys set to nil are meant to be
+	// removed, but Sources might keep them with a null value, so the readers must treat null keys as absent.
+	ResetApplicationExtra(ctx context.Context, authData *AuthenticationData, appId string, extra map[string]interface{}) error
+	// GetApplication fetches an application from Sources.
+	GetApplication(ctx context.Context, authData *AuthenticationData, appId string) (*ApplicationResponse, error)
+	// ListApplicationAuthentications fetches the authentications linked to an application in Sources.
+	ListApplicationAuthentications(ctx context.Context, authData *AuthenticationData, appId string) ([]AuthenticationResponse, error)
+	// ListSourceApplications fetches the applications of a source in Sources.
+	ListSourceApplications(ctx context.Context, authData *AuthenticationData, sourceId string) ([]ApplicationResponse, error)
+	// GetSource fetches a source from Sources.
+	GetSource(ctx context.Context, authData *AuthenticationData, sourceId string) (*SourceResponse, error)
+	// GetApplicationType fetches an application type from Sources by its name.
+	GetApplicationType(ctx context.Context, authData *AuthenticationData, name string) (*ApplicationTypeResponse, error)
 	// PatchSource modifies an application in Sources.
 	PatchSource(ctx context.Context, authData *AuthenticationData, sourceId string, patchSourceRequest *PatchSourceRequest) error
+	// UpdateApplicationAvailability sets the availability status of an application, through the Sources status topic
+	// when enabled, and through the REST API otherwise or when the publishing fails.
+	UpdateApplicationAvailability(ctx context.Context, authData *AuthenticationData, appId, status, statusError string, extra map[string]interface{}) error
+	// UpdateSourceAvailability sets the availability status of a source, like "UpdateApplicationAvailability".
+	UpdateSourceAvailability(ctx context.Context, authData *AuthenticationData, sourceId, status string) error
 	// GetInternalAuthentication fetches an authentication using the internal Sources' endpoint, which ensure that the authentication will have the password as well.
 	GetInternalAuthentication(ctx context.Context, authData *AuthenticationData, authId string) (*model.AuthenticationInternalResponse, error)
 }
@@ -0,0 +1,247 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// The states of the circuit breaker.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned when a request is not sent because the circuit breaker is open.
+var ErrCircuitOpen = errors.New("the Sources API circuit breaker is open")
+
+// circuitStateValues are the values the states are reported with in the metrics.
+var circuitStateValues = map[string]float64{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+var (
+	circuitStateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sources_superkey_sources_api_circuit_state",
+		Help: "The state of the Sources API circuit breaker: 0 closed, 1 half open, 2 open",
+	})
+	circuitTransitionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_sources_api_circuit_transitions",
+		Help: "The number of times the Sources API circuit breaker changed its state, by new state",
+	}, []string{"state"})
+	circuitRejectedRequestsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sources_superkey_sources_api_circuit_rejected_requests",
+		Help: "The number of requests to the Sources API that were not sent because the circuit breaker was open",
+	})
+)
+
+// NewCircuitBreaker returns a closed circuit breaker which opens after the given number of consecutive failures, and
+// stays open for the given duration before letting a trial request through.
+func NewCircuitBreaker(threshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		state:        CircuitClosed,
+		threshold:    threshold,
+		openDuration: openDuration,
+		changed:      make(chan struct{}),
+	}
+}
+
+// Allow returns true when a request can be sent. Once the open duration has elapsed, a single trial request is
+// allowed until its outcome is recorded.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return false
+		}
+
+		b.transition(CircuitHalfOpen)
+		b.trialInFlight = true
+
+		return true
+	default:
+		if b.trialInFlight {
+			return false
+		}
+
+		b.trialInFlight = true
+
+		return true
+	}
+}
+
+// RecordSuccess closes the circuit breaker.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trialInFlight = false
+
+	if b.state != CircuitClosed {
+		b.transition(CircuitClosed)
+	}
+}
+
+// RecordFailure opens the circuit breaker when the failed request was the trial one, or when the consecutive failures
+// reach the threshold.
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trialInFlight = false
+
+	switch b.state {
+	case CircuitHalfOpen:
+		b.open()
+	case CircuitClosed:
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// releaseTrial lets another trial request through when the current one was abandoned before its outcome was known.
+func (b *CircuitBreaker) releaseTrial() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialInFlight = false
+}
+
+// State returns the current state of the circuit breaker.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Wait blocks until the circuit breaker is closed or the context is done. Whenever the open duration elapses, the
+// given probe is run as the trial request, so that the breaker closes as soon as the API recovers even when nothing
+// else is being sent.
+func (b *CircuitBreaker) Wait(ctx context.Context, probe func(ctx context.Context) error) error {
+	for {
+		b.mu.Lock()
+		state := b.state
+		changed := b.changed
+		retryIn := b.openDuration - time.Since(b.openedAt)
+		b.mu.Unlock()
+
+		if state == CircuitClosed {
+			return nil
+		}
+
+		if state == CircuitOpen && retryIn <= 0 && b.Allow() {
+			if probe(ctx) == nil {
+				b.RecordSuccess()
+			} else {
+				b.RecordFailure()
+			}
+
+			continue
+		}
+
+		// Wake up when the state changes, or when the trial request can be sent.
+		if retryIn <= 0 {
+			retryIn = b.openDuration
+		}
+
+		timer := time.NewTimer(retryIn)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// open opens the circuit breaker. The caller must hold the lock.
+func (b *CircuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.transition(CircuitOpen)
+}
+
+// transition moves the circuit breaker to the given state, and wakes up the waiters. The caller must hold the lock.
+func (b *CircuitBreaker) transition(state string) {
+	b.state = state
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	circuitStateGauge.Set(circuitStateValues[state])
+	circuitTransitionsCounter.WithLabelValues(state).Inc()
+
+	switch state {
+	case CircuitOpen:
+		l.Log.Warnf("Sources API circuit breaker opened after %d consecutive failures", b.failures)
+	case CircuitHalfOpen:
+		l.Log.Info("Sources API circuit breaker half open, sending a trial request")
+	default:
+		l.Log.Info("Sources API circuit breaker closed")
+	}
+}
+
+// recordOutcome reports the outcome of an attempt to the circuit breaker. Only the server and network errors count as
+// failures, since any other response proves that Sources is up. The attempts that failed because the caller gave up
+// tell nothing about Sources, so they are not counted.
+func (b *CircuitBreaker) recordOutcome(ctx context.Context, outcome string) {
+	switch {
+	case ctx.Err() != nil:
+		b.releaseTrial()
+	case outcome == outcomeServerError || outcome == outcomeNetworkError:
+		b.RecordFailure()
+	default:
+		b.RecordSuccess()
+	}
+}
+
+// CircuitState returns the state of the circuit breaker of the shared Sources client.
+func CircuitState() string {
+	sourcesClient, err := Client()
+	if err != nil {
+		return CircuitClosed
+	}
+
+	return sourcesClient.breaker.State()
+}
+
+// WaitUntilAvailable blocks while the circuit breaker of the shared Sources client is open, probing the Sources API
+// with health checks to close it as soon as the API recovers.
+func WaitUntilAvailable(ctx context.Context) error {
+	sourcesClient, err := Client()
+	if err != nil {
+		return err
+	}
+
+	if sourcesClient.breaker.State() == CircuitClosed {
+		return nil
+	}
+
+	l.Log.Warn("Pausing until the Sources API is available again")
+
+	err = sourcesClient.breaker.Wait(ctx, sourcesClient.healthCheck)
+	if err != nil {
+		return err
+	}
+
+	l.Log.Info("Sources API available again, resuming")
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// breakerConfig makes the circuit breaker open after two failed attempts, and let a trial request through shortly
+// after.
+func breakerConfig(conf *config.SuperKeyWorkerConfig) {
+	conf.SourcesRequestsMaxAttempts = 1
+	conf.SourcesBreakerThreshold = 2
+	conf.SourcesBreakerOpenDuration = 10 * time.Millisecond
+}
+
+// TestBreakerOpensAndCloses tests that the circuit breaker opens after consecutive server errors, rejects the
+// requests without sending them while open, and gets closed by a health check once Sources recovers.
+func TestBreakerOpensAndCloses(t *testing.T) {
+	server, client := newTestClient(t, breakerConfig)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	appPath := "/api/sources/v3.1/applications/" + appId
+	server.Fail(sourcestest.Failure{Method: http.MethodGet, Path: appPath, StatusCode: http.StatusServiceUnavailable, Times: 2})
+
+	ctx := context.Background()
+	authData := &AuthenticationData{OrgId: testOrgId}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetApplication(ctx, authData, appId)
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("want a server error, got %v", err)
+		}
+	}
+
+	if state := client.breaker.State(); state != CircuitOpen {
+		t.Fatalf(`want the "%s" state, got "%s"`, CircuitOpen, state)
+	}
+
+	_, err := client.GetApplication(ctx, authData, appId)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want an open circuit error, got %v", err)
+	}
+
+	if got := len(server.RequestsTo(http.MethodGet, appPath)); got != 2 {
+		t.Errorf("want the rejected request not to reach Sources, got %d requests", got)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = client.breaker.Wait(waitCtx, client.healthCheck)
+	if err != nil {
+		t.Fatalf("want the breaker to close, got %s", err)
+	}
+
+	_, err = client.GetApplication(ctx, authData, appId)
+	if err != nil {
+		t.Errorf("want no error once the breaker is closed, got %s", err)
+	}
+}
+
+// TestBreakerIgnoresClientErrors tests that the client errors do not open the circuit breaker, since they prove that
+// Sources is up.
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	server, client := newTestClient(t, breakerConfig)
+
+	ctx := context.Background()
+	authData := &AuthenticationData{OrgId: testOrgId}
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetApplication(ctx, authData, "missing")
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("want a not found error, got %v", err)
+		}
+	}
+
+	if state := client.breaker.State(); state != CircuitClosed {
+		t.Errorf(`want the "%s" state, got "%s"`, CircuitClosed, state)
+	}
+
+	if got := len(server.RequestsTo(http.MethodGet, "/api/sources/v3.1/applications/missing")); got != 3 {
+		t.Errorf("want 3 requests, got %d", got)
+	}
+}
+
+// TestSetClientReplacesTheBreaker tests that every client gets its own circuit breaker, and that the shared client
+// and its breaker get replaced by "SetClient".
+func TestSetClientReplacesTheBreaker(t *testing.T) {
+	server := sourcestest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.Fail(sourcestest.Failure{Method: http.MethodGet, StatusCode: http.StatusServiceUnavailable, Times: 2})
+
+	conf := server.Config()
+	breakerConfig(conf)
+	conf.SourcesBreakerOpenDuration = time.Minute
+
+	err := SetClient(conf)
+	if err != nil {
+		t.Fatalf("unable to set the shared client: %s", err)
+	}
+
+	shared, err := Client()
+	if err != nil {
+		t.Fatalf("unable to get the shared client: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _ = shared.GetApplication(context.Background(), &AuthenticationData{OrgId: testOrgId}, "1")
+	}
+
+	if state := CircuitState(); state != CircuitOpen {
+		t.Fatalf(`want the "%s" state, got "%s"`, CircuitOpen, state)
+	}
+
+	other, err := NewSourcesClient(conf)
+	if err != nil {
+		t.Fatalf("unable to create the Sources client: %s", err)
+	}
+
+	if state := other.breaker.State(); state != CircuitClosed {
+		t.Errorf(`want the breaker of another client to be "%s", got "%s"`, CircuitClosed, state)
+	}
+
+	err = SetClient(conf)
+	if err != nil {
+		t.Fatalf("unable to set the shared client: %s", err)
+	}
+
+	if state := CircuitState(); state != CircuitClosed {
+		t.Errorf(`want the breaker of the new shared client to be "%s", got "%s"`, CircuitClosed, state)
+	}
+}
@@ -0,0 +1,40 @@
+package sources
+
+import (
+	"os"
+	"testing"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// testOrgId is the organization the fixtures of the fake Sources API belong to.
+const testOrgId = "12345"
+
+func TestMain(m *testing.M) {
+	l.InitLogger(&config.SuperKeyWorkerConfig{})
+
+	os.Exit(m.Run())
+}
+
+// newTestClient starts a fake Sources API, and returns it along with a client created from the given configuration,
+// pointed to the fake.
+func newTestClient(t *testing.T, configure func(conf *config.SuperKeyWorkerConfig)) (*sourcestest.Server, *sourcesClient) {
+	t.Helper()
+
+	server := sourcestest.NewServer()
+	t.Cleanup(server.Close)
+
+	conf := server.Config()
+	if configure != nil {
+		configure(conf)
+	}
+
+	client, err := NewSourcesClient(conf)
+	if err != nil {
+		t.Fatalf("unable to create the Sources client: %s", err)
+	}
+
+	return server, client
+}
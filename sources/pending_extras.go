@@ -0,0 +1,237 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// pendingExtrasBucket is the bucket the pending extras are stored in, keyed by application.
+var pendingExtrasBucket = []byte("pending_extras")
+
+// pendingExtraRetryInterval is how often the application extras that could not be stored are sent again.
+const pendingExtraRetryInterval = 30 * time.Second
+
+// ErrExtraPending is wrapped by the errors of the availability status updates that went through while the
+// application's extra could not be stored. The extra is kept and sent again in the background, so that the state it
+// holds, such as the completed steps a teardown needs, does not get lost while the REST API is down.
+var ErrExtraPending = errors.New("the application's extra could not be stored, it will be sent again in the background")
+
+var pendingExtrasGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "sources_superkey_pending_application_extras",
+	Help: "The number of applications whose extra could not be stored in Sources and waits to be sent again",
+})
+
+// newPendingExtras returns an empty set of pending extras.
+func newPendingExtras() *pendingExtras {
+	return &pendingExtras{extras: make(map[string]*pendingExtra)}
+}
+
+// PersistPendingExtras makes the shared client keep its pending extras in the database file at the given path, so
+// that they still get sent after a restart, and loads the ones a previous run left behind.
+// returns: the function that closes the database file, or an error when it cannot be opened or read.
+func PersistPendingExtras(path string) (func() error, error) {
+	sourcesClient, err := Client()
+	if err != nil {
+		return nil, err
+	}
+
+	err = sourcesClient.pendingExtras.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return sourcesClient.pendingExtras.close, nil
+}
+
+// open opens, or creates, the database file at the given path, and loads the extras stored in it.
+func (p *pendingExtras) open(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf(`unable to open the pending extras file "%s": %w`, path, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(pendingExtrasBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(appId, raw []byte) error {
+			stored := storedPendingExtra{}
+			err := json.Unmarshal(raw, &stored)
+			if err != nil {
+				return fmt.Errorf(`unable to unmarshal the pending extra of the application "%s": %w`, appId, err)
+			}
+
+			p.extras[string(appId)] = &pendingExtra{authData: AuthenticationData{OrgId: stored.OrgId}, extra: stored.Extra}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf(`unable to load the pending extras file "%s": %w`, path, err)
+	}
+
+	p.db = db
+	pendingExtrasGauge.Set(float64(len(p.extras)))
+
+	return nil
+}
+
+// close closes the database file, if any.
+func (p *pendingExtras) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db == nil {
+		return nil
+	}
+
+	err := p.db.Close()
+	p.db = nil
+
+	return err
+}
+
+// store writes the extra waiting for the application to the database file, if any, or removes it from the file when
+// there is none anymore. A failure only gets logged, since the extra is still kept in memory. The caller must hold
+// the lock.
+func (p *pendingExtras) store(appId string) {
+	if p.db == nil {
+		return
+	}
+
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pendingExtrasBucket)
+
+		pending, ok := p.extras[appId]
+		if !ok {
+			return bucket.Delete([]byte(appId))
+		}
+
+		raw, err := json.Marshal(storedPendingExtra{OrgId: pending.authData.OrgId, Extra: pending.extra})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(appId), raw)
+	})
+	if err != nil {
+		l.LogWithContext(l.WithApplicationId(context.Background(), appId)).Errorf("Unable to store the pending extra of the application on disk, it would not survive a restart: %s", err)
+	}
+}
+
+// put keeps the extra of the application until it gets sent again. The keys are merged with the ones of the extra
+// already waiting for the application, if any, the latest values winning.
+func (p *pendingExtras) put(authData *AuthenticationData, appId string, extra map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending, ok := p.extras[appId]
+	if !ok {
+		pending = &pendingExtra{extra: make(map[string]interface{})}
+		p.extras[appId] = pending
+	}
+
+	pending.authData = *authData
+	for key, value := range extra {
+		pending.extra[key] = value
+	}
+
+	p.store(appId)
+	pendingExtrasGauge.Set(float64(len(p.extras)))
+}
+
+// supersede drops the given keys from the extra waiting for the application, since they were stored with newer
+// values in the meantime, so that sending the pending extra does not overwrite them.
+func (p *pendingExtras) supersede(appId string, extra map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending, ok := p.extras[appId]
+	if !ok {
+		return
+	}
+
+	for key := range extra {
+		delete(pending.extra, key)
+	}
+
+	if len(pending.extra) == 0 {
+		delete(p.extras, appId)
+	}
+
+	p.store(appId)
+	pendingExtrasGauge.Set(float64(len(p.extras)))
+}
+
+// snapshot returns a copy of the pending extras, by application.
+func (p *pendingExtras) snapshot() map[string]pendingExtra {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make(map[string]pendingExtra, len(p.extras))
+	for appId, pending := range p.extras {
+		extra := make(map[string]interface{}, len(pending.extra))
+		for key, value := range pending.extra {
+			extra[key] = value
+		}
+
+		snapshot[appId] = pendingExtra{authData: pending.authData, extra: extra}
+	}
+
+	return snapshot
+}
+
+// RetryPendingExtras sends the application extras that could not be stored again, every so often, until the given
+// context is done. The pending extras only live in memory unless "PersistPendingExtras" was called.
+func RetryPendingExtras(ctx context.Context) {
+	sourcesClient, err := Client()
+	if err != nil {
+		l.Log.Errorf("Unable to retry the pending application extras: %s", err)
+		return
+	}
+
+	ticker := time.NewTicker(pendingExtraRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sourcesClient.sendPendingExtras(ctx)
+		}
+	}
+}
+
+// sendPendingExtras sends the pending extras again, unless the circuit breaker says that the Sources API is down. The
+// extras that get stored are dropped from the pending ones as they supersede themselves.
+func (sc *sourcesClient) sendPendingExtras(ctx context.Context) {
+	if sc.breaker.State() != CircuitClosed {
+		return
+	}
+
+	for appId, pending := range sc.pendingExtras.snapshot() {
+		authData := pending.authData
+
+		err := sc.ResetApplicationExtra(ctx, &authData, appId, pending.extra)
+		if err != nil {
+			l.LogWithContext(l.WithApplicationId(ctx, appId)).Warnf("Unable to store the pending extra of the application, retrying later: %s", err)
+			continue
+		}
+
+		l.LogWithContext(l.WithApplicationId(ctx, appId)).Info("Stored the pending extra of the application")
+	}
+}
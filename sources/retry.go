@@ -0,0 +1,169 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// idempotencyKeyHeader is the header the idempotency keys are sent in.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// The outcomes of the requests sent to the Sources API.
+const (
+	outcomeSuccess      = "success"
+	outcomeClientError  = "client_error"
+	outcomeServerError  = "server_error"
+	outcomeNetworkError = "network_error"
+	outcomeCircuitOpen  = "circuit_open"
+)
+
+// idempotencyKeyCtxKeyType defines the type for the idempotency key that will ensure type safety when storing or
+// fetching the variable to/from the context.
+type idempotencyKeyCtxKeyType string
+
+// idempotencyKeyCtxKey defines the key to be used to store the idempotency key of the requests.
+const idempotencyKeyCtxKey idempotencyKeyCtxKeyType = "idempotency_key"
+
+// numericPathSegment matches the identifiers in the paths, so that they can be replaced in the metrics' labels.
+var numericPathSegment = regexp.MustCompile(`/\d+(/|$)`)
+
+var (
+	sourcesRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_sources_api_requests",
+		Help: "The number of requests sent to the Sources API, retries excluded, by endpoint, method and outcome",
+	}, []string{"endpoint", "method", "outcome"})
+	sourcesRetriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_sources_api_retries",
+		Help: "The number of retried requests to the Sources API, by endpoint, method and outcome of the failed attempt",
+	}, []string{"endpoint", "method", "outcome"})
+	sourcesRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sources_superkey_sources_api_request_duration_seconds",
+		Help:    "The duration of every attempt of the requests sent to the Sources API, by endpoint and method",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint", "method"})
+)
+
+// WithIdempotencyKey creates a new context by copying the given context and appending the idempotency key to it. The
+// requests sent with the context carry the key, so that Sources can recognize their replays if it ever honors it. The
+// key does not make a request retryable. The key should come from "OperationIdempotencyKey".
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtxKey, key)
+}
+
+// idempotencyKey returns the idempotency key stored in the context, if any.
+func idempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtxKey).(string)
+
+	return key
+}
+
+// OperationIdempotencyKey returns the idempotency key of a request sent on behalf of the given step of an operation.
+// The retries of the request share the key, while the requests of a different operation, step or attempt get a
+// different one, even when their bodies are identical.
+func OperationIdempotencyKey(guid, operation, step string, attempt int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%d", guid, operation, step, attempt)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// isIdempotentRequest returns true when sending the same request more than once has the same effect as sending it
+// once. The patches the worker sends set absolute values, and triggering the availability check of a source twice only
+// checks it twice, so replaying them is harmless too. The other posts create resources, which a replay that reached
+// Sources would duplicate, since nothing in the Sources API documents that it honors the idempotency keys.
+func isIdempotentRequest(httpMethod, path string) bool {
+	switch httpMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(path, "/check_availability")
+	default:
+		return false
+	}
+}
+
+// attemptOutcome classifies the outcome of an attempt, and tells whether it is worth retrying.
+func attemptOutcome(statusCode int, err error) (string, bool) {
+	switch {
+	case err != nil:
+		return outcomeNetworkError, true
+	case statusCode >= 200 && statusCode < 300:
+		return outcomeSuccess, false
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout:
+		// 429 and 408 are the only client errors that might go away by retrying.
+		return outcomeClientError, true
+	case statusCode >= 400 && statusCode < 500:
+		return outcomeClientError, false
+	default:
+		return outcomeServerError, true
+	}
+}
+
+// retryDelay returns how long to wait before the given attempt's retry: an exponential backoff from the base delay up
+// to the max delay, with a random jitter of up to half of it so that the clients do not retry in lockstep.
+func retryDelay(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + rand.N(half+1)
+}
+
+// parseRetryAfter returns the delay the "Retry-After" header asks for, which is either a number of seconds or an HTTP
+// date.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := date.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay, true
+}
+
+// endpointLabel returns the path with its identifiers replaced, so that it can be used as a metric label.
+func endpointLabel(path string) string {
+	// The replacement consumes the slash that follows the identifier, so consecutive identifiers need two passes.
+	for numericPathSegment.MatchString(path) {
+		path = numericPathSegment.ReplaceAllString(path, "/:id$1")
+	}
+
+	return path
+}
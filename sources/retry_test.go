@@ -0,0 +1,134 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/sources-api-go/model"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// TestAvailabilityCheckIsRetried tests that triggering an availability check gets retried after a server error, since
+// triggering it twice is harmless, and that every attempt carries the same idempotency key.
+func TestAvailabilityCheckIsRetried(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	sourceId := server.AddSource(sourcestest.Source{OrgID: testOrgId})
+	checkPath := "/api/sources/v3.1/sources/" + sourceId + "/check_availability"
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: checkPath, StatusCode: http.StatusServiceUnavailable, Times: 1})
+
+	key := OperationIdempotencyKey("guid", "Application.create", "check_availability", 0)
+	ctx := WithIdempotencyKey(context.Background(), key)
+
+	err := client.TriggerSourceAvailabilityCheck(ctx, &AuthenticationData{OrgId: testOrgId}, sourceId)
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	requests := server.RequestsTo(http.MethodPost, checkPath)
+	if len(requests) != 2 {
+		t.Fatalf("want 2 attempts, got %d", len(requests))
+	}
+
+	for _, request := range requests {
+		if got := request.Header.Get(idempotencyKeyHeader); got != key {
+			t.Errorf(`want the "%s" idempotency key, got "%s"`, key, got)
+		}
+	}
+
+	source, _ := server.Source(sourceId)
+	if source.AvailabilityChecks != 1 {
+		t.Errorf("want 1 availability check, got %d", source.AvailabilityChecks)
+	}
+}
+
+// TestKeyedPostIsNotRetried tests that a POST which creates a resource is sent once even when it carries an
+// idempotency key, since it might have reached Sources even though it failed.
+func TestKeyedPostIsNotRetried(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	authPath := "/api/sources/v3.1/authentications"
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: authPath, StatusCode: http.StatusServiceUnavailable, Times: 1})
+
+	key := OperationIdempotencyKey("guid", "Application.create", "create_authentication", 0)
+	ctx := WithIdempotencyKey(context.Background(), key)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	_, err := client.CreateAuthentication(ctx, &AuthenticationData{OrgId: testOrgId}, &model.AuthenticationCreateRequest{
+		AuthType:      "cloud-meter-arn",
+		ResourceType:  "Application",
+		ResourceIDRaw: appId,
+	})
+	if err == nil {
+		t.Fatal("want an error, got none")
+	}
+
+	requests := server.RequestsTo(http.MethodPost, authPath)
+	if len(requests) != 1 {
+		t.Fatalf("want 1 attempt, got %d", len(requests))
+	}
+
+	if got := requests[0].Header.Get(idempotencyKeyHeader); got != key {
+		t.Errorf(`want the "%s" idempotency key, got "%s"`, key, got)
+	}
+}
+
+// TestPatchIsRetried tests that the patches get retried without an idempotency key, and that the client errors are
+// not retried.
+func TestPatchIsRetried(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	appPath := "/api/sources/v3.1/applications/" + appId
+	server.Fail(sourcestest.Failure{Method: http.MethodPatch, Path: appPath, StatusCode: http.StatusBadGateway, Times: 2})
+
+	authData := &AuthenticationData{OrgId: testOrgId}
+	err := client.ResetApplicationExtra(context.Background(), authData, appId, map[string]interface{}{"key": "value"})
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if got := len(server.RequestsTo(http.MethodPatch, appPath)); got != 3 {
+		t.Errorf("want 3 attempts, got %d", got)
+	}
+
+	application, _ := server.Application(appId)
+	if application.Extra["key"] != "value" {
+		t.Errorf(`want the "value" extra, got "%v"`, application.Extra["key"])
+	}
+
+	server.Fail(sourcestest.Failure{Method: http.MethodPatch, Path: appPath, StatusCode: http.StatusBadRequest, Times: 1})
+
+	err = client.ResetApplicationExtra(context.Background(), authData, appId, map[string]interface{}{"key": "other"})
+	if err == nil {
+		t.Fatal("want an error, got none")
+	}
+
+	if got := len(server.RequestsTo(http.MethodPatch, appPath)); got != 4 {
+		t.Errorf("want the client error not to be retried, got %d attempts in total", got)
+	}
+}
+
+// TestOperationIdempotencyKey tests that the idempotency keys are the same for the same step of the same attempt of
+// an operation, and differ otherwise.
+func TestOperationIdempotencyKey(t *testing.T) {
+	key := OperationIdempotencyKey("guid", "Application.create", "create_authentication", 0)
+
+	if got := OperationIdempotencyKey("guid", "Application.create", "create_authentication", 0); got != key {
+		t.Errorf(`want the same key for the same step, got "%s" and "%s"`, key, got)
+	}
+
+	others := map[string]string{
+		"another guid":      OperationIdempotencyKey("other", "Application.create", "create_authentication", 0),
+		"another operation": OperationIdempotencyKey("guid", "Application.update", "create_authentication", 0),
+		"another step":      OperationIdempotencyKey("guid", "Application.create", "link_authentication", 0),
+		"another attempt":   OperationIdempotencyKey("guid", "Application.create", "create_authentication", 1),
+	}
+
+	for name, other := range others {
+		if other == key {
+			t.Errorf("want a different key for %s, got the same one", name)
+		}
+	}
+}
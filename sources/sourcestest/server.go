@@ -0,0 +1,788 @@
+package sourcestest
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+)
+
+// The base paths of the endpoints served by the fake Sources API.
+const (
+	v31Path        = "/api/sources/v3.1"
+	internalV2Path = "/internal/v2.0"
+)
+
+// NewServer starts a fake Sources API with no state. The caller must close it once done.
+func NewServer() *Server {
+	s := &Server{
+		applications:               make(map[string]*Application),
+		sources:                    make(map[string]*Source),
+		authentications:            make(map[string]*Authentication),
+		applicationAuthentications: make(map[string]*ApplicationAuthentication),
+		applicationTypes:           make(map[string]*ApplicationType),
+	}
+
+	mux := http.NewServeMux()
+
+	// The health check does not carry any headers.
+	mux.HandleFunc("GET "+v31Path+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"openapi": "3.0.0"})
+	})
+
+	mux.Handle("GET "+v31Path+"/applications/{id}", s.authenticated(s.getApplication))
+	mux.Handle("PATCH "+v31Path+"/applications/{id}", s.authenticated(s.patchApplication))
+	mux.Handle("GET "+v31Path+"/applications/{id}/authentications", s.authenticated(s.listApplicationAuthentications))
+	mux.Handle("GET "+v31Path+"/application_types", s.authenticated(s.listApplicationTypes))
+	mux.Handle("GET "+v31Path+"/sources/{id}", s.authenticated(s.getSource))
+	mux.Handle("PATCH "+v31Path+"/sources/{id}", s.authenticated(s.patchSource))
+	mux.Handle("GET "+v31Path+"/sources/{id}/applications", s.authenticated(s.listSourceApplications))
+	mux.Handle("POST "+v31Path+"/sources/{id}/check_availability", s.authenticated(s.checkAvailability))
+	mux.Handle("POST "+v31Path+"/authentications", s.authenticated(s.createAuthentication))
+	mux.Handle("DELETE "+v31Path+"/authentications/{id}", s.authenticated(s.deleteAuthentication))
+	mux.Handle("POST "+v31Path+"/application_authentications", s.authenticated(s.createApplicationAuthentication))
+	mux.Handle("POST "+v31Path+"/bulk_create", s.authenticated(s.bulkCreate))
+	mux.Handle("GET "+internalV2Path+"/authentications/{id}", s.authenticated(s.getInternalAuthentication))
+	mux.Handle("GET "+internalV2Path+"/authentications/{id}/{$}", s.authenticated(s.getInternalAuthentication))
+
+	s.Server = httptest.NewServer(s.recorded(mux))
+
+	return s
+}
+
+// Configure points the Sources settings of the given configuration to the fake Sources API, so that the clients
+// created from it talk to the fake.
+func (s *Server) Configure(conf *config.SuperKeyWorkerConfig) {
+	serverUrl, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(serverUrl.Port())
+
+	conf.SourcesScheme = serverUrl.Scheme
+	conf.SourcesHost = serverUrl.Hostname()
+	conf.SourcesPort = port
+	conf.SourcesPSK = s.PSK
+}
+
+// Config returns a configuration pointing to the fake Sources API, with short retry delays so that the tests do not
+// wait for long.
+func (s *Server) Config() *config.SuperKeyWorkerConfig {
+	conf := &config.SuperKeyWorkerConfig{
+		SourcesRequestsMaxAttempts: 3,
+		SourcesRequestTimeout:      5 * time.Second,
+		SourcesRetryBaseDelay:      time.Millisecond,
+		SourcesRetryMaxDelay:       5 * time.Millisecond,
+		SourcesBreakerThreshold:    5,
+		SourcesBreakerOpenDuration: time.Minute,
+		SourcesMaxIdleConns:        2,
+		SourcesIdleConnTimeout:     time.Second,
+	}
+	s.Configure(conf)
+
+	return conf
+}
+
+// AddSource stores the given source, and returns its ID. An ID gets generated when the source does not have one.
+func (s *Server) AddSource(source Source) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source.ID == "" {
+		source.ID = s.newId()
+	}
+
+	s.sources[source.ID] = &source
+
+	return source.ID
+}
+
+// AddApplication stores the given application, and returns its ID. An ID gets generated when the application does
+// not have one.
+func (s *Server) AddApplication(application Application) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if application.ID == "" {
+		application.ID = s.newId()
+	}
+
+	application.Extra = copyExtra(application.Extra)
+	s.applications[application.ID] = &application
+
+	return application.ID
+}
+
+// AddApplicationType stores the given application type, and returns its ID. An ID gets generated when the
+// application type does not have one.
+func (s *Server) AddApplicationType(applicationType ApplicationType) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if applicationType.ID == "" {
+		applicationType.ID = s.newId()
+	}
+
+	s.applicationTypes[applicationType.ID] = &applicationType
+
+	return applicationType.ID
+}
+
+// AddAuthentication stores the given authentication, and returns its ID. An ID gets generated when the
+// authentication does not have one.
+func (s *Server) AddAuthentication(authentication Authentication) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if authentication.ID == "" {
+		authentication.ID = s.newId()
+	}
+
+	authentication.Extra = copyExtra(authentication.Extra)
+	s.authentications[authentication.ID] = &authentication
+
+	return authentication.ID
+}
+
+// Application returns a copy of the stored application with the given ID.
+func (s *Server) Application(id string) (Application, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	application, ok := s.applications[id]
+	if !ok {
+		return Application{}, false
+	}
+
+	copied := *application
+	copied.Extra = copyExtra(application.Extra)
+
+	return copied, true
+}
+
+// Source returns a copy of the stored source with the given ID.
+func (s *Server) Source(id string) (Source, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[id]
+	if !ok {
+		return Source{}, false
+	}
+
+	return *source, true
+}
+
+// Authentications returns a copy of the stored authentications.
+func (s *Server) Authentications() []Authentication {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authentications := make([]Authentication, 0, len(s.authentications))
+	for _, authentication := range s.authentications {
+		copied := *authentication
+		copied.Extra = copyExtra(authentication.Extra)
+		authentications = append(authentications, copied)
+	}
+
+	return authentications
+}
+
+// ApplicationAuthentications returns a copy of the stored links between the applications and the authentications.
+func (s *Server) ApplicationAuthentications() []ApplicationAuthentication {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := make([]ApplicationAuthentication, 0, len(s.applicationAuthentications))
+	for _, link := range s.applicationAuthentications {
+		links = append(links, *link)
+	}
+
+	return links
+}
+
+// Fail makes the requests matching the given failure fail as scripted. The failures are checked in the order they
+// were added, and the first matching one applies.
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &failure)
+}
+
+// Requests returns the requests received so far, in the order they were received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the requests received so far with the given method and path, in the order they were received.
+func (s *Server) RequestsTo(method, path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []Request
+	for _, request := range s.requests {
+		if request.Method == method && request.Path == path {
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+// recorded records the incoming requests, and applies the scripted failures before handing them to the given
+// handler.
+func (s *Server) recorded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "unable to read the request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		failure := s.matchFailure(r)
+		s.mu.Unlock()
+
+		if failure != nil {
+			if failure.Delay > 0 {
+				select {
+				case <-time.After(failure.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if failure.StatusCode != 0 {
+				if failure.Served {
+					next.ServeHTTP(httptest.NewRecorder(), r)
+				}
+
+				if failure.RetryAfter != "" {
+					w.Header().Set("Retry-After", failure.RetryAfter)
+				}
+
+				writeError(w, failure.StatusCode, "scripted failure")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchFailure returns the failure that applies to the given request, and uses it up. The caller must hold the lock.
+func (s *Server) matchFailure(r *http.Request) *Failure {
+	for i, failure := range s.failures {
+		if failure.Method != "" && failure.Method != r.Method {
+			continue
+		}
+
+		if failure.Path != "" {
+			matched, err := path.Match(failure.Path, r.URL.Path)
+			if err != nil || !matched {
+				continue
+			}
+		}
+
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+
+		return failure
+	}
+
+	return nil
+}
+
+// authenticated rejects the requests that do not carry the PSK, or that do not identify the organization they are
+// made for.
+func (s *Server) authenticated(next func(w http.ResponseWriter, r *http.Request, orgId string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.PSK != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("x-rh-sources-psk")), []byte(s.PSK)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid psk")
+			return
+		}
+
+		orgId := r.Header.Get("x-rh-org-id")
+		if identityHeader := r.Header.Get("x-rh-identity"); identityHeader != "" {
+			identityOrgId, err := identityOrgId(identityHeader)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "invalid identity header")
+				return
+			}
+
+			if orgId == "" {
+				orgId = identityOrgId
+			}
+		}
+
+		if orgId == "" {
+			writeError(w, http.StatusUnauthorized, "missing the identity and org id headers")
+			return
+		}
+
+		next(w, r, orgId)
+	})
+}
+
+func (s *Server) getApplication(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	application, ok := s.applications[r.PathValue("id")]
+	if !ok || !visible(application.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "application not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, application)
+}
+
+func (s *Server) listApplicationAuthentications(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	application, ok := s.applications[r.PathValue("id")]
+	if !ok || !visible(application.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "application not found")
+		return
+	}
+
+	// Like in Sources, the authentications whose resource is the application get listed, along with the ones linked
+	// to it.
+	authentications := make([]Authentication, 0)
+	for _, authentication := range s.authentications {
+		if authentication.ResourceType == "Application" && authentication.ResourceID == application.ID || s.linked(application.ID, authentication.ID) {
+			authentications = append(authentications, withoutPassword(authentication))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": authentications})
+}
+
+func (s *Server) listSourceApplications(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[r.PathValue("id")]
+	if !ok || !visible(source.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+
+	applications := make([]Application, 0)
+	for _, application := range s.applications {
+		if application.SourceID == source.ID {
+			applications = append(applications, *application)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": applications})
+}
+
+func (s *Server) patchApplication(w http.ResponseWriter, r *http.Request, orgId string) {
+	patch := applicationPatch{}
+	if !readJSON(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	application, ok := s.applications[r.PathValue("id")]
+	if !ok || !visible(application.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "application not found")
+		return
+	}
+
+	if patch.AvailabilityStatus != nil {
+		application.AvailabilityStatus = *patch.AvailabilityStatus
+	}
+
+	if patch.AvailabilityStatusError != nil {
+		application.AvailabilityStatusError = *patch.AvailabilityStatusError
+	}
+
+	// The extra gets merged. The keys set to null are kept with a null value, since nothing guarantees that Sources
+	// removes them, so that the worker gets tested against the less convenient behavior.
+	if patch.Extra != nil && application.Extra == nil {
+		application.Extra = make(map[string]interface{})
+	}
+
+	for key, value := range patch.Extra {
+		application.Extra[key] = value
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listApplicationTypes lists the application types, which can be filtered by name with "filter[name][eq]".
+func (s *Server) listApplicationTypes(w http.ResponseWriter, r *http.Request, _ string) {
+	name := r.URL.Query().Get("filter[name][eq]")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applicationTypes := make([]ApplicationType, 0)
+	for _, applicationType := range s.applicationTypes {
+		if name == "" || applicationType.Name == name {
+			applicationTypes = append(applicationTypes, *applicationType)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"meta": map[string]int{"count": len(applicationTypes)},
+		"data": applicationTypes,
+	})
+}
+
+func (s *Server) getSource(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[r.PathValue("id")]
+	if !ok || !visible(source.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, source)
+}
+
+func (s *Server) patchSource(w http.ResponseWriter, r *http.Request, orgId string) {
+	patch := sourcePatch{}
+	if !readJSON(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[r.PathValue("id")]
+	if !ok || !visible(source.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+
+	if patch.AvailabilityStatus != nil {
+		source.AvailabilityStatus = *patch.AvailabilityStatus
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) checkAvailability(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[r.PathValue("id")]
+	if !ok || !visible(source.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+
+	source.AvailabilityChecks++
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) createAuthentication(w http.ResponseWriter, r *http.Request, orgId string) {
+	create := authenticationCreate{}
+	if !readJSON(w, r, &create) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authentication, status, message := s.storeAuthentication(create, orgId)
+	if authentication == nil {
+		writeError(w, status, message)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, withoutPassword(authentication))
+}
+
+func (s *Server) deleteAuthentication(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authentication, ok := s.authentications[r.PathValue("id")]
+	if !ok || !visible(authentication.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "authentication not found")
+		return
+	}
+
+	// Removing an authentication removes its links as well.
+	delete(s.authentications, authentication.ID)
+	for id, link := range s.applicationAuthentications {
+		if link.AuthenticationID == authentication.ID {
+			delete(s.applicationAuthentications, id)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createApplicationAuthentication(w http.ResponseWriter, r *http.Request, orgId string) {
+	create := applicationAuthenticationCreate{}
+	if !readJSON(w, r, &create) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, status, message := s.storeApplicationAuthentication(rawId(create.ApplicationID), rawId(create.AuthenticationID), orgId)
+	if link == nil {
+		writeError(w, status, message)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// bulkCreate creates the authentications, along with their links to the applications they belong to, all or
+// nothing.
+func (s *Server) bulkCreate(w http.ResponseWriter, r *http.Request, orgId string) {
+	create := bulkCreate{}
+	if !readJSON(w, r, &create) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := bulkCreateResult{}
+	for _, authCreate := range create.Authentications {
+		authentication, status, message := s.storeAuthentication(authCreate, orgId)
+		if authentication == nil {
+			s.rollBackBulkCreate(result)
+			writeError(w, status, message)
+			return
+		}
+		result.Authentications = append(result.Authentications, withoutPassword(authentication))
+
+		if authentication.ResourceType != "Application" {
+			continue
+		}
+
+		link, status, message := s.storeApplicationAuthentication(authentication.ResourceID, authentication.ID, orgId)
+		if link == nil {
+			s.rollBackBulkCreate(result)
+			writeError(w, status, message)
+			return
+		}
+		result.ApplicationAuthentications = append(result.ApplicationAuthentications, *link)
+	}
+
+	writeJSON(w, http.StatusCreated, result)
+}
+
+// rollBackBulkCreate removes the resources a failed bulk creation created. The caller must hold the lock.
+func (s *Server) rollBackBulkCreate(result bulkCreateResult) {
+	for _, authentication := range result.Authentications {
+		delete(s.authentications, authentication.ID)
+	}
+
+	for _, link := range result.ApplicationAuthentications {
+		delete(s.applicationAuthentications, link.ID)
+	}
+}
+
+func (s *Server) getInternalAuthentication(w http.ResponseWriter, r *http.Request, orgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authentication, ok := s.authentications[r.PathValue("id")]
+	if !ok || !visible(authentication.OrgID, orgId) {
+		writeError(w, http.StatusNotFound, "authentication not found")
+		return
+	}
+
+	response := *authentication
+	if r.URL.Query().Get("expose_encrypted_attribute[]") != "password" {
+		response.Password = ""
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// storeAuthentication stores a new authentication for the given organization.
+// returns: the stored authentication, or the status code and the message to reject the request with. The caller must
+// hold the lock.
+func (s *Server) storeAuthentication(create authenticationCreate, orgId string) (*Authentication, int, string) {
+	if create.AuthType == "" || create.ResourceType == "" {
+		return nil, http.StatusBadRequest, "the authtype and the resource type are required"
+	}
+
+	resourceId := rawId(create.ResourceID)
+	if create.ResourceType == "Application" {
+		application, ok := s.applications[resourceId]
+		if !ok || !visible(application.OrgID, orgId) {
+			return nil, http.StatusBadRequest, "the application the authentication belongs to does not exist"
+		}
+	}
+
+	authentication := &Authentication{
+		ID:           s.newId(),
+		OrgID:        orgId,
+		Name:         create.Name,
+		AuthType:     create.AuthType,
+		Username:     create.Username,
+		Password:     create.Password,
+		ResourceType: create.ResourceType,
+		ResourceID:   resourceId,
+		Extra:        copyExtra(create.Extra),
+	}
+	s.authentications[authentication.ID] = authentication
+
+	return authentication, 0, ""
+}
+
+// linked returns true when the given application and authentication are linked. The caller must hold the lock.
+func (s *Server) linked(applicationId, authenticationId string) bool {
+	for _, link := range s.applicationAuthentications {
+		if link.ApplicationID == applicationId && link.AuthenticationID == authenticationId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storeApplicationAuthentication links the given application and authentication.
+// returns: the stored link, or the status code and the message to reject the request with. The caller must hold the
+// lock.
+func (s *Server) storeApplicationAuthentication(applicationId, authenticationId, orgId string) (*ApplicationAuthentication, int, string) {
+	application, ok := s.applications[applicationId]
+	if !ok || !visible(application.OrgID, orgId) {
+		return nil, http.StatusBadRequest, "the application does not exist"
+	}
+
+	authentication, ok := s.authentications[authenticationId]
+	if !ok || !visible(authentication.OrgID, orgId) {
+		return nil, http.StatusBadRequest, "the authentication does not exist"
+	}
+
+	link := &ApplicationAuthentication{
+		ID:               s.newId(),
+		OrgID:            orgId,
+		ApplicationID:    application.ID,
+		AuthenticationID: authentication.ID,
+	}
+	s.applicationAuthentications[link.ID] = link
+
+	return link, 0, ""
+}
+
+// newId returns a new ID, unique across every kind of resource. The caller must hold the lock.
+func (s *Server) newId() string {
+	s.nextId++
+
+	return strconv.Itoa(s.nextId)
+}
+
+// visible returns true when a resource of the given organization is visible to the requests of the other one.
+func visible(resourceOrgId, orgId string) bool {
+	return resourceOrgId == "" || resourceOrgId == orgId
+}
+
+// identityOrgId returns the organization ID of the given base64 encoded identity header.
+func identityOrgId(header string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return "", err
+	}
+
+	identity := struct {
+		Identity struct {
+			OrgID string `json:"org_id"`
+		} `json:"identity"`
+	}{}
+
+	err = json.Unmarshal(raw, &identity)
+	if err != nil {
+		return "", err
+	}
+
+	return identity.Identity.OrgID, nil
+}
+
+// rawId returns the given ID, which the clients send either as a string or as a number, as a string.
+func rawId(id interface{}) string {
+	switch typed := id.(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatInt(int64(typed), 10)
+	default:
+		return ""
+	}
+}
+
+// withoutPassword returns a copy of the given authentication without its password, as the public endpoints do.
+func withoutPassword(authentication *Authentication) Authentication {
+	copied := *authentication
+	copied.Password = ""
+
+	return copied
+}
+
+// copyExtra returns a shallow copy of the given extra.
+func copyExtra(extra map[string]interface{}) map[string]interface{} {
+	if extra == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(extra))
+	for key, value := range extra {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// readJSON decodes the body of the request into the given target, rejecting the request when it is not valid JSON.
+// returns: true when the body was decoded.
+func readJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+		return false
+	}
+
+	return true
+}
+
+// writeError writes an error with the given status code, in the same format Sources does.
+func writeError(w http.ResponseWriter, statusCode int, detail string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"errors": []map[string]string{{"detail": detail, "status": strconv.Itoa(statusCode)}},
+	})
+}
+
+// writeJSON writes the given body as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
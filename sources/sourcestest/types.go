@@ -0,0 +1,154 @@
+package sourcestest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Server is an in-memory fake of the Sources API, which serves the v3.1 and internal v2.0 endpoints the worker uses.
+// It keeps the state of the applications, sources, authentications and links between them, checks the PSK and the
+// identity headers of the requests, and fails the requests it is told to.
+type Server struct {
+	*httptest.Server
+
+	// PSK is the pre-shared key the requests must carry in the "x-rh-sources-psk" header. Any key is accepted when
+	// empty.
+	PSK string
+
+	mu                         sync.Mutex
+	nextId                     int
+	applications               map[string]*Application
+	sources                    map[string]*Source
+	authentications            map[string]*Authentication
+	applicationAuthentications map[string]*ApplicationAuthentication
+	applicationTypes           map[string]*ApplicationType
+	failures                   []*Failure
+	requests                   []Request
+}
+
+// Application is an application stored in the fake Sources API. Only the applications of the OrgID organization are
+// visible, unless it is empty.
+type Application struct {
+	ID                      string                 `json:"id"`
+	OrgID                   string                 `json:"-"`
+	SourceID                string                 `json:"source_id"`
+	ApplicationTypeID       string                 `json:"application_type_id"`
+	AvailabilityStatus      string                 `json:"availability_status"`
+	AvailabilityStatusError string                 `json:"availability_status_error"`
+	Extra                   map[string]interface{} `json:"extra"`
+	PausedAt                *time.Time             `json:"paused_at,omitempty"`
+}
+
+// ApplicationType is an application type stored in the fake Sources API, along with the superkey steps its
+// applications require. Application types are visible to every organization.
+type ApplicationType struct {
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	SuperKeyMetadata interface{} `json:"superkey_metadata,omitempty"`
+}
+
+// Source is a source stored in the fake Sources API. AvailabilityChecks counts the availability checks requested for
+// it.
+type Source struct {
+	ID                 string     `json:"id"`
+	OrgID              string     `json:"-"`
+	Name               string     `json:"name"`
+	AvailabilityStatus string     `json:"availability_status"`
+	PausedAt           *time.Time `json:"paused_at,omitempty"`
+	AvailabilityChecks int        `json:"-"`
+}
+
+// Authentication is an authentication stored in the fake Sources API. The password is only exposed by the internal
+// endpoint.
+type Authentication struct {
+	ID           string                 `json:"id"`
+	OrgID        string                 `json:"-"`
+	Name         string                 `json:"name,omitempty"`
+	AuthType     string                 `json:"authtype"`
+	Username     string                 `json:"username"`
+	Password     string                 `json:"password,omitempty"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Extra        map[string]interface{} `json:"extra,omitempty"`
+}
+
+// ApplicationAuthentication is a link between an application and an authentication stored in the fake Sources API.
+type ApplicationAuthentication struct {
+	ID               string `json:"id"`
+	OrgID            string `json:"-"`
+	ApplicationID    string `json:"application_id"`
+	AuthenticationID string `json:"authentication_id"`
+}
+
+// Failure scripts how the matching requests fail. The requests are delayed by Delay, and then answered with
+// StatusCode and the RetryAfter header, if given, instead of being served.
+type Failure struct {
+	// Method is the HTTP method of the failing requests. Every method matches when empty.
+	Method string
+	// Path is the pattern, as understood by "path.Match", the paths of the failing requests match. Every path
+	// matches when empty.
+	Path string
+	// StatusCode is the status code the failing requests get answered with. The requests are served as usual after
+	// the delay when it is zero, which allows simulating slow responses.
+	StatusCode int
+	// RetryAfter is the value of the "Retry-After" header sent along with the status code.
+	RetryAfter string
+	// Delay is for how long the failing requests are held before being answered.
+	Delay time.Duration
+	// Times is the number of requests that fail before the failure stops applying. Every request fails when zero.
+	Times int
+	// Served makes the requests be served as usual before being answered with the status code, which simulates the
+	// requests that reached Sources but whose responses got lost.
+	Served bool
+}
+
+// Request is a request received by the fake Sources API.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// applicationPatch is the body of the application patches. The fields that are not sent are left untouched.
+type applicationPatch struct {
+	AvailabilityStatus      *string                `json:"availability_status"`
+	AvailabilityStatusError *string                `json:"availability_status_error"`
+	Extra                   map[string]interface{} `json:"extra"`
+}
+
+// sourcePatch is the body of the source patches.
+type sourcePatch struct {
+	AvailabilityStatus *string `json:"availability_status"`
+}
+
+// authenticationCreate is the body of the authentication creations.
+type authenticationCreate struct {
+	Name         string                 `json:"name"`
+	AuthType     string                 `json:"authtype"`
+	Username     string                 `json:"username"`
+	Password     string                 `json:"password"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   interface{}            `json:"resource_id"`
+	ResourceName string                 `json:"resource_name"`
+	Extra        map[string]interface{} `json:"extra"`
+}
+
+// applicationAuthenticationCreate is the body of the application authentication creations.
+type applicationAuthenticationCreate struct {
+	ApplicationID    interface{} `json:"application_id"`
+	AuthenticationID interface{} `json:"authentication_id"`
+}
+
+// bulkCreate is the body of the bulk creations.
+type bulkCreate struct {
+	Authentications []authenticationCreate `json:"authentications"`
+}
+
+// bulkCreateResult is the response of the bulk creations.
+type bulkCreateResult struct {
+	Authentications            []Authentication            `json:"authentications"`
+	ApplicationAuthentications []ApplicationAuthentication `json:"application_authentications"`
+}
@@ -0,0 +1,163 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// availabilityStatusEventType is the event type the Sources status listener expects the availability status updates
+// to carry.
+const availabilityStatusEventType = "availability_status"
+
+// The resource types of the availability status messages, as understood by the Sources status listener.
+const (
+	resourceTypeApplication = "Application"
+	resourceTypeSource      = "Source"
+)
+
+// The transports the availability status updates are sent through.
+const (
+	transportKafka = "kafka"
+	transportRest  = "rest"
+)
+
+// ErrStatusTopicDisabled is returned when publishing an availability status while no status writer is set.
+var ErrStatusTopicDisabled = errors.New("the Sources status topic is not enabled")
+
+// statusWriter publishes the availability status updates to the Sources status topic. The updates are sent through
+// the REST API when it is nil.
+var statusWriter *kafkago.Writer
+
+var (
+	availabilityStatusUpdatesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sources_superkey_availability_status_updates",
+		Help: "The number of availability status updates sent to Sources, by resource type, transport and outcome.",
+	}, []string{"resource_type", "transport", "outcome"})
+)
+
+// SetStatusWriter makes the availability status updates get published with the given writer, instead of being sent
+// through the REST API. The REST API is still used when the publishing fails.
+func SetStatusWriter(writer *kafkago.Writer) {
+	statusWriter = writer
+}
+
+// UpdateApplicationAvailability sets the availability status and error of the application. The extra, which the
+// status messages cannot carry, gets patched through the REST API when not empty. When the extra cannot be stored,
+// it is kept and sent again in the background, and the returned error wraps "ErrExtraPending" when the status itself
+// went through.
+func (sc *sourcesClient) UpdateApplicationAvailability(ctx context.Context, authData *AuthenticationData, appId, status, statusError string, extra map[string]interface{}) error {
+	// Set the logging fields.
+	ctx = l.WithApplicationId(ctx, appId)
+
+	err := publishAvailabilityStatus(ctx, authData, resourceTypeApplication, appId, status, statusError)
+	if err == nil {
+		if len(extra) == 0 {
+			return nil
+		}
+
+		// Only the extra gets sent, so that the published availability status is not overwritten.
+		err = sc.ResetApplicationExtra(ctx, authData, appId, extra)
+		if err != nil {
+			sc.pendingExtras.put(authData, appId, extra)
+			return fmt.Errorf("%w: %w", ErrExtraPending, err)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, ErrStatusTopicDisabled) {
+		l.LogWithContext(ctx).WithField("error", err).Warn("Unable to publish the application's availability status, falling back to the REST API")
+	}
+
+	err = sc.PatchApplication(ctx, authData, appId, &PatchApplicationRequest{
+		AvailabilityStatus:      &status,
+		AvailabilityStatusError: &statusError,
+		Extra:                   extra,
+	})
+	countAvailabilityStatusUpdate(resourceTypeApplication, transportRest, err)
+
+	if err != nil && len(extra) > 0 {
+		sc.pendingExtras.put(authData, appId, extra)
+	}
+
+	return err
+}
+
+// UpdateSourceAvailability sets the availability status of the source.
+func (sc *sourcesClient) UpdateSourceAvailability(ctx context.Context, authData *AuthenticationData, sourceId, status string) error {
+	err := publishAvailabilityStatus(ctx, authData, resourceTypeSource, sourceId, status, "")
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, ErrStatusTopicDisabled) {
+		l.LogWithContext(ctx).WithField("error", err).Warn("Unable to publish the source's availability status, falling back to the REST API")
+	}
+
+	err = sc.PatchSource(ctx, authData, sourceId, &PatchSourceRequest{AvailabilityStatus: &status})
+	countAvailabilityStatusUpdate(resourceTypeSource, transportRest, err)
+
+	return err
+}
+
+// publishAvailabilityStatus publishes the availability status of the resource to the Sources status topic, along
+// with the identity headers the status listener needs to find the resource.
+func publishAvailabilityStatus(ctx context.Context, authData *AuthenticationData, resourceType, resourceId, status, statusError string) error {
+	if statusWriter == nil {
+		return ErrStatusTopicDisabled
+	}
+
+	body, err := json.Marshal(StatusMessage{
+		ResourceType: resourceType,
+		ResourceID:   resourceId,
+		Status:       status,
+		Error:        statusError,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal the availability status message: %w", err)
+	}
+
+	headers := []kafkago.Header{
+		{Key: "event_type", Value: []byte(availabilityStatusEventType)},
+		{Key: "x-rh-identity", Value: []byte(authData.IdentityHeader)},
+		{Key: "x-rh-sources-org-id", Value: []byte(authData.OrgId)},
+	}
+
+	// The account number is only sent when the identity carries one, since the organization ID is enough to find the
+	// resource.
+	accountNumber, err := getAccountNumber(authData.IdentityHeader)
+	if err == nil && accountNumber != "" {
+		headers = append(headers, kafkago.Header{Key: "x-rh-sources-account-number", Value: []byte(accountNumber)})
+	}
+
+	err = statusWriter.WriteMessages(ctx, kafkago.Message{
+		Key:     []byte(resourceId),
+		Value:   body,
+		Headers: headers,
+	})
+	countAvailabilityStatusUpdate(resourceType, transportKafka, err)
+	if err != nil {
+		return fmt.Errorf("unable to publish the availability status of the %s: %w", resourceType, err)
+	}
+
+	l.LogWithContext(ctx).Debugf(`Published the "%s" availability status of the %s "%s" to the Sources status topic`, status, resourceType, resourceId)
+
+	return nil
+}
+
+// countAvailabilityStatusUpdate counts the availability status update sent through the given transport.
+func countAvailabilityStatusUpdate(resourceType, transport string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+
+	availabilityStatusUpdatesCounter.WithLabelValues(resourceType, transport, outcome).Inc()
+}
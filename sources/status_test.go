@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// TestUpdateApplicationAvailability tests that the availability status and the extra get stored through the REST API
+// when the status topic is not enabled.
+func TestUpdateApplicationAvailability(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+
+	err := client.UpdateApplicationAvailability(context.Background(), &AuthenticationData{OrgId: testOrgId}, appId, "unavailable", "failed", map[string]interface{}{"_superkey": nil})
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	application, _ := server.Application(appId)
+	if application.AvailabilityStatus != "unavailable" || application.AvailabilityStatusError != "failed" {
+		t.Errorf(`want the "unavailable" status with the "failed" error, got "%s" and "%s"`, application.AvailabilityStatus, application.AvailabilityStatusError)
+	}
+
+	if value, ok := application.Extra["_superkey"]; !ok || value != nil {
+		t.Errorf(`want a null "_superkey" key, got "%v"`, value)
+	}
+}
+
+// TestPendingExtraIsSentAgain tests that the extra of a failed availability status update is kept, and stored once
+// Sources accepts it.
+func TestPendingExtraIsSentAgain(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	appPath := "/api/sources/v3.1/applications/" + appId
+	server.Fail(sourcestest.Failure{Method: http.MethodPatch, Path: appPath, StatusCode: http.StatusBadRequest, Times: 1})
+
+	ctx := context.Background()
+	authData := &AuthenticationData{OrgId: testOrgId}
+
+	err := client.UpdateApplicationAvailability(ctx, authData, appId, "unavailable", "failed", map[string]interface{}{"_superkey": nil})
+	if err == nil {
+		t.Fatal("want an error, got none")
+	}
+
+	if _, ok := client.pendingExtras.snapshot()[appId]; !ok {
+		t.Fatal("want the extra to be pending")
+	}
+
+	client.sendPendingExtras(ctx)
+
+	application, _ := server.Application(appId)
+	if value, ok := application.Extra["_superkey"]; !ok || value != nil {
+		t.Errorf(`want a null "_superkey" key, got "%v"`, value)
+	}
+
+	if pending := client.pendingExtras.snapshot(); len(pending) != 0 {
+		t.Errorf("want no pending extras, got %v", pending)
+	}
+}
+
+// TestPendingExtraIsSuperseded tests that a pending extra does not overwrite the keys that were stored with newer
+// values in the meantime.
+func TestPendingExtraIsSuperseded(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	appPath := "/api/sources/v3.1/applications/" + appId
+	server.Fail(sourcestest.Failure{Method: http.MethodPatch, Path: appPath, StatusCode: http.StatusBadRequest, Times: 1})
+
+	ctx := context.Background()
+	authData := &AuthenticationData{OrgId: testOrgId}
+
+	_ = client.UpdateApplicationAvailability(ctx, authData, appId, "unavailable", "failed", map[string]interface{}{"_superkey": "old"})
+
+	err := client.ResetApplicationExtra(ctx, authData, appId, map[string]interface{}{"_superkey": "new"})
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if pending := client.pendingExtras.snapshot(); len(pending) != 0 {
+		t.Errorf("want no pending extras, got %v", pending)
+	}
+
+	patches := len(server.RequestsTo(http.MethodPatch, appPath))
+	client.sendPendingExtras(ctx)
+
+	if got := len(server.RequestsTo(http.MethodPatch, appPath)); got != patches {
+		t.Errorf("want nothing to be sent, got %d more patches", got-patches)
+	}
+
+	application, _ := server.Application(appId)
+	if application.Extra["_superkey"] != "new" {
+		t.Errorf(`want the "new" extra, got "%v"`, application.Extra["_superkey"])
+	}
+}
+
+// TestPendingExtraWaitsForTheBreaker tests that the pending extras are not sent while the circuit breaker is open.
+func TestPendingExtraWaitsForTheBreaker(t *testing.T) {
+	server, client := newTestClient(t, nil)
+
+	appId := server.AddApplication(sourcestest.Application{OrgID: testOrgId})
+	client.pendingExtras.put(&AuthenticationData{OrgId: testOrgId}, appId, map[string]interface{}{"key": "value"})
+
+	for i := 0; i < client.config.SourcesBreakerThreshold; i++ {
+		client.breaker.RecordFailure()
+	}
+
+	client.sendPendingExtras(context.Background())
+
+	if got := len(server.Requests()); got != 0 {
+		t.Errorf("want no requests while the breaker is open, got %d", got)
+	}
+
+	if _, ok := client.pendingExtras.snapshot()[appId]; !ok {
+		t.Error("want the extra to still be pending")
+	}
+}
+
+// TestPendingExtrasArePersisted tests that the pending extras are kept in the database file without the identity
+// header, loaded again after a restart, and removed from the file once stored.
+func TestPendingExtrasArePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending-extras.db")
+
+	pending := newPendingExtras()
+	if err := pending.open(path); err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	pending.put(&AuthenticationData{IdentityHeader: "identity", OrgId: testOrgId}, "1", map[string]interface{}{"key": "value"})
+	pending.put(&AuthenticationData{OrgId: testOrgId}, "2", map[string]interface{}{"key": "value"})
+	pending.supersede("2", map[string]interface{}{"key": "newer"})
+
+	if err := pending.close(); err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	restarted := newPendingExtras()
+	if err := restarted.open(path); err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+	t.Cleanup(func() { restarted.close() })
+
+	snapshot := restarted.snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("want 1 pending extra, got %v", snapshot)
+	}
+
+	extra, ok := snapshot["1"]
+	if !ok || extra.extra["key"] != "value" {
+		t.Errorf(`want the pending extra of the application "1", got %v`, snapshot)
+	}
+
+	if extra.authData.IdentityHeader != "" || extra.authData.OrgId != testOrgId {
+		t.Errorf("want only the organization ID to be stored, got %+v", extra.authData)
+	}
+}
@@ -0,0 +1,113 @@
+package sources
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+)
+
+var (
+	// sharedClient is the long-lived Sources client, so that the connections to Sources get reused across requests.
+	sharedClient     *sourcesClient
+	sharedClientErr  error
+	sharedClientOnce sync.Once
+)
+
+// Client returns the Sources client shared by the whole worker, creating it from the configuration the first time it
+// is called.
+func Client() (*sourcesClient, error) {
+	sharedClientOnce.Do(func() {
+		sharedClient, sharedClientErr = NewSourcesClient(config.Get())
+	})
+
+	return sharedClient, sharedClientErr
+}
+
+// SetClient replaces the Sources client shared by the whole worker with one created from the given configuration,
+// along with its own circuit breaker, e.g. to point the worker to a fake Sources API. It is meant to be called before
+// any request gets processed.
+func SetClient(conf *config.SuperKeyWorkerConfig) error {
+	client, err := NewSourcesClient(conf)
+	if err != nil {
+		return err
+	}
+
+	// Make sure that the configuration does not replace the client afterwards.
+	sharedClientOnce.Do(func() {})
+	sharedClient, sharedClientErr = client, nil
+
+	return nil
+}
+
+// newHTTPClient returns an HTTP client with a connection pool tuned for talking to a single host, which trusts the
+// configured CA bundle on top of the system ones, presents the configured client certificate, and goes through the
+// configured proxy.
+func newHTTPClient(conf *config.SuperKeyWorkerConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = conf.SourcesMaxIdleConns
+	transport.MaxIdleConnsPerHost = conf.SourcesMaxIdleConns
+	transport.IdleConnTimeout = conf.SourcesIdleConnTimeout
+
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// Without an explicit proxy the usual "HTTPS_PROXY", "HTTP_PROXY" and "NO_PROXY" environment variables apply.
+	if conf.SourcesProxyURL != "" {
+		proxyUrl, err := url.Parse(conf.SourcesProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Sources proxy URL: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTLSConfig returns the TLS configuration the requests to Sources are sent with.
+func newTLSConfig(conf *config.SuperKeyWorkerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.SourcesCAPath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(conf.SourcesCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the Sources CA bundle: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf(`no certificates found in the Sources CA bundle "%s"`, conf.SourcesCAPath)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if (conf.SourcesClientCertPath == "") != (conf.SourcesClientKeyPath == "") {
+		return nil, errors.New("both the client certificate and its key are required for mutual TLS with Sources")
+	}
+
+	if conf.SourcesClientCertPath != "" {
+		certificate, err := tls.LoadX509KeyPair(conf.SourcesClientCertPath, conf.SourcesClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the Sources client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -3,8 +3,57 @@ package sources
 import (
 	"encoding/base64"
 	"encoding/json"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
+// CircuitBreaker stops the requests to the Sources API after too many consecutive failures, so that the worker does
+// not keep on processing requests that are doomed to fail. Once open, it lets a single trial request through after
+// the open duration, which closes it again when it succeeds.
+type CircuitBreaker struct {
+	mu           sync.Mutex
+	state        string
+	failures     int
+	threshold    int
+	openDuration time.Duration
+	openedAt     time.Time
+	// trialInFlight is set while the trial request of the half open state has not finished.
+	trialInFlight bool
+	// changed is closed and replaced on every state transition, so that the waiters wake up.
+	changed chan struct{}
+}
+
+// pendingExtras holds the application extras that could not be stored in Sources, by application, until they get
+// sent again. They are kept in the database file too, when there is one, so that they survive a restart.
+type pendingExtras struct {
+	mu     sync.Mutex
+	extras map[string]*pendingExtra
+	db     *bolt.DB
+}
+
+// pendingExtra is the extra waiting to be stored for an application, along with the headers to store it with.
+type pendingExtra struct {
+	authData AuthenticationData
+	extra    map[string]interface{}
+}
+
+// storedPendingExtra is a pending extra as stored in the database file. The identity header is left out, since it
+// must not end up on disk and the organization ID is enough to store the extra.
+type storedPendingExtra struct {
+	OrgId string                 `json:"org_id"`
+	Extra map[string]interface{} `json:"extra"`
+}
+
+// StatusMessage is the availability status update of a resource, as published to the Sources status topic.
+type StatusMessage struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	Status       string `json:"status"`
+	Error        string `json:"error"`
+}
+
 type XRhIdentity struct {
 	Identity struct {
 		AccountNumber string `json:"account_number"`
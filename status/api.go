@@ -0,0 +1,36 @@
+package status
+
+import (
+	"net/http"
+
+	"github.com/redhatinsights/sources-superkey-worker/internal/httpapi"
+)
+
+// RegisterHandlers registers the operation status endpoints in the given mux, which require the given PSK:
+//
+//	GET /operations                            lists the operations in flight and the recently finished ones
+//	GET /operations?application_id=<id>        lists the operations performed on the given application
+//	GET /operations/{guid}                     lists the operations performed on the resources with the given GUID
+func RegisterHandlers(mux *http.ServeMux, tracker *Tracker, psk string) {
+	auth := httpapi.Authenticator{PSK: psk}
+
+	mux.Handle("GET /operations", auth.Authenticated(func(w http.ResponseWriter, r *http.Request) {
+		applicationId := r.URL.Query().Get("application_id")
+		if applicationId != "" {
+			httpapi.WriteJSON(w, http.StatusOK, tracker.ByApplicationID(applicationId))
+			return
+		}
+
+		httpapi.WriteJSON(w, http.StatusOK, tracker.List())
+	}))
+
+	mux.Handle("GET /operations/{guid}", auth.Authenticated(func(w http.ResponseWriter, r *http.Request) {
+		operations := tracker.ByGUID(r.PathValue("guid"))
+		if len(operations) == 0 {
+			httpapi.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "operation not found"})
+			return
+		}
+
+		httpapi.WriteJSON(w, http.StatusOK, operations)
+	}))
+}
@@ -0,0 +1,166 @@
+package status
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+)
+
+// NewTracker returns a tracker which remembers up to the given number of finished operations.
+func NewTracker(history int) *Tracker {
+	if history < 1 {
+		history = 1
+	}
+
+	return &Tracker{
+		inFlight: make(map[string]*Operation),
+		finished: make([]*Operation, history),
+	}
+}
+
+// Begin starts tracking a new operation for the forged application.
+func (t *Tracker) Begin(f *superkey.ForgedApplication, eventType string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inFlight[f.GUID] = &Operation{
+		GUID:           f.GUID,
+		TenantID:       f.Request.TenantID,
+		SourceID:       f.Request.SourceID,
+		ApplicationID:  f.Request.ApplicationID,
+		EventType:      eventType,
+		Phase:          superkey.InitialPhase(eventType),
+		StepsCompleted: stepNames(f.StepsCompleted),
+		StartedAt:      time.Now(),
+	}
+
+	return nil
+}
+
+// RecordIntent sets the step the operation is currently acting on.
+func (t *Tracker) RecordIntent(f *superkey.ForgedApplication, action, step string, _ map[string]string) error {
+	t.update(f, func(op *Operation) {
+		op.CurrentStep = fmt.Sprintf("%s %s", action, step)
+	})
+
+	return nil
+}
+
+// RecordOutcome clears the current step and refreshes the completed steps.
+func (t *Tracker) RecordOutcome(f *superkey.ForgedApplication, _, _ string, _ map[string]string, stepErr error) error {
+	t.update(f, func(op *Operation) {
+		op.CurrentStep = ""
+		op.StepsCompleted = stepNames(f.StepsCompleted)
+		if stepErr != nil {
+			op.Error = stepErr.Error()
+		}
+	})
+
+	return nil
+}
+
+// SetPhase sets the phase the operation is in.
+func (t *Tracker) SetPhase(f *superkey.ForgedApplication, phase string) error {
+	t.update(f, func(op *Operation) {
+		op.Phase = phase
+	})
+
+	return nil
+}
+
+// Finish moves the operation to the ring buffer of finished operations, overwriting the oldest one when it is full.
+func (t *Tracker) Finish(f *superkey.ForgedApplication, phase string, opErr error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	op, ok := t.inFlight[f.GUID]
+	if !ok {
+		return nil
+	}
+	delete(t.inFlight, f.GUID)
+
+	now := time.Now()
+	op.Phase = phase
+	op.CurrentStep = ""
+	op.StepsCompleted = stepNames(f.StepsCompleted)
+	op.FinishedAt = &now
+	if opErr != nil {
+		op.Error = opErr.Error()
+	}
+
+	t.finished[t.next] = op
+	t.next = (t.next + 1) % len(t.finished)
+
+	return nil
+}
+
+// List returns the operations in flight followed by the finished ones, the most recent first.
+func (t *Tracker) List() []Operation {
+	return t.filter(func(*Operation) bool { return true })
+}
+
+// ByGUID returns the operations performed on the resources with the given GUID, the most recent first.
+func (t *Tracker) ByGUID(guid string) []Operation {
+	return t.filter(func(op *Operation) bool { return op.GUID == guid })
+}
+
+// ByApplicationID returns the operations performed on the given application, the most recent first.
+func (t *Tracker) ByApplicationID(applicationId string) []Operation {
+	return t.filter(func(op *Operation) bool { return op.ApplicationID == applicationId })
+}
+
+// filter returns copies of the tracked operations that match the given predicate, the most recent first.
+func (t *Tracker) filter(match func(op *Operation) bool) []Operation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	operations := make([]Operation, 0)
+
+	inFlight := make([]Operation, 0, len(t.inFlight))
+	for _, op := range t.inFlight {
+		if match(op) {
+			inFlight = append(inFlight, op.copy())
+		}
+	}
+	sort.Slice(inFlight, func(i, j int) bool { return inFlight[i].StartedAt.After(inFlight[j].StartedAt) })
+	operations = append(operations, inFlight...)
+
+	for i := 1; i <= len(t.finished); i++ {
+		op := t.finished[(t.next-i+len(t.finished))%len(t.finished)]
+		if op != nil && match(op) {
+			operations = append(operations, op.copy())
+		}
+	}
+
+	return operations
+}
+
+// update applies the given modification to the operation in flight for the forged application, if any.
+func (t *Tracker) update(f *superkey.ForgedApplication, modify func(op *Operation)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if op, ok := t.inFlight[f.GUID]; ok {
+		modify(op)
+	}
+}
+
+// copy returns a copy of the operation that is safe to hand out of the tracker's lock.
+func (op *Operation) copy() Operation {
+	copied := *op
+	copied.StepsCompleted = append([]string(nil), op.StepsCompleted...)
+
+	return copied
+}
+
+func stepNames(steps map[string]map[string]string) []string {
+	names := make([]string, 0, len(steps))
+	for name := range steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
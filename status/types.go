@@ -0,0 +1,30 @@
+package status
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker keeps track of the operations in flight, along with the most recently finished ones, which are kept in a
+// ring buffer. It records the operations by being set as one of the superkey journals.
+type Tracker struct {
+	mu       sync.Mutex
+	inFlight map[string]*Operation
+	finished []*Operation
+	next     int
+}
+
+// Operation is the status of an operation performed on a forged application.
+type Operation struct {
+	GUID           string     `json:"guid"`
+	TenantID       string     `json:"tenant_id"`
+	SourceID       string     `json:"source_id,omitempty"`
+	ApplicationID  string     `json:"application_id,omitempty"`
+	EventType      string     `json:"event_type"`
+	Phase          string     `json:"phase"`
+	CurrentStep    string     `json:"current_step,omitempty"`
+	StepsCompleted []string   `json:"steps_completed"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
@@ -2,9 +2,10 @@ package superkey
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/redhatinsights/sources-superkey-worker/config"
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
 	"github.com/redhatinsights/sources-superkey-worker/sources"
 )
@@ -13,8 +14,24 @@ import (
 // also marking the application's availability_status_error to what AWS updated
 // us with.
 func (req *CreateRequest) MarkSourceUnavailable(ctx context.Context, incomingErr error, newApplication *ForgedApplication) error {
-	availabilityStatus := "unavailable"
 	availabilityStatusError := fmt.Sprintf("Resource Creation error: failed to create resources in Amazon. Error: %s", incomingErr)
+
+	return req.markUnavailable(ctx, availabilityStatusError, newApplication)
+}
+
+// MarkUpdateFailed marks the application and source as unavailable after a
+// failed update, storing how far the reconciliation got so that the
+// application's extra keeps pointing to the resources that actually exist.
+func (req *CreateRequest) MarkUpdateFailed(ctx context.Context, incomingErr error, updatedApplication *ForgedApplication) error {
+	availabilityStatusError := fmt.Sprintf("Resource Update error: failed to update the resources in Amazon. Error: %s", incomingErr)
+
+	return req.markUnavailable(ctx, availabilityStatusError, updatedApplication)
+}
+
+// markUnavailable marks the application and source as unavailable with the
+// given error, storing the steps completed by the forged application, if any.
+func (req *CreateRequest) markUnavailable(ctx context.Context, availabilityStatusError string, newApplication *ForgedApplication) error {
+	availabilityStatus := "unavailable"
 	extra := make(map[string]interface{})
 
 	// creating the aws resources was at least partially successful, need to store
@@ -27,33 +44,145 @@ func (req *CreateRequest) MarkSourceUnavailable(ctx context.Context, incomingErr
 		newApplication = &ForgedApplication{}
 	}
 
-	sourcesClient := sources.NewSourcesClient(config.Get())
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
 
 	authData := &sources.AuthenticationData{
 		IdentityHeader: req.IdentityHeader,
 		OrgId:          req.OrgIdHeader,
 	}
 
-	patchAppRequestBody := &sources.PatchApplicationRequest{
-		AvailabilityStatus:      &availabilityStatus,
-		AvailabilityStatusError: &availabilityStatusError,
-		Extra:                   extra,
+	// The source gets marked even when the application could not be, since
+	// the statuses might be published to the status topic while the REST API
+	// is down.
+	var errs []error
+
+	// The extra holds the completed steps a teardown needs, so it gets sent
+	// again in the background when it cannot be stored right away.
+	err = sourcesClient.UpdateApplicationAvailability(ctx, authData, req.ApplicationID, availabilityStatus, availabilityStatusError, extra)
+	switch {
+	case errors.Is(err, sources.ErrExtraPending):
+		l.LogWithContext(ctx).Warnf(`Application marked as "unavailable", but its extra is still pending: %s`, err)
+	case err != nil:
+		errs = append(errs, fmt.Errorf("error while updating the application: %w", err))
+	default:
+		l.LogWithContext(ctx).Info(`Application marked as "unavailable"`)
+	}
+
+	err = sourcesClient.UpdateSourceAvailability(ctx, authData, req.SourceID, availabilityStatus)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error while updating the source: %w", err))
+	} else {
+		l.LogWithContext(ctx).Info(`Source marked as "unavailable"`)
+	}
+
+	return errors.Join(errs...)
+}
+
+// MarkRequestInvalid marks the application as unavailable, setting its
+// availability_status_error to the violations found when validating the
+// request, so that the user gets to know why the request was rejected.
+func (req *CreateRequest) MarkRequestInvalid(ctx context.Context, validationErr error) error {
+	availabilityStatus := "unavailable"
+	availabilityStatusError := fmt.Sprintf("Resource Creation error: the superkey request is not valid. Error: %s", validationErr)
+
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
 	}
 
-	err := sourcesClient.PatchApplication(ctx, authData, req.ApplicationID, patchAppRequestBody)
+	authData := &sources.AuthenticationData{
+		IdentityHeader: req.IdentityHeader,
+		OrgId:          req.OrgIdHeader,
+	}
+
+	err = sourcesClient.UpdateApplicationAvailability(ctx, authData, req.ApplicationID, availabilityStatus, availabilityStatusError, nil)
 	if err != nil {
 		return fmt.Errorf("error while updating the application: %w", err)
 	}
 
-	l.LogWithContext(ctx).Info(`Application marked as "unavailable"`)
+	l.LogWithContext(ctx).Info(`Application marked as "unavailable" due to an invalid request`)
+
+	return nil
+}
+
+// MarkTeardownAbandoned marks the application as unavailable, setting its
+// availability_status_error to the resource that could not be removed from
+// AWS, so that the leaked resource can be removed by hand.
+func (req *CreateRequest) MarkTeardownAbandoned(ctx context.Context, resourceType, identifier string, teardownErr error) error {
+	availabilityStatus := "unavailable"
+	availabilityStatusError := fmt.Sprintf(`Resource Removal error: failed to remove the "%s" resource "%s" from Amazon, it needs to be removed manually. Error: %s`, resourceType, identifier, teardownErr)
 
-	patchSourceRequestBody := &sources.PatchSourceRequest{AvailabilityStatus: &availabilityStatus}
-	err = sourcesClient.PatchSource(ctx, authData, req.SourceID, patchSourceRequestBody)
+	sourcesClient, err := sources.Client()
 	if err != nil {
-		return fmt.Errorf("error while updating the source: %w", err)
+		return err
 	}
 
-	l.LogWithContext(ctx).Info(`Source marked as "unavailable"`)
+	authData := &sources.AuthenticationData{
+		IdentityHeader: req.IdentityHeader,
+		OrgId:          req.OrgIdHeader,
+	}
+
+	err = sourcesClient.UpdateApplicationAvailability(ctx, authData, req.ApplicationID, availabilityStatus, availabilityStatusError, nil)
+	if err != nil {
+		return fmt.Errorf("error while updating the application: %w", err)
+	}
+
+	l.LogWithContext(ctx).Info(`Application marked as "unavailable" due to an abandoned teardown`)
 
 	return nil
 }
+
+// CheckBeforeForging fetches the application and its source from Sources, to
+// make sure that a delayed request does not forge resources for an
+// application that was deleted, paused or already provisioned in the meantime.
+// returns: the reason to skip the request for, or nil when the resources can
+// be forged, or an error when Sources could not be asked.
+func (req *CreateRequest) CheckBeforeForging(ctx context.Context) (*SkipReason, error) {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: req.IdentityHeader,
+		OrgId:          req.OrgIdHeader,
+	}
+
+	application, err := sourcesClient.GetApplication(ctx, authData, req.ApplicationID)
+	if errors.Is(err, sources.ErrNotFound) {
+		return &SkipReason{Code: SkipApplicationGone, Message: "the application no longer exists in Sources"}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the application: %w", err)
+	}
+
+	source, err := sourcesClient.GetSource(ctx, authData, req.SourceID)
+	if errors.Is(err, sources.ErrNotFound) {
+		return &SkipReason{Code: SkipSourceGone, Message: "the source no longer exists in Sources"}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the source: %w", err)
+	}
+
+	if application.PausedAt != nil {
+		return &SkipReason{Code: SkipPaused, Message: fmt.Sprintf("the application is paused since %s", application.PausedAt.Format(time.RFC3339))}, nil
+	}
+
+	if source.PausedAt != nil {
+		return &SkipReason{Code: SkipPaused, Message: fmt.Sprintf("the source is paused since %s", source.PausedAt.Format(time.RFC3339))}, nil
+	}
+
+	// Applications whose forging was rolled back keep a "_superkey" state
+	// without any steps, and can be forged again.
+	state, err := ParseSuperKeyExtra(application.Extra)
+	if err == nil && len(state.Steps) > 0 {
+		return &SkipReason{Code: SkipAlreadyProvisioned, Message: fmt.Sprintf(`the application already holds the resources forged by the operation "%s"`, state.GUID)}, nil
+	}
+
+	return nil, nil
+}
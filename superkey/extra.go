@@ -0,0 +1,135 @@
+package superkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+)
+
+// ErrNoSuperKeyState is returned when an application's extra does not hold
+// any "_superkey" state.
+var ErrNoSuperKeyState = errors.New(`the application's extra does not hold any "_superkey" state`)
+
+// ErrApplicationLookup is wrapped by the registration errors that happen
+// because the application could not be fetched from Sources, before anything
+// got registered, which makes the registration worth retrying.
+var ErrApplicationLookup = errors.New("unable to fetch the application from Sources")
+
+// ParseSuperKeyExtra decodes the "_superkey" key of the given raw application
+// extra. The key is removed by setting it to null, and nothing documents that
+// Sources removes such keys rather than keeping them with a null value, so a
+// null state counts as no state at all.
+func ParseSuperKeyExtra(rawExtra []byte) (*SuperKeyExtra, error) {
+	extra := struct {
+		SuperKey *SuperKeyExtra `json:"_superkey"`
+	}{}
+
+	err := json.Unmarshal(rawExtra, &extra)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the application's extra: %w", err)
+	}
+
+	if extra.SuperKey == nil || extra.SuperKey.GUID == "" {
+		return nil, ErrNoSuperKeyState
+	}
+
+	if extra.SuperKey.Steps == nil {
+		extra.SuperKey.Steps = make(map[string]map[string]string)
+	}
+
+	return extra.SuperKey, nil
+}
+
+// FetchSuperKeyState fetches the application from Sources and returns the
+// "_superkey" state stored in its extra.
+func FetchSuperKeyState(ctx context.Context, identityHeader, orgIdHeader, applicationId string) (*SuperKeyExtra, error) {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: identityHeader,
+		OrgId:          orgIdHeader,
+	}
+
+	application, err := sourcesClient.GetApplication(ctx, authData, applicationId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSuperKeyExtra(application.Extra)
+}
+
+// FindApplicationByGUID lists the applications of the source in Sources and
+// returns the ID of the one whose "_superkey" state holds the given GUID, or
+// an empty ID when none of them does.
+func FindApplicationByGUID(ctx context.Context, identityHeader, orgIdHeader, sourceId, guid string) (string, error) {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return "", err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: identityHeader,
+		OrgId:          orgIdHeader,
+	}
+
+	applications, err := sourcesClient.ListSourceApplications(ctx, authData, sourceId)
+	if err != nil {
+		return "", err
+	}
+
+	for _, application := range applications {
+		state, err := ParseSuperKeyExtra(application.Extra)
+		if err == nil && state.GUID == guid {
+			return application.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// RestoreSensitiveExtra fetches the application from Sources and restores the
+// sensitive keys of the request's extra, such as the customer's external ID,
+// which the journal does not store.
+func (req *CreateRequest) RestoreSensitiveExtra(ctx context.Context) error {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: req.IdentityHeader,
+		OrgId:          req.OrgIdHeader,
+	}
+
+	application, err := sourcesClient.GetApplication(ctx, authData, req.ApplicationID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrApplicationLookup, err)
+	}
+
+	var extra map[string]interface{}
+	if len(application.Extra) > 0 {
+		err = json.Unmarshal(application.Extra, &extra)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal the application's extra: %w", err)
+		}
+	}
+
+	if req.Extra == nil {
+		req.Extra = make(map[string]string)
+	}
+
+	for key, value := range extra {
+		if text, ok := value.(string); ok && l.IsSensitiveKey(key) {
+			req.Extra[key] = text
+		}
+	}
+
+	return nil
+}
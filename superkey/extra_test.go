@@ -0,0 +1,48 @@
+package superkey
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// TestFindApplicationByGUID tests that the application of a destroy request is found among the source's applications
+// by the GUID of its superkey state.
+func TestFindApplicationByGUID(t *testing.T) {
+	server := setUpSources(t, nil)
+
+	sourceId := server.AddSource(sourcestest.Source{OrgID: testOrgId})
+	server.AddApplication(sourcestest.Application{OrgID: testOrgId, SourceID: sourceId})
+	server.AddApplication(sourcestest.Application{
+		OrgID:    testOrgId,
+		SourceID: sourceId,
+		Extra:    map[string]interface{}{"_superkey": map[string]interface{}{"guid": "other"}},
+	})
+	appId := server.AddApplication(sourcestest.Application{
+		OrgID:    testOrgId,
+		SourceID: sourceId,
+		Extra:    map[string]interface{}{"_superkey": map[string]interface{}{"guid": "guid"}},
+	})
+
+	got, err := FindApplicationByGUID(context.Background(), "", testOrgId, sourceId, "guid")
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if got != appId {
+		t.Errorf(`want the application "%s", got "%s"`, appId, got)
+	}
+
+	got, err = FindApplicationByGUID(context.Background(), "", testOrgId, sourceId, "missing")
+	if err != nil || got != "" {
+		t.Errorf(`want no application and no error, got "%s" and %v`, got, err)
+	}
+
+	_, err = FindApplicationByGUID(context.Background(), "", testOrgId, "missing", "guid")
+	if !errors.Is(err, sources.ErrNotFound) {
+		t.Errorf("want a not found error, got %v", err)
+	}
+}
@@ -2,13 +2,14 @@ package superkey
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/RedHatInsights/sources-api-go/model"
-	"github.com/redhatinsights/sources-superkey-worker/config"
 	l "github.com/redhatinsights/sources-superkey-worker/logger"
 	"github.com/redhatinsights/sources-superkey-worker/sources"
 )
@@ -19,10 +20,41 @@ func ReconstructForgedApplication(request *DestroyRequest) *ForgedApplication {
 	return &ForgedApplication{
 		StepsCompleted: request.StepsCompleted,
 		Request: &CreateRequest{
-			TenantID:      request.TenantID,
-			SuperKey:      request.SuperKey,
-			Provider:      request.Provider,
-			SuperKeySteps: request.SuperKeySteps,
+			IdentityHeader: request.IdentityHeader,
+			OrgIdHeader:    request.OrgIdHeader,
+			TenantID:       request.TenantID,
+			SourceID:       request.SourceID,
+			ApplicationID:  request.ApplicationID,
+			SuperKey:       request.SuperKey,
+			Provider:       request.Provider,
+			SuperKeySteps:  request.SuperKeySteps,
+		},
+		GUID: request.GUID,
+	}
+}
+
+// ReconstructForgedApplicationForUpdate - returns a ForgedApplication with the
+// fields set during the initial creation, and with the request's superkey steps
+// set to the new steps the application needs to be reconciled with
+func ReconstructForgedApplicationForUpdate(request *UpdateRequest) *ForgedApplication {
+	stepsCompleted := request.StepsCompleted
+	if stepsCompleted == nil {
+		stepsCompleted = make(map[string]map[string]string)
+	}
+
+	return &ForgedApplication{
+		StepsCompleted: stepsCompleted,
+		Request: &CreateRequest{
+			IdentityHeader:  request.IdentityHeader,
+			OrgIdHeader:     request.OrgIdHeader,
+			TenantID:        request.TenantID,
+			SourceID:        request.SourceID,
+			ApplicationID:   request.ApplicationID,
+			ApplicationType: request.ApplicationType,
+			SuperKey:        request.SuperKey,
+			Provider:        request.Provider,
+			Extra:           request.Extra,
+			SuperKeySteps:   request.SuperKeySteps,
 		},
 		GUID: request.GUID,
 	}
@@ -33,7 +65,9 @@ func (f *ForgedApplication) MarkCompleted(name string, data map[string]string) {
 	f.StepsCompleted[name] = data
 }
 
-// CreateInSourcesAPI - creates the forged application in sources
+// CreateInSourcesAPI - creates the forged application in sources. When a step
+// fails, the steps that were already completed get undone in reverse, so that
+// nothing is left behind in Sources while the resources get torn down in AWS.
 func (f *ForgedApplication) CreateInSourcesAPI(ctx context.Context) error {
 	l.LogWithContext(ctx).Debug("Sleeping to prevent IAM Race Condition")
 
@@ -41,25 +75,75 @@ func (f *ForgedApplication) CreateInSourcesAPI(ctx context.Context) error {
 	// before it's ready.
 	time.Sleep(waitTime() * time.Second)
 
-	sourcesClient := sources.NewSourcesClient(config.Get())
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
 
-	l.LogWithContext(ctx).Debugf("Posting resources back to Sources API: %v", f)
-	err := f.storeSuperKeyData(ctx, sourcesClient)
+	// Keep the extra the application had, so that it can be restored. Nothing
+	// has been registered yet at this point, so unless the application is
+	// gone, the registration can be retried as is.
+	application, err := sourcesClient.GetApplication(ctx, authData, f.Request.ApplicationID)
+	if errors.Is(err, sources.ErrNotFound) {
+		return fmt.Errorf("error while fetching the application from Sources: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrApplicationLookup, err)
+	}
+
+	var undos []registrationUndo
+
+	l.LogWithContext(ctx).WithField("forged_application", f).Debug("Posting resources back to Sources API")
+	err = f.storeSuperKeyData(ctx, sourcesClient)
 	if err != nil {
 		return fmt.Errorf("error while storing the superkey data in Sources: %w", err)
 	}
 
+	undos = append(undos, registrationUndo{
+		name: "superkey data",
+		run: func(ctx context.Context) error {
+			return sourcesClient.ResetApplicationExtra(ctx, authData, f.Request.ApplicationID, f.previousExtra(application.Extra))
+		},
+	})
+
 	l.LogWithContext(ctx).Info("Superkey data stored in Sources")
 
-	err = f.createAuthentications(ctx, sourcesClient)
+	authId, linked, err := f.registerAuthentication(ctx, sourcesClient, authData)
 	if err != nil {
+		f.undoRegistration(ctx, undos, err)
 		return fmt.Errorf("error while creating the authentications in Sources: %w", err)
 	}
 
+	undos = append(undos, registrationUndo{
+		name: "authentication",
+		run: func(ctx context.Context) error {
+			err := sourcesClient.DeleteAuthentication(ctx, authData, authId)
+			if errors.Is(err, sources.ErrNotFound) {
+				return nil
+			}
+
+			return err
+		},
+	})
+
+	if !linked {
+		err = f.linkAuthentication(ctx, sourcesClient, authId)
+		if err != nil {
+			f.undoRegistration(ctx, undos, err)
+			return fmt.Errorf("error while creating the authentications in Sources: %w", err)
+		}
+	}
+
 	l.LogWithContext(ctx).Info("Authentications created in Sources")
 
 	err = f.checkAvailability(ctx, sourcesClient)
 	if err != nil {
+		f.undoRegistration(ctx, undos, err)
 		return fmt.Errorf("error while triggering an availability check in Sources: %w", err)
 	}
 
@@ -69,37 +153,268 @@ func (f *ForgedApplication) CreateInSourcesAPI(ctx context.Context) error {
 	return nil
 }
 
-func (f *ForgedApplication) createAuthentications(ctx context.Context, sourcesRestClient sources.RestClient) error {
+// IsRegistered - checks whether the forged application was already registered
+// in Sources, which is the case when an authentication for the forged
+// resources is linked to the application. The registration creates a new
+// authentication every time, so it must not be repeated when it already went
+// through.
+func (f *ForgedApplication) IsRegistered(ctx context.Context) (bool, error) {
+	if f.Product == nil || f.Product.AuthPayload.Username == nil {
+		return false, nil
+	}
+
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return false, err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
+
+	authId, err := f.existingAuthentication(ctx, sourcesClient, authData)
+	if err != nil {
+		return false, err
+	}
+
+	return authId != "", nil
+}
+
+// existingAuthentication lists the application's authentications in Sources,
+// and returns the ID of the one created for the forged resources, or an empty
+// ID when there is none.
+func (f *ForgedApplication) existingAuthentication(ctx context.Context, sourcesRestClient sources.RestClient, authData *sources.AuthenticationData) (string, error) {
+	if f.Product.AuthPayload.Username == nil {
+		return "", nil
+	}
+
+	authentications, err := sourcesRestClient.ListApplicationAuthentications(ctx, authData, f.Request.ApplicationID)
+	if err != nil {
+		return "", fmt.Errorf("error while fetching the application's authentications from Sources: %w", err)
+	}
+
+	for _, authentication := range authentications {
+		if authentication.AuthType == f.Product.AuthPayload.AuthType && authentication.Username == *f.Product.AuthPayload.Username {
+			return authentication.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// undoRegistration undoes the given registration steps in reverse. The
+// failures are logged, so that the remaining steps still get undone. Nothing
+// is undone when the registration failed because the Sources API is
+// unavailable, since it gets resumed once the API is back.
+func (f *ForgedApplication) undoRegistration(ctx context.Context, undos []registrationUndo, cause error) {
+	if errors.Is(cause, sources.ErrCircuitOpen) {
+		return
+	}
+
+	// The next registration must not be mistaken for a replay of this one.
+	f.registrationAttempt++
+
+	// The rollback has to finish even when we are shutting down.
+	ctx = context.WithoutCancel(ctx)
+
+	for i := len(undos) - 1; i >= 0; i-- {
+		err := undos[i].run(ctx)
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Unable to undo the registration of the %s in Sources, it needs to be removed manually: %s`, undos[i].name, err)
+			continue
+		}
+
+		l.LogWithContext(ctx).Infof(`Registration of the %s undone in Sources`, undos[i].name)
+	}
+}
+
+// previousExtra returns the extra that restores the given raw extra, with the
+// keys that the superkey data added set to nil so that they get removed. In
+// case Sources keeps them with a null value instead, they still read as absent,
+// since a null "_superkey" state is the same as no state at all.
+func (f *ForgedApplication) previousExtra(rawExtra json.RawMessage) map[string]interface{} {
 	extra := map[string]interface{}{}
-	externalID, ok := f.Request.Extra["external_id"]
-	if ok {
-		extra["external_id"] = externalID
+	if len(rawExtra) > 0 {
+		err := json.Unmarshal(rawExtra, &extra)
+		if err != nil {
+			l.Log.Warnf("Unable to decode the previous extra of the application, the superkey keys will just be removed: %s", err)
+			extra = map[string]interface{}{}
+		}
 	}
 
+	for key := range f.Product.Extra {
+		if _, ok := extra[key]; !ok {
+			extra[key] = nil
+		}
+	}
+
+	return extra
+}
+
+// UpdateInSourcesAPI - stores the reconciled state of the forged application in
+// the application's extra in sources
+func (f *ForgedApplication) UpdateInSourcesAPI(ctx context.Context) error {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
+
+	l.LogWithContext(ctx).WithField("forged_application", f).Debug("Posting updated resources back to Sources API")
+	err = f.storeSuperKeyData(ctx, sourcesClient)
+	if err != nil {
+		return fmt.Errorf("error while storing the updated superkey data in Sources: %w", err)
+	}
+
+	l.LogWithContext(ctx).Info("Updated superkey data stored in Sources")
+
+	return nil
+}
+
+// withIdempotencyKey scopes the requests of the given registration step to the
+// current registration attempt, so that they get retried when they fail without
+// sharing a key with the requests of any other operation or attempt.
+func (f *ForgedApplication) withIdempotencyKey(ctx context.Context, step string) context.Context {
+	return sources.WithIdempotencyKey(ctx, sources.OperationIdempotencyKey(f.GUID, f.Operation, step, f.registrationAttempt))
+}
+
+// authenticationCreationAttempts is how many times the creation of the
+// authentication is sent to Sources, as long as the failed attempts did not
+// create it.
+const authenticationCreationAttempts = 2
+
+// registerAuthentication creates the authentication of the forged application
+// in Sources. Creating an authentication is not idempotent, so before sending
+// a failed creation again, the application's authentications get listed, and
+// the one that the failed creation might have created anyway is reused.
+// returns: the ID of the authentication, and whether it is linked to the
+// application.
+func (f *ForgedApplication) registerAuthentication(ctx context.Context, sourcesRestClient sources.RestClient, authData *sources.AuthenticationData) (string, bool, error) {
+	// The bulk create links the authentication to the application in the same
+	// transaction it creates the authentication in.
+	bulkCreate := sourcesRestClient.BulkCreateEnabled()
+
+	var err error
+	for attempt := 1; attempt <= authenticationCreationAttempts; attempt++ {
+		var authId string
+		var linked bool
+		if bulkCreate {
+			authId, linked, err = f.bulkCreateAuthentication(ctx, sourcesRestClient)
+		} else {
+			authId, err = f.createAuthentication(ctx, sourcesRestClient)
+		}
+
+		if err == nil || errors.Is(err, sources.ErrCircuitOpen) {
+			return authId, linked, err
+		}
+
+		existingId, lookupErr := f.existingAuthentication(ctx, sourcesRestClient, authData)
+		if lookupErr != nil {
+			return "", false, fmt.Errorf("%w, and whether the authentication got created anyway is unknown: %w", err, lookupErr)
+		}
+
+		if existingId != "" {
+			l.LogWithContext(ctx).Warnf(`The failed creation of the authentication went through, reusing the authentication "%s": %s`, existingId, err)
+			return existingId, bulkCreate, nil
+		}
+
+		if attempt < authenticationCreationAttempts {
+			l.LogWithContext(ctx).Warnf("Unable to create the authentication in Sources, retrying: %s", err)
+		}
+	}
+
+	return "", false, err
+}
+
+// createAuthentication creates the authentication of the forged application in
+// Sources.
+// returns: the ID of the created authentication.
+func (f *ForgedApplication) createAuthentication(ctx context.Context, sourcesRestClient sources.RestClient) (string, error) {
+	authData := sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
+
+	auth := f.authenticationPayload()
+
+	createdAuthentication, err := sourcesRestClient.CreateAuthentication(f.withIdempotencyKey(ctx, "create_authentication"), &authData, &auth)
+	if err != nil {
+		return "", fmt.Errorf("error while creating the authentication in Sources: %w", err)
+	}
+
+	return createdAuthentication.ID, nil
+}
+
+// bulkCreateAuthentication creates the authentication of the forged application
+// in Sources along with its link to the application, in a single request.
+// returns: the ID of the created authentication, and whether Sources linked it
+// to the application.
+func (f *ForgedApplication) bulkCreateAuthentication(ctx context.Context, sourcesRestClient sources.RestClient) (string, bool, error) {
 	authData := sources.AuthenticationData{
 		IdentityHeader: f.Request.IdentityHeader,
 		OrgId:          f.Request.OrgIdHeader,
 	}
 
-	auth := model.AuthenticationCreateRequest{
+	bulkCreateRequest := sources.BulkCreateRequest{
+		Authentications: []sources.BulkCreateAuthentication{{
+			AuthenticationCreateRequest: f.authenticationPayload(),
+			ResourceName:                f.Request.ApplicationType,
+		}},
+	}
+
+	created, err := sourcesRestClient.BulkCreate(f.withIdempotencyKey(ctx, "bulk_create"), &authData, &bulkCreateRequest)
+	if err != nil {
+		return "", false, fmt.Errorf("error while bulk creating the authentication in Sources: %w", err)
+	}
+
+	if len(created.Authentications) == 0 {
+		return "", false, errors.New("the bulk create did not return the created authentication")
+	}
+
+	authId := created.Authentications[0].ID
+	for _, appAuth := range created.ApplicationAuthentications {
+		if appAuth.AuthenticationID == authId && appAuth.ApplicationID == f.Request.ApplicationID {
+			return authId, true, nil
+		}
+	}
+
+	l.LogWithContext(ctx).Warn("The bulk create did not link the authentication to the application, linking it separately")
+
+	return authId, false, nil
+}
+
+// authenticationPayload returns the authentication of the forged application
+// to be created in Sources.
+func (f *ForgedApplication) authenticationPayload() model.AuthenticationCreateRequest {
+	extra := map[string]interface{}{}
+	externalID, ok := f.Request.Extra["external_id"]
+	if ok {
+		extra["external_id"] = externalID
+	}
+
+	return model.AuthenticationCreateRequest{
 		AuthType:      f.Product.AuthPayload.AuthType,
 		Username:      f.Product.AuthPayload.Username,
 		ResourceType:  f.Product.AuthPayload.ResourceType,
 		ResourceIDRaw: f.Request.ApplicationID,
 		Extra:         extra,
 	}
+}
 
-	createdAuthentication, err := sourcesRestClient.CreateAuthentication(ctx, &authData, &auth)
-	if err != nil {
-		return fmt.Errorf("error while creating the authentication in Sources: %w", err)
+// linkAuthentication associates the given authentication with the forged
+// application in Sources.
+func (f *ForgedApplication) linkAuthentication(ctx context.Context, sourcesRestClient sources.RestClient, authId string) error {
+	authData := sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
 	}
 
 	appAuthBody := model.ApplicationAuthenticationCreateRequest{
 		ApplicationIDRaw:    f.Request.ApplicationID,
-		AuthenticationIDRaw: createdAuthentication.ID,
+		AuthenticationIDRaw: authId,
 	}
 
-	err = sourcesRestClient.CreateApplicationAuthentication(ctx, &authData, &appAuthBody)
+	err := sourcesRestClient.CreateApplicationAuthentication(f.withIdempotencyKey(ctx, "link_authentication"), &authData, &appAuthBody)
 	if err != nil {
 		return fmt.Errorf("error while associating the authentication with an application in Sources: %w", err)
 	}
@@ -127,7 +442,7 @@ func (f *ForgedApplication) checkAvailability(ctx context.Context, sourcesRestCl
 		OrgId:          f.Request.OrgIdHeader,
 	}
 
-	err := sourcesRestClient.TriggerSourceAvailabilityCheck(ctx, &authData, f.Product.SourceID)
+	err := sourcesRestClient.TriggerSourceAvailabilityCheck(f.withIdempotencyKey(ctx, "check_availability"), &authData, f.Product.SourceID)
 	if err != nil {
 		return err
 	}
@@ -188,3 +503,14 @@ func waitTime() time.Duration {
 
 	return time.Duration(i)
 }
+
+// StepResource returns the identifier of the resource created by the given
+// completed step. The binding of the role is identified by both the role and
+// the policy.
+func (f *ForgedApplication) StepResource(step string) string {
+	if step == "bind_role" {
+		return fmt.Sprintf("%s:%s", f.StepsCompleted["role"]["output"], f.StepsCompleted["policy"]["output"])
+	}
+
+	return f.StepsCompleted[step]["output"]
+}
@@ -0,0 +1,291 @@
+package superkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// testRoleArn is the username of the authentication the forged applications register.
+const testRoleArn = "arn:aws:iam::123456789012:role/superkey"
+
+// newTestForgedApplication stores a source and an application in the fake Sources API, and returns a forged
+// application for them which is ready to be registered.
+func newTestForgedApplication(server *sourcestest.Server) *ForgedApplication {
+	sourceId := server.AddSource(sourcestest.Source{OrgID: testOrgId})
+	appId := server.AddApplication(sourcestest.Application{
+		OrgID:    testOrgId,
+		SourceID: sourceId,
+		Extra:    map[string]interface{}{"external_id": "external"},
+	})
+
+	f := &ForgedApplication{
+		StepsCompleted: map[string]map[string]string{"role": {"output": testRoleArn}},
+		Request: &CreateRequest{
+			OrgIdHeader:     testOrgId,
+			SourceID:        sourceId,
+			ApplicationID:   appId,
+			ApplicationType: "/insights/platform/cloud-meter",
+			Provider:        "amazon",
+			Extra:           map[string]string{"result_type": "cloud-meter-arn"},
+		},
+		GUID:      "guid",
+		Operation: "Application.create",
+	}
+
+	username := testRoleArn
+	f.CreatePayload(&username, nil, nil)
+
+	return f
+}
+
+// superKeyState returns the "_superkey" state stored in the extra of the application in the fake Sources API.
+func superKeyState(t *testing.T, server *sourcestest.Server, appId string) (*SuperKeyExtra, error) {
+	t.Helper()
+
+	application, ok := server.Application(appId)
+	if !ok {
+		t.Fatalf(`the application "%s" does not exist`, appId)
+	}
+
+	rawExtra, err := json.Marshal(application.Extra)
+	if err != nil {
+		t.Fatalf("unable to encode the application's extra: %s", err)
+	}
+
+	return ParseSuperKeyExtra(rawExtra)
+}
+
+// TestCreateInSourcesAPI tests that the registration stores the superkey state, creates the authentication, links it
+// to the application and triggers an availability check.
+func TestCreateInSourcesAPI(t *testing.T) {
+	server := setUpSources(t, nil)
+	f := newTestForgedApplication(server)
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	state, err := superKeyState(t, server, f.Request.ApplicationID)
+	if err != nil {
+		t.Fatalf("want the superkey state to be stored, got %s", err)
+	}
+
+	if state.GUID != "guid" || state.Steps["role"]["output"] != testRoleArn {
+		t.Errorf("want the state of the forged application, got %+v", state)
+	}
+
+	authentications := server.Authentications()
+	if len(authentications) != 1 {
+		t.Fatalf("want 1 authentication, got %d", len(authentications))
+	}
+
+	if authentications[0].Username != testRoleArn || authentications[0].ResourceID != f.Request.ApplicationID {
+		t.Errorf("want the role's authentication for the application, got %+v", authentications[0])
+	}
+
+	links := server.ApplicationAuthentications()
+	if len(links) != 1 || links[0].AuthenticationID != authentications[0].ID {
+		t.Errorf("want the authentication to be linked to the application, got %+v", links)
+	}
+
+	source, _ := server.Source(f.Request.SourceID)
+	if source.AvailabilityChecks != 1 {
+		t.Errorf("want 1 availability check, got %d", source.AvailabilityChecks)
+	}
+
+	registered, err := f.IsRegistered(context.Background())
+	if err != nil || !registered {
+		t.Errorf("want the application to be registered, got %t and %v", registered, err)
+	}
+}
+
+// TestCreateInSourcesAPIWithBulkCreate tests that the bulk create registers and links the authentication in a single
+// request.
+func TestCreateInSourcesAPIWithBulkCreate(t *testing.T) {
+	server := setUpSources(t, func(conf *config.SuperKeyWorkerConfig) {
+		conf.SourcesBulkCreate = true
+	})
+	f := newTestForgedApplication(server)
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	bulkCreates := server.RequestsTo(http.MethodPost, "/api/sources/v3.1/bulk_create")
+	if len(bulkCreates) != 1 {
+		t.Fatalf("want 1 bulk create, got %d", len(bulkCreates))
+	}
+
+	if bulkCreates[0].Header.Get("Idempotency-Key") == "" {
+		t.Error("want the bulk create to carry an idempotency key")
+	}
+
+	if got := len(server.RequestsTo(http.MethodPost, "/api/sources/v3.1/application_authentications")); got != 0 {
+		t.Errorf("want the authentication not to be linked separately, got %d links", got)
+	}
+
+	if got := len(server.ApplicationAuthentications()); got != 1 {
+		t.Errorf("want 1 link, got %d", got)
+	}
+}
+
+// TestCreateInSourcesAPIRetriesTheCreation tests that a failed authentication creation that did not create the
+// authentication gets retried with the same idempotency key, without creating the authentication twice.
+func TestCreateInSourcesAPIRetriesTheCreation(t *testing.T) {
+	server := setUpSources(t, nil)
+	f := newTestForgedApplication(server)
+
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: "/api/sources/v3.1/authentications", StatusCode: http.StatusServiceUnavailable, Times: 1})
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	creations := server.RequestsTo(http.MethodPost, "/api/sources/v3.1/authentications")
+	if len(creations) != 2 {
+		t.Fatalf("want 2 attempts, got %d", len(creations))
+	}
+
+	if creations[0].Header.Get("Idempotency-Key") != creations[1].Header.Get("Idempotency-Key") {
+		t.Error("want both attempts to carry the same idempotency key")
+	}
+
+	if got := len(server.Authentications()); got != 1 {
+		t.Errorf("want 1 authentication, got %d", got)
+	}
+}
+
+// TestCreateInSourcesAPIReusesTheAuthentication tests that the authentication a failed creation created anyway gets
+// reused instead of being created again.
+func TestCreateInSourcesAPIReusesTheAuthentication(t *testing.T) {
+	server := setUpSources(t, nil)
+	f := newTestForgedApplication(server)
+
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: "/api/sources/v3.1/authentications", StatusCode: http.StatusBadGateway, Times: 1, Served: true})
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if got := len(server.RequestsTo(http.MethodPost, "/api/sources/v3.1/authentications")); got != 1 {
+		t.Errorf("want 1 attempt, got %d", got)
+	}
+
+	authentications := server.Authentications()
+	if len(authentications) != 1 {
+		t.Fatalf("want 1 authentication, got %d", len(authentications))
+	}
+
+	links := server.ApplicationAuthentications()
+	if len(links) != 1 || links[0].AuthenticationID != authentications[0].ID {
+		t.Errorf("want the reused authentication to be linked to the application, got %v", links)
+	}
+}
+
+// TestCreateInSourcesAPIRollsBack tests that a failed registration removes what it stored in Sources, and that the
+// next registration does not reuse its idempotency keys.
+func TestCreateInSourcesAPIRollsBack(t *testing.T) {
+	server := setUpSources(t, nil)
+	f := newTestForgedApplication(server)
+
+	linkPath := "/api/sources/v3.1/application_authentications"
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: linkPath, StatusCode: http.StatusBadRequest, Times: 1})
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if err == nil {
+		t.Fatal("want an error, got none")
+	}
+
+	if got := len(server.Authentications()); got != 0 {
+		t.Errorf("want the authentication to be deleted, got %d authentications", got)
+	}
+
+	_, err = superKeyState(t, server, f.Request.ApplicationID)
+	if !errors.Is(err, ErrNoSuperKeyState) {
+		t.Errorf("want the superkey state to be removed, got %v", err)
+	}
+
+	application, _ := server.Application(f.Request.ApplicationID)
+	if application.Extra["external_id"] != "external" {
+		t.Errorf(`want the previous extra to be kept, got %v`, application.Extra)
+	}
+
+	registered, err := f.IsRegistered(context.Background())
+	if err != nil || registered {
+		t.Errorf("want the application not to be registered, got %t and %v", registered, err)
+	}
+
+	err = f.CreateInSourcesAPI(context.Background())
+	if err != nil {
+		t.Fatalf("want the next registration to succeed, got %s", err)
+	}
+
+	links := server.RequestsTo(http.MethodPost, linkPath)
+	if len(links) != 2 {
+		t.Fatalf("want 2 links, got %d", len(links))
+	}
+
+	if links[0].Header.Get("Idempotency-Key") == links[1].Header.Get("Idempotency-Key") {
+		t.Error("want the next registration to use another idempotency key")
+	}
+}
+
+// TestCreateInSourcesAPIWithOpenCircuit tests that nothing gets undone when the registration fails because the
+// circuit breaker opened, since the registration gets resumed once Sources is back.
+func TestCreateInSourcesAPIWithOpenCircuit(t *testing.T) {
+	server := setUpSources(t, func(conf *config.SuperKeyWorkerConfig) {
+		conf.SourcesBreakerThreshold = 1
+	})
+	f := newTestForgedApplication(server)
+
+	server.Fail(sourcestest.Failure{Method: http.MethodPost, Path: "/api/sources/v3.1/authentications", StatusCode: http.StatusServiceUnavailable})
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if !errors.Is(err, sources.ErrCircuitOpen) {
+		t.Fatalf("want an open circuit error, got %v", err)
+	}
+
+	if _, err := superKeyState(t, server, f.Request.ApplicationID); err != nil {
+		t.Errorf("want the superkey state to be kept, got %s", err)
+	}
+
+	if f.registrationAttempt != 0 {
+		t.Errorf("want the resumed registration to reuse the idempotency keys, got attempt %d", f.registrationAttempt)
+	}
+}
+
+// TestCreateInSourcesAPIApplicationLookup tests that the registration is only worth retrying when the application
+// could not be fetched, and not when it is gone.
+func TestCreateInSourcesAPIApplicationLookup(t *testing.T) {
+	server := setUpSources(t, nil)
+	f := newTestForgedApplication(server)
+
+	server.Fail(sourcestest.Failure{Method: http.MethodGet, Path: "/api/sources/v3.1/applications/" + f.Request.ApplicationID, StatusCode: http.StatusBadRequest, Times: 1})
+
+	err := f.CreateInSourcesAPI(context.Background())
+	if !errors.Is(err, ErrApplicationLookup) {
+		t.Errorf("want an application lookup error, got %v", err)
+	}
+
+	if got := len(server.RequestsTo(http.MethodPatch, "/api/sources/v3.1/applications/"+f.Request.ApplicationID)); got != 0 {
+		t.Errorf("want nothing to be stored, got %d patches", got)
+	}
+
+	f.Request.ApplicationID = "missing"
+
+	err = f.CreateInSourcesAPI(context.Background())
+	if !errors.Is(err, sources.ErrNotFound) || errors.Is(err, ErrApplicationLookup) {
+		t.Errorf("want a not found error, got %v", err)
+	}
+}
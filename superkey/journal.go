@@ -0,0 +1,177 @@
+package superkey
+
+import (
+	"context"
+	"fmt"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// The phases an operation goes through. The operations that are not in one of
+// the final phases when the worker starts were interrupted.
+const (
+	PhaseForging     = "forging"
+	PhaseRegistering = "registering"
+	PhaseUpdating    = "updating"
+	PhaseTearingDown = "tearing_down"
+
+	PhaseCompleted  = "completed"
+	PhaseRolledBack = "rolled_back"
+	PhaseFailed     = "failed"
+)
+
+// InitialPhase returns the phase the operations of the given event type start
+// in.
+func InitialPhase(eventType string) string {
+	switch eventType {
+	case "create_application":
+		return PhaseForging
+	case "update_application":
+		return PhaseUpdating
+	default:
+		return PhaseTearingDown
+	}
+}
+
+// The actions recorded in the journal for every step.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// operationJournal is the journal every forged application records its
+// operations in. It does not record anything until a journal is set.
+var operationJournal Journal = noopJournal{}
+
+// SetJournal sets the journals the operations get recorded in. Every record
+// goes to the journals in the given order, and stops at the first one that
+// fails to store it.
+func SetJournal(journals ...Journal) {
+	switch len(journals) {
+	case 0:
+		operationJournal = noopJournal{}
+	case 1:
+		operationJournal = journals[0]
+	default:
+		operationJournal = multiJournal(journals)
+	}
+}
+
+// BeginOperation registers a new operation for the forged application in the
+// journal.
+func (f *ForgedApplication) BeginOperation(ctx context.Context, eventType string) error {
+	f.Operation = eventType
+
+	err := operationJournal.Begin(f, eventType)
+	if err != nil {
+		return fmt.Errorf(`unable to record the "%s" operation in the journal: %w`, eventType, err)
+	}
+
+	return nil
+}
+
+// RecordIntent records in the journal that the given action is about to be
+// performed on the step. The action must not be performed if the intent could
+// not be recorded, since it could not be recovered after a crash.
+func (f *ForgedApplication) RecordIntent(ctx context.Context, action, step string, data map[string]string) error {
+	err := operationJournal.RecordIntent(f, action, step, data)
+	if err != nil {
+		return fmt.Errorf(`unable to record the intent to %s the "%s" step in the journal: %w`, action, step, err)
+	}
+
+	return nil
+}
+
+// RecordOutcome records in the journal the outcome of the given action. Since
+// the action has already been performed at this point, a failure to record
+// the outcome only gets logged.
+func (f *ForgedApplication) RecordOutcome(ctx context.Context, action, step string, stepErr error) {
+	err := operationJournal.RecordOutcome(f, action, step, f.StepsCompleted[step], stepErr)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to record the outcome of the "%s" action on the "%s" step in the journal: %s`, action, step, err)
+	}
+}
+
+// SetPhase records in the journal that the operation moved to the given phase.
+func (f *ForgedApplication) SetPhase(ctx context.Context, phase string) {
+	err := operationJournal.SetPhase(f, phase)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to record the "%s" phase in the journal: %s`, phase, err)
+	}
+}
+
+// FinishOperation marks the operation as finished in the journal, along with
+// the error that made it fail, if any.
+func (f *ForgedApplication) FinishOperation(ctx context.Context, phase string, opErr error) {
+	err := operationJournal.Finish(f, phase, opErr)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to record the end of the operation as "%s" in the journal: %s`, phase, err)
+	}
+}
+
+// noopJournal is the journal used when no journal has been configured.
+type noopJournal struct{}
+
+func (noopJournal) Begin(*ForgedApplication, string) error { return nil }
+func (noopJournal) RecordIntent(*ForgedApplication, string, string, map[string]string) error {
+	return nil
+}
+func (noopJournal) RecordOutcome(*ForgedApplication, string, string, map[string]string, error) error {
+	return nil
+}
+func (noopJournal) SetPhase(*ForgedApplication, string) error      { return nil }
+func (noopJournal) Finish(*ForgedApplication, string, error) error { return nil }
+
+// multiJournal records the operations in several journals.
+type multiJournal []Journal
+
+func (m multiJournal) Begin(f *ForgedApplication, eventType string) error {
+	for _, j := range m {
+		if err := j.Begin(f, eventType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m multiJournal) RecordIntent(f *ForgedApplication, action, step string, data map[string]string) error {
+	for _, j := range m {
+		if err := j.RecordIntent(f, action, step, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m multiJournal) RecordOutcome(f *ForgedApplication, action, step string, data map[string]string, stepErr error) error {
+	for _, j := range m {
+		if err := j.RecordOutcome(f, action, step, data, stepErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m multiJournal) SetPhase(f *ForgedApplication, phase string) error {
+	for _, j := range m {
+		if err := j.SetPhase(f, phase); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m multiJournal) Finish(f *ForgedApplication, phase string, opErr error) error {
+	for _, j := range m {
+		if err := j.Finish(f, phase, opErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package superkey
+
+import (
+	"os"
+	"testing"
+
+	"github.com/redhatinsights/sources-superkey-worker/config"
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// testOrgId is the organization the fixtures of the fake Sources API belong to.
+const testOrgId = "12345"
+
+func TestMain(m *testing.M) {
+	l.InitLogger(&config.SuperKeyWorkerConfig{})
+
+	// Nothing waits for IAM in the tests.
+	os.Setenv("AWS_WAIT_TIME", "0")
+
+	os.Exit(m.Run())
+}
+
+// setUpSources starts a fake Sources API, and makes the shared Sources client and a fresh steps cache point to it.
+// The given function can tweak the configuration the client gets created from.
+func setUpSources(t *testing.T, configure func(conf *config.SuperKeyWorkerConfig)) *sourcestest.Server {
+	t.Helper()
+
+	server := sourcestest.NewServer()
+	t.Cleanup(server.Close)
+
+	conf := server.Config()
+	if configure != nil {
+		configure(conf)
+	}
+
+	err := sources.SetClient(conf)
+	if err != nil {
+		t.Fatalf("unable to set the Sources client: %s", err)
+	}
+
+	SetStepsCache(NewStepsCache(0))
+
+	return server
+}
@@ -0,0 +1,153 @@
+package superkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+)
+
+// ErrNoSuperKeySteps is returned when neither the application type's superkey
+// metadata nor the request provide any superkey steps.
+var ErrNoSuperKeySteps = errors.New("no superkey steps in the application type's metadata nor in the request")
+
+// applicationTypeSteps caches the superkey steps of the application types. It
+// does not keep them at all until a cache is set with "SetStepsCache".
+var applicationTypeSteps = NewStepsCache(0)
+
+// NewStepsCache returns an empty cache, which keeps the superkey steps of the
+// application types for the given TTL.
+func NewStepsCache(ttl time.Duration) *StepsCache {
+	return &StepsCache{
+		ttl:     ttl,
+		entries: make(map[string]stepsCacheEntry),
+	}
+}
+
+// SetStepsCache makes "ResolveSteps" keep the superkey steps of the
+// application types in the given cache. It is meant to be called on startup.
+func SetStepsCache(cache *StepsCache) {
+	applicationTypeSteps = cache
+}
+
+// ResolveSteps sets the superkey steps of the request from the superkey
+// metadata of its application type in Sources, so that the fixes to the
+// metadata apply to the requests that were already queued. The steps embedded
+// in the request are only used when the metadata cannot be fetched or does not
+// hold any steps.
+func (req *CreateRequest) ResolveSteps(ctx context.Context) error {
+	metadataSteps, err := applicationTypeSteps.get(ctx, req)
+	if err != nil {
+		if len(req.SuperKeySteps) == 0 {
+			return fmt.Errorf("unable to fetch the superkey metadata of the application type: %w", err)
+		}
+
+		l.LogWithContext(ctx).Warnf("Using the superkey steps embedded in the request, since the superkey metadata of the application type could not be fetched: %s", err)
+		return nil
+	}
+
+	switch {
+	case len(metadataSteps) == 0 && len(req.SuperKeySteps) == 0:
+		return ErrNoSuperKeySteps
+	case len(metadataSteps) == 0:
+		l.LogWithContext(ctx).Debug("Using the superkey steps embedded in the request, since the application type does not have any superkey metadata")
+	default:
+		req.SuperKeySteps = metadataSteps
+		l.LogWithContext(ctx).Debug("Using the superkey steps of the application type")
+	}
+
+	return nil
+}
+
+// get returns the superkey steps of the request's application type, fetching
+// them from Sources when they are not cached or their TTL expired. The
+// expired steps are still returned when fetching them again fails.
+func (c *StepsCache) get(ctx context.Context, req *CreateRequest) ([]Step, error) {
+	c.mu.Lock()
+	entry, cached := c.entries[req.ApplicationType]
+	c.mu.Unlock()
+
+	if cached && time.Since(entry.fetchedAt) < c.ttl {
+		return copySteps(entry.steps), nil
+	}
+
+	steps, err := fetchApplicationTypeSteps(ctx, req)
+	if err != nil {
+		if cached {
+			l.LogWithContext(ctx).Warnf("Using the expired superkey metadata of the application type, since it could not be fetched again: %s", err)
+			return copySteps(entry.steps), nil
+		}
+
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[req.ApplicationType] = stepsCacheEntry{steps: steps, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return copySteps(steps), nil
+}
+
+// fetchApplicationTypeSteps fetches the superkey steps of the request's
+// application type from Sources.
+func fetchApplicationTypeSteps(ctx context.Context, req *CreateRequest) ([]Step, error) {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: req.IdentityHeader,
+		OrgId:          req.OrgIdHeader,
+	}
+
+	applicationType, err := sourcesClient.GetApplicationType(ctx, authData, req.ApplicationType)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := decodeSuperKeyMetadata(applicationType.SuperKeyMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the superkey metadata of the application type: %w", err)
+	}
+
+	return steps, nil
+}
+
+// decodeSuperKeyMetadata decodes the steps of an application type's superkey
+// metadata, which holds them either as a bare list or in the "steps" key of
+// an object.
+func decodeSuperKeyMetadata(raw json.RawMessage) ([]Step, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+
+	var steps []Step
+	if trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &steps)
+		return steps, err
+	}
+
+	metadata := struct {
+		Steps []Step `json:"steps"`
+	}{}
+
+	err := json.Unmarshal(trimmed, &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.Steps, nil
+}
+
+// copySteps returns a copy of the given steps, so that the cached ones do not
+// get modified through the requests.
+func copySteps(steps []Step) []Step {
+	return append([]Step(nil), steps...)
+}
@@ -0,0 +1,102 @@
+package superkey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/sources/sourcestest"
+)
+
+// testApplicationType is the name of the application type the metadata tests resolve the steps of.
+const testApplicationType = "/insights/platform/cost-management"
+
+// metadataSteps returns the superkey steps as the application types' metadata holds them.
+func metadataSteps() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"step": 1, "name": "s3", "payload": "metadata-bucket"},
+		{"step": 2, "name": "policy", "payload": "metadata-policy"},
+	}
+}
+
+// TestResolveStepsFromList tests that the steps come from the metadata when it holds them as a list.
+func TestResolveStepsFromList(t *testing.T) {
+	server := setUpSources(t, nil)
+	server.AddApplicationType(sourcestest.ApplicationType{Name: testApplicationType, SuperKeyMetadata: metadataSteps()})
+
+	req := &CreateRequest{OrgIdHeader: testOrgId, ApplicationType: testApplicationType, SuperKeySteps: []Step{{Step: 1, Name: "s3", Payload: "request-bucket"}}}
+
+	err := req.ResolveSteps(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if len(req.SuperKeySteps) != 2 || req.SuperKeySteps[0].Payload != "metadata-bucket" {
+		t.Errorf("want the steps of the metadata, got %+v", req.SuperKeySteps)
+	}
+}
+
+// TestResolveStepsFromObject tests that the steps come from the "steps" key of the metadata when it is an object, and
+// that they take precedence over the request's steps.
+func TestResolveStepsFromObject(t *testing.T) {
+	server := setUpSources(t, nil)
+	server.AddApplicationType(sourcestest.ApplicationType{Name: testApplicationType, SuperKeyMetadata: map[string]interface{}{"steps": metadataSteps()}})
+
+	req := &CreateRequest{
+		OrgIdHeader:     testOrgId,
+		ApplicationType: testApplicationType,
+		SuperKeySteps:   []Step{{Step: 1, Name: "s3", Payload: "request-bucket"}},
+	}
+
+	err := req.ResolveSteps(context.Background())
+	if err != nil {
+		t.Fatalf("want no error, got %s", err)
+	}
+
+	if len(req.SuperKeySteps) != 2 || req.SuperKeySteps[0].Payload != "metadata-bucket" || req.SuperKeySteps[1].Payload != "metadata-policy" {
+		t.Errorf("want the steps of the metadata, got %+v", req.SuperKeySteps)
+	}
+}
+
+// TestResolveStepsWithoutMetadata tests that the request's steps are used when the application type cannot be
+// fetched, and that the request fails without them.
+func TestResolveStepsWithoutMetadata(t *testing.T) {
+	setUpSources(t, nil)
+
+	req := &CreateRequest{OrgIdHeader: testOrgId, ApplicationType: testApplicationType, SuperKeySteps: []Step{{Step: 1, Name: "s3", Payload: "request-bucket"}}}
+
+	err := req.ResolveSteps(context.Background())
+	if err != nil || len(req.SuperKeySteps) != 1 {
+		t.Errorf("want the request's steps, got %+v and %v", req.SuperKeySteps, err)
+	}
+
+	req.SuperKeySteps = nil
+
+	err = req.ResolveSteps(context.Background())
+	if err == nil || errors.Is(err, ErrNoSuperKeySteps) {
+		t.Errorf("want a metadata error, got %v", err)
+	}
+}
+
+// TestResolveStepsCache tests that the cached steps are not fetched again until their TTL expires.
+func TestResolveStepsCache(t *testing.T) {
+	server := setUpSources(t, nil)
+	server.AddApplicationType(sourcestest.ApplicationType{Name: testApplicationType, SuperKeyMetadata: metadataSteps()})
+
+	SetStepsCache(NewStepsCache(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		req := &CreateRequest{OrgIdHeader: testOrgId, ApplicationType: testApplicationType}
+
+		err := req.ResolveSteps(context.Background())
+		if err != nil || len(req.SuperKeySteps) != 2 {
+			t.Fatalf("want the steps of the metadata, got %+v and %v", req.SuperKeySteps, err)
+		}
+	}
+
+	if got := len(server.RequestsTo(http.MethodGet, "/api/sources/v3.1/application_types")); got != 1 {
+		t.Errorf("want the steps to be fetched once, got %d requests", got)
+	}
+}
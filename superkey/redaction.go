@@ -0,0 +1,88 @@
+package superkey
+
+import (
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+)
+
+// MarshalLog returns the request without the identity header, the superkey's identifier and the sensitive extra
+// values, and with only the names of the superkey steps.
+func (req *CreateRequest) MarshalLog() map[string]interface{} {
+	if req == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"org_id":           req.OrgIdHeader,
+		"tenant_id":        req.TenantID,
+		"source_id":        req.SourceID,
+		"application_id":   req.ApplicationID,
+		"application_type": req.ApplicationType,
+		"provider":         req.Provider,
+		"extra":            l.RedactMap(req.Extra),
+		"superkey_steps":   stepNames(req.SuperKeySteps),
+	}
+}
+
+// MarshalLog returns the request without the identity header, the superkey's identifier and the sensitive values of
+// the completed steps.
+func (req *DestroyRequest) MarshalLog() map[string]interface{} {
+	if req == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"org_id":          req.OrgIdHeader,
+		"tenant_id":       req.TenantID,
+		"source_id":       req.SourceID,
+		"application_id":  req.ApplicationID,
+		"guid":            req.GUID,
+		"provider":        req.Provider,
+		"steps_completed": l.RedactNestedMap(req.StepsCompleted),
+		"superkey_steps":  stepNames(req.SuperKeySteps),
+	}
+}
+
+// MarshalLog returns the request without the identity header, the superkey's identifier and the sensitive extra and
+// completed steps values, and with only the names of the superkey steps.
+func (req *UpdateRequest) MarshalLog() map[string]interface{} {
+	if req == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"org_id":           req.OrgIdHeader,
+		"tenant_id":        req.TenantID,
+		"source_id":        req.SourceID,
+		"application_id":   req.ApplicationID,
+		"application_type": req.ApplicationType,
+		"guid":             req.GUID,
+		"provider":         req.Provider,
+		"extra":            l.RedactMap(req.Extra),
+		"steps_completed":  l.RedactNestedMap(req.StepsCompleted),
+		"superkey_steps":   stepNames(req.SuperKeySteps),
+	}
+}
+
+// MarshalLog returns the state of the forged application without the sensitive values of the completed steps, along
+// with its redacted request.
+func (f *ForgedApplication) MarshalLog() map[string]interface{} {
+	if f == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"guid":            f.GUID,
+		"steps_completed": l.RedactNestedMap(f.StepsCompleted),
+		"request":         f.Request.MarshalLog(),
+	}
+}
+
+// stepNames returns the names of the given steps, which is all the logs need from them.
+func stepNames(steps []Step) []string {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,249 @@
+package superkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/sources"
+)
+
+// The statuses a resource can end up in after a teardown. A resource that was
+// already absent counts as torn down.
+const (
+	TeardownDeleted       = "deleted"
+	TeardownAlreadyAbsent = "already_absent"
+	TeardownFailed        = "failed"
+	TeardownSkipped       = "skipped"
+)
+
+// NewTeardownReport returns an empty teardown report.
+func NewTeardownReport() *TeardownReport {
+	return &TeardownReport{Results: make([]TeardownResult, 0)}
+}
+
+// Add appends the result of tearing down a resource to the report.
+func (r *TeardownReport) Add(result TeardownResult) {
+	r.Results = append(r.Results, result)
+}
+
+// SkipAll marks every given step as skipped because of the given error.
+func (r *TeardownReport) SkipAll(f *ForgedApplication, steps []string, reason error) {
+	for _, step := range steps {
+		r.Add(TeardownResult{Step: step, Resource: f.StepResource(step), Status: TeardownSkipped, Err: reason})
+	}
+}
+
+// Succeeded returns true when every resource was either deleted or already
+// absent.
+func (r *TeardownReport) Succeeded() bool {
+	return len(r.Unfinished()) == 0
+}
+
+// Unfinished returns the results of the resources that still exist, or might
+// still exist, because they either failed to be torn down or were skipped.
+func (r *TeardownReport) Unfinished() []TeardownResult {
+	unfinished := make([]TeardownResult, 0)
+	for _, result := range r.Results {
+		if result.Status == TeardownFailed || result.Status == TeardownSkipped {
+			unfinished = append(unfinished, result)
+		}
+	}
+
+	return unfinished
+}
+
+// Err returns the errors of the resources that could not be torn down joined
+// together, or nil when the teardown succeeded.
+func (r *TeardownReport) Err() error {
+	errs := make([]error, 0)
+	for _, result := range r.Unfinished() {
+		errs = append(errs, result.Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Summary returns a human readable count of the resources in each status.
+func (r *TeardownReport) Summary() string {
+	counts := make(map[string]int)
+	for _, result := range r.Results {
+		counts[result.Status]++
+	}
+
+	return fmt.Sprintf("%d deleted, %d already absent, %d failed, %d skipped", counts[TeardownDeleted], counts[TeardownAlreadyAbsent], counts[TeardownFailed], counts[TeardownSkipped])
+}
+
+// TeardownSummary builds what gets reported back about the teardown of the forged
+// application. The steps that could not be torn down are retained, along with
+// the reason why, so that they can be retried.
+func (f *ForgedApplication) TeardownSummary(report *TeardownReport) *TeardownSummary {
+	summary := &TeardownSummary{
+		TenantID:       f.Request.TenantID,
+		SourceID:       f.Request.SourceID,
+		ApplicationID:  f.Request.ApplicationID,
+		GUID:           f.GUID,
+		Provider:       f.Request.Provider,
+		Complete:       report.Succeeded(),
+		RemovedSteps:   make([]string, 0),
+		RemainingSteps: make([]string, 0),
+		Retained:       make(map[string]map[string]string),
+	}
+
+	for _, result := range report.Results {
+		if result.Status == TeardownDeleted || result.Status == TeardownAlreadyAbsent {
+			summary.RemovedSteps = append(summary.RemovedSteps, result.Step)
+			continue
+		}
+
+		summary.RemainingSteps = append(summary.RemainingSteps, result.Step)
+
+		retained := make(map[string]string, len(f.StepsCompleted[result.Step])+3)
+		for k, v := range f.StepsCompleted[result.Step] {
+			retained[k] = v
+		}
+
+		retained["status"] = result.Status
+		if result.ErrorClass != "" {
+			retained["error_class"] = result.ErrorClass
+		}
+		if result.Err != nil {
+			retained["error"] = result.Err.Error()
+		}
+
+		summary.Retained[result.Step] = retained
+	}
+
+	return summary
+}
+
+// StoreTeardownInSourcesAPI updates the application's "_superkey" extra with
+// the steps that were removed and the ones that remain. The remaining steps
+// are kept as the application's superkey steps so that the teardown can be
+// retried, and the reason why they remain is stored in the
+// "_superkey_retained" extra.
+// returns: an error wrapping sources.ErrNotFound when the application does not
+// exist anymore.
+func (f *ForgedApplication) StoreTeardownInSourcesAPI(ctx context.Context, summary *TeardownSummary) error {
+	remaining := make(map[string]map[string]string, len(summary.RemainingSteps))
+	for _, step := range summary.RemainingSteps {
+		remaining[step] = f.StepsCompleted[step]
+	}
+
+	extra := map[string]interface{}{
+		"_superkey": map[string]interface{}{
+			"steps":           remaining,
+			"guid":            f.GUID,
+			"provider":        f.Request.Provider,
+			"removed_steps":   summary.RemovedSteps,
+			"remaining_steps": summary.RemainingSteps,
+		},
+	}
+
+	// A complete teardown clears what a previous, partial one retained.
+	if summary.Complete {
+		extra["_superkey_retained"] = nil
+	} else {
+		extra["_superkey_retained"] = summary.Retained
+	}
+
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
+
+	err = sourcesClient.PatchApplication(ctx, authData, f.Request.ApplicationID, &sources.PatchApplicationRequest{Extra: extra})
+	if err != nil {
+		return fmt.Errorf("failed to update the application with the teardown results: %w", err)
+	}
+
+	l.LogWithContext(ctx).Info("Teardown results stored in Sources")
+
+	return nil
+}
+
+// StoreRetriedTeardownInSourcesAPI removes the step whose queued teardown
+// succeeded from the steps the application's "_superkey" extra keeps, and
+// from its "_superkey_retained" extra, which gets cleared once no retained
+// step is left. Nothing is updated when the application no longer exists or
+// holds the resources of another GUID.
+func (f *ForgedApplication) StoreRetriedTeardownInSourcesAPI(ctx context.Context, step string) error {
+	sourcesClient, err := sources.Client()
+	if err != nil {
+		return err
+	}
+
+	authData := &sources.AuthenticationData{
+		IdentityHeader: f.Request.IdentityHeader,
+		OrgId:          f.Request.OrgIdHeader,
+	}
+
+	application, err := sourcesClient.GetApplication(ctx, authData, f.Request.ApplicationID)
+	if errors.Is(err, sources.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch the application to update its teardown results: %w", err)
+	}
+
+	state, err := ParseSuperKeyExtra(application.Extra)
+	if errors.Is(err, ErrNoSuperKeyState) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if state.GUID != f.GUID {
+		return nil
+	}
+
+	retainedExtra := struct {
+		Retained map[string]map[string]string `json:"_superkey_retained"`
+	}{}
+	err = json.Unmarshal(application.Extra, &retainedExtra)
+	if err != nil {
+		return fmt.Errorf("unable to decode the application's retained steps: %w", err)
+	}
+
+	delete(state.Steps, step)
+	delete(retainedExtra.Retained, step)
+
+	remainingSteps := make([]string, 0, len(state.RemainingSteps))
+	for _, remaining := range state.RemainingSteps {
+		if remaining != step {
+			remainingSteps = append(remainingSteps, remaining)
+		}
+	}
+
+	extra := map[string]interface{}{
+		"_superkey": map[string]interface{}{
+			"steps":           state.Steps,
+			"guid":            state.GUID,
+			"provider":        state.Provider,
+			"removed_steps":   append(state.RemovedSteps, step),
+			"remaining_steps": remainingSteps,
+		},
+		"_superkey_retained": nil,
+	}
+
+	if len(retainedExtra.Retained) != 0 {
+		extra["_superkey_retained"] = retainedExtra.Retained
+	}
+
+	err = sourcesClient.PatchApplication(ctx, authData, f.Request.ApplicationID, &sources.PatchApplicationRequest{Extra: extra})
+	if err != nil {
+		return fmt.Errorf("failed to update the application with the retried teardown: %w", err)
+	}
+
+	l.LogWithContext(ctx).Infof(`Retried teardown of the "%s" step stored in Sources`, step)
+
+	return nil
+}
@@ -2,6 +2,8 @@ package superkey
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/RedHatInsights/sources-api-go/model"
 )
@@ -31,7 +33,11 @@ type Step struct {
 // DestroyRequest - struct representing a teardown request for an application
 // created through superkey
 type DestroyRequest struct {
+	IdentityHeader string                       `json:"identity_header"`
+	OrgIdHeader    string                       `json:"org_id_header"`
 	TenantID       string                       `json:"tenant_id"`
+	SourceID       string                       `json:"source_id"`
+	ApplicationID  string                       `json:"application_id"`
 	SuperKey       string                       `json:"super_key"`
 	GUID           string                       `json:"guid"`
 	Provider       string                       `json:"provider"`
@@ -39,6 +45,24 @@ type DestroyRequest struct {
 	SuperKeySteps  []Step                       `json:"superkey_steps"`
 }
 
+// UpdateRequest - struct representing a request to reconcile the resources of
+// an application created through superkey with its new superkey steps. The
+// GUID and the completed steps come from the application's "_superkey" extra.
+type UpdateRequest struct {
+	IdentityHeader  string                       `json:"identity_header"`
+	OrgIdHeader     string                       `json:"org_id_header"`
+	TenantID        string                       `json:"tenant_id"`
+	SourceID        string                       `json:"source_id"`
+	ApplicationID   string                       `json:"application_id"`
+	ApplicationType string                       `json:"application_type"`
+	SuperKey        string                       `json:"super_key"`
+	GUID            string                       `json:"guid"`
+	Provider        string                       `json:"provider"`
+	Extra           map[string]string            `json:"extra"`
+	StepsCompleted  map[string]map[string]string `json:"steps_completed"`
+	SuperKeySteps   []Step                       `json:"superkey_steps"`
+}
+
 // App - represents an application that can be posted to sources after being
 // populated
 type App struct {
@@ -55,11 +79,135 @@ type ForgedApplication struct {
 	Request        *CreateRequest
 	Client         Provider
 	GUID           string
+	// Operation is the event type of the operation being performed on the
+	// application, which identifies it in the journal along with the GUID.
+	Operation string
+	// registrationAttempt counts the rolled back registrations of the
+	// application in Sources, which scopes the idempotency keys of their
+	// requests. A resumed or recovered registration replays the requests of
+	// the interrupted one with the same keys.
+	registrationAttempt int
 }
 
-// Provider the interface for all of the superkey providers currently just a
-// single method is needed (ForgeApplication)
+// Provider the interface for all of the superkey providers, which need to be
+// able to forge, update and tear down applications
 type Provider interface {
 	ForgeApplication(ctx context.Context, createRequest *CreateRequest) (*ForgedApplication, error)
-	TearDown(ctx context.Context, forgedApplication *ForgedApplication) []error
+	UpdateApplication(ctx context.Context, forgedApplication *ForgedApplication) error
+	TearDown(ctx context.Context, forgedApplication *ForgedApplication) *TeardownReport
+	TearDownStep(ctx context.Context, forgedApplication *ForgedApplication, step string) TeardownResult
+	StepExists(ctx context.Context, forgedApplication *ForgedApplication, step string) (bool, error)
+}
+
+// TeardownReport - lists what happened to each of the resources of a forged
+// application when tearing it down
+type TeardownReport struct {
+	Results []TeardownResult
+}
+
+// TeardownResult - the outcome of tearing down the resource created by a
+// completed step. The error class and the error are only set for the failed
+// and the skipped resources.
+type TeardownResult struct {
+	Step       string
+	Resource   string
+	Status     string
+	ErrorClass string
+	Err        error
+}
+
+// The reasons a create request gets skipped for after checking the state of
+// its application in Sources.
+const (
+	SkipApplicationGone    = "application_gone"
+	SkipSourceGone         = "source_gone"
+	SkipPaused             = "paused"
+	SkipAlreadyProvisioned = "already_provisioned"
+)
+
+// SkipReason - why the resources of a create request should not be forged,
+// with the reason code and a message for the logs
+type SkipReason struct {
+	Code    string
+	Message string
+}
+
+// StepsCache - caches the superkey steps of the application types, so that
+// Sources does not get asked for them on every request
+type StepsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]stepsCacheEntry
+}
+
+// stepsCacheEntry - the superkey steps of an application type, along with
+// when they were fetched
+type stepsCacheEntry struct {
+	steps     []Step
+	fetchedAt time.Time
+}
+
+// registrationUndo - undoes a registration step that was completed in Sources,
+// so that a failed registration does not leave anything behind
+type registrationUndo struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// SuperKeyExtra - the "_superkey" key of an application's extra, which holds
+// the state of the resources forged for the application
+type SuperKeyExtra struct {
+	GUID           string                       `json:"guid"`
+	Provider       string                       `json:"provider"`
+	Steps          map[string]map[string]string `json:"steps"`
+	RemovedSteps   []string                     `json:"removed_steps,omitempty"`
+	RemainingSteps []string                     `json:"remaining_steps,omitempty"`
+}
+
+// TeardownSummary - what gets reported back to Sources, or published as an
+// event when the application no longer exists, once a teardown is done
+type TeardownSummary struct {
+	TenantID       string                       `json:"tenant_id"`
+	SourceID       string                       `json:"source_id,omitempty"`
+	ApplicationID  string                       `json:"application_id,omitempty"`
+	GUID           string                       `json:"guid"`
+	Provider       string                       `json:"provider"`
+	Complete       bool                         `json:"complete"`
+	RemovedSteps   []string                     `json:"removed_steps"`
+	RemainingSteps []string                     `json:"remaining_steps"`
+	Retained       map[string]map[string]string `json:"retained"`
+}
+
+// ValidationError - holds the field-level violations found when validating a
+// request against its JSON schema
+type ValidationError struct {
+	Violations []string
+}
+
+// Journal - records the intent and the outcome of every step of the operations
+// performed on forged applications, so that the operations interrupted by a
+// crash can be resumed or rolled back on startup
+type Journal interface {
+	// Begin registers a new operation for the forged application, along with
+	// the state the application is in before the operation starts.
+	Begin(f *ForgedApplication, eventType string) error
+	// RecordIntent records that the given action is about to be performed on
+	// the step, along with the data that identifies the affected resource.
+	RecordIntent(f *ForgedApplication, action, step string, data map[string]string) error
+	// RecordOutcome records the outcome of an action previously registered
+	// with RecordIntent, along with the resulting data of the step.
+	RecordOutcome(f *ForgedApplication, action, step string, data map[string]string, stepErr error) error
+	// SetPhase records that the operation moved to the given phase.
+	SetPhase(f *ForgedApplication, phase string) error
+	// Finish marks the operation as finished with the given final phase, along
+	// with the error that made the operation fail, if any.
+	Finish(f *ForgedApplication, phase string, opErr error) error
+}
+
+// PlannedStep is a resource that forging a request would create, along with the substituted payload it would be
+// created with.
+type PlannedStep struct {
+	Step     string `json:"step"`
+	Resource string `json:"resource"`
+	Payload  string `json:"payload,omitempty"`
 }
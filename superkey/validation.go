@@ -0,0 +1,126 @@
+package superkey
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultSchemaVersion is the schema version assumed for the messages that do not specify one in their headers.
+const DefaultSchemaVersion = "v1"
+
+//go:embed schemas
+var schemaFiles embed.FS
+
+// schemas holds the compiled JSON schemas, indexed by schema version and by event type.
+var schemas = compileSchemas()
+
+// compileSchemas compiles every embedded schema. The schemas are laid out as "schemas/<version>/<event_type>.json".
+func compileSchemas() map[string]map[string]*jsonschema.Schema {
+	compiled := make(map[string]map[string]*jsonschema.Schema)
+
+	versions, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(fmt.Sprintf("unable to read the embedded superkey schemas: %s", err))
+	}
+
+	for _, version := range versions {
+		files, err := schemaFiles.ReadDir(path.Join("schemas", version.Name()))
+		if err != nil {
+			panic(fmt.Sprintf(`unable to read the embedded superkey schemas for version "%s": %s`, version.Name(), err))
+		}
+
+		compiled[version.Name()] = make(map[string]*jsonschema.Schema)
+		for _, file := range files {
+			filePath := path.Join("schemas", version.Name(), file.Name())
+
+			raw, err := schemaFiles.ReadFile(filePath)
+			if err != nil {
+				panic(fmt.Sprintf(`unable to read the embedded superkey schema "%s": %s`, filePath, err))
+			}
+
+			compiler := jsonschema.NewCompiler()
+			if err := compiler.AddResource(filePath, bytes.NewReader(raw)); err != nil {
+				panic(fmt.Sprintf(`unable to load the superkey schema "%s": %s`, filePath, err))
+			}
+
+			eventType := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+			compiled[version.Name()][eventType] = compiler.MustCompile(filePath)
+		}
+	}
+
+	return compiled
+}
+
+// ValidateRequest validates the raw request against the JSON schema of the given version and event type. When the
+// request does not conform to the schema, a "*ValidationError" is returned listing every violation.
+func ValidateRequest(schemaVersion string, eventType string, raw []byte) error {
+	if schemaVersion == "" {
+		schemaVersion = DefaultSchemaVersion
+	}
+
+	versionSchemas, ok := schemas[schemaVersion]
+	if !ok {
+		return &ValidationError{Violations: []string{fmt.Sprintf(`unsupported schema version "%s"`, schemaVersion)}}
+	}
+
+	// Only the event types whose requests changed get a schema in the later versions, the others keep the one of the
+	// default version.
+	schema, ok := versionSchemas[eventType]
+	if !ok {
+		schema, ok = schemas[DefaultSchemaVersion][eventType]
+	}
+	if !ok {
+		return &ValidationError{Violations: []string{fmt.Sprintf(`no schema for event type "%s" in schema version "%s"`, eventType, schemaVersion)}}
+	}
+
+	var request interface{}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return &ValidationError{Violations: []string{fmt.Sprintf("the request is not valid JSON: %s", err)}}
+	}
+
+	err := schema.Validate(request)
+	if err == nil {
+		return nil
+	}
+
+	schemaErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return fmt.Errorf("unable to validate the request: %w", err)
+	}
+
+	violations := make([]string, 0)
+	collectViolations(schemaErr, &violations)
+	sort.Strings(violations)
+
+	return &ValidationError{Violations: violations}
+}
+
+// collectViolations walks the validation error tree and collects the leaf errors, which are the ones that point to
+// the offending fields.
+func collectViolations(err *jsonschema.ValidationError, violations *[]string) {
+	if len(err.Causes) == 0 {
+		field := strings.ReplaceAll(strings.TrimPrefix(err.InstanceLocation, "/"), "/", ".")
+		if field == "" {
+			field = "(root)"
+		}
+
+		*violations = append(*violations, fmt.Sprintf("%s: %s", field, err.Message))
+		return
+	}
+
+	for _, cause := range err.Causes {
+		collectViolations(cause, violations)
+	}
+}
+
+// Error returns every violation in a single line.
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("invalid superkey request: %s", strings.Join(v.Violations, "; "))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	l "github.com/redhatinsights/sources-superkey-worker/logger"
+	"github.com/redhatinsights/sources-superkey-worker/provider"
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	"github.com/redhatinsights/sources-superkey-worker/teardownqueue"
+)
+
+// teardownRetryInterval is how often the queue is checked for teardowns that are due to be retried.
+const teardownRetryInterval = 30 * time.Second
+
+// teardownQueue holds the teardowns that failed and need to be retried. It is nil when the retries are disabled.
+var teardownQueue *teardownqueue.Queue
+
+// queueFailedTeardowns queues the resources that could not be torn down, so that they get retried in the background.
+func queueFailedTeardowns(ctx context.Context, f *superkey.ForgedApplication, report *superkey.TeardownReport) {
+	unfinished := report.Unfinished()
+	if teardownQueue == nil || f == nil || len(unfinished) == 0 {
+		return
+	}
+
+	for i, result := range unfinished {
+		item := teardownqueue.NewItem(f, result.Step, i, result.Err)
+
+		err := teardownQueue.Enqueue(item, conf.TeardownRetryBaseDelay)
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Unable to queue the teardown of the "%s" resource "%s" for retrying, it needs to be removed manually: %s`, item.ResourceType, item.Identifier, err)
+			continue
+		}
+
+		queuedTeardownsCounter.Inc()
+		l.LogWithContext(ctx).Warnf(`Queued the teardown of the "%s" resource "%s" for retrying`, item.ResourceType, item.Identifier)
+	}
+
+	updateTeardownQueueSize()
+}
+
+// retryFailedTeardowns periodically retries the queued teardowns that are due, until the context gets cancelled.
+func retryFailedTeardowns(ctx context.Context) {
+	ticker := time.NewTicker(teardownRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			items, err := teardownQueue.Due(time.Now())
+			if err != nil {
+				l.Log.Errorf("Unable to get the teardowns to retry: %s", err)
+				continue
+			}
+
+			// The remaining resources of an application wait for the next round once one of them fails, so that its
+			// resources keep being torn down in order.
+			failed := make(map[string]bool)
+			for _, item := range items {
+				if ctx.Err() != nil {
+					return
+				}
+
+				if failed[item.GUID] {
+					continue
+				}
+
+				if !retryTeardown(item) {
+					failed[item.GUID] = true
+				}
+			}
+
+			updateTeardownQueueSize()
+		}
+	}
+}
+
+// retryTeardown retries the teardown of the queued resource, unless it has already been deleted. Resources that
+// could not be torn down for longer than the maximum age are given up on, and reported to Sources.
+// returns: false when the retry failed and got rescheduled.
+func retryTeardown(item *teardownqueue.Item) bool {
+	f := item.ForgedApplication()
+
+	// The queued items always carry the GUID, so there is always a key to lock on.
+	lockKey, _ := applicationLockKey(f.Request.SourceID, f.GUID)
+	unlock := applicationLocks.Lock(lockKey)
+	defer unlock()
+
+	// Define the log context with the fields we want to log.
+	ctx := l.WithTenantId(context.Background(), f.Request.TenantID)
+	ctx = l.WithSourceId(ctx, f.Request.SourceID)
+	ctx = l.WithApplicationId(ctx, f.Request.ApplicationID)
+	ctx = l.WithApplicationType(ctx, f.Request.ApplicationType)
+
+	if time.Since(item.FirstFailedAt) > conf.TeardownRetryMaxAge {
+		abandonTeardown(ctx, item)
+		return true
+	}
+
+	var result superkey.TeardownResult
+
+	err := f.BeginOperation(ctx, "destroy_application")
+	if err == nil {
+		result = provider.RetryTearDownStep(ctx, f, item.ResourceType)
+		teardownResourcesCounter.WithLabelValues(result.Step, result.Status, result.ErrorClass).Inc()
+
+		err = result.Err
+		if err != nil {
+			f.FinishOperation(ctx, superkey.PhaseFailed, err)
+		} else {
+			f.FinishOperation(ctx, superkey.PhaseCompleted, nil)
+		}
+	}
+
+	if err != nil {
+		delay := teardownqueue.Backoff(item.Attempts+1, conf.TeardownRetryBaseDelay, conf.TeardownRetryMaxDelay)
+
+		l.LogWithContext(ctx).Warnf(`Unable to tear down the "%s" resource "%s" after %d attempts, retrying in %s: %s`, item.ResourceType, item.Identifier, item.Attempts+1, delay, err)
+		failedTeardownRetriesCounter.Inc()
+
+		err := teardownQueue.Reschedule(item, err, delay)
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Unable to reschedule the teardown of the "%s" resource "%s": %s`, item.ResourceType, item.Identifier, err)
+		}
+
+		return false
+	}
+
+	if result.Status == superkey.TeardownAlreadyAbsent {
+		l.LogWithContext(ctx).Infof(`Skipping the teardown of the "%s" resource "%s" because it has already been deleted`, item.ResourceType, item.Identifier)
+	} else {
+		l.LogWithContext(ctx).Infof(`Tore down the "%s" resource "%s" after %d failed attempts`, item.ResourceType, item.Identifier, item.Attempts+1)
+	}
+	successfulTeardownRetriesCounter.Inc()
+
+	// The resource is no longer retained by the application once it is gone.
+	if f.Request.ApplicationID != "" {
+		err = f.StoreRetriedTeardownInSourcesAPI(ctx, item.ResourceType)
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Unable to remove the "%s" resource from the retained steps of the application in Sources: %s`, item.ResourceType, err)
+		}
+	}
+
+	err = teardownQueue.Remove(item.ID)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to remove the teardown of the "%s" resource "%s" from the queue: %s`, item.ResourceType, item.Identifier, err)
+	}
+
+	return true
+}
+
+// abandonTeardown stops retrying the teardown of the queued resource, and reports the leaked resource to Sources when
+// the application it belongs to is known.
+func abandonTeardown(ctx context.Context, item *teardownqueue.Item) {
+	l.LogWithContext(ctx).Errorf(`Giving up on tearing down the "%s" resource "%s" after %d attempts since %s, it needs to be removed manually: %s`, item.ResourceType, item.Identifier, item.Attempts+1, item.FirstFailedAt.Format(time.RFC3339), item.LastError)
+	abandonedTeardownsCounter.Inc()
+
+	if item.Request != nil && item.Request.ApplicationID != "" {
+		err := item.Request.MarkTeardownAbandoned(ctx, item.ResourceType, item.Identifier, errors.New(item.LastError))
+		if err != nil {
+			l.LogWithContext(ctx).Errorf(`Error while reporting the abandoned teardown to the application in Sources: %s`, err)
+		}
+	}
+
+	err := teardownQueue.Remove(item.ID)
+	if err != nil {
+		l.LogWithContext(ctx).Errorf(`Unable to remove the teardown of the "%s" resource "%s" from the queue: %s`, item.ResourceType, item.Identifier, err)
+	}
+}
+
+// updateTeardownQueueSize updates the gauge with the number of queued teardowns.
+func updateTeardownQueueSize() {
+	size, err := teardownQueue.Len()
+	if err != nil {
+		l.Log.Errorf("Unable to get the size of the teardown queue: %s", err)
+		return
+	}
+
+	teardownQueueSizeGauge.Set(float64(size))
+}
@@ -0,0 +1,199 @@
+package teardownqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	bolt "go.etcd.io/bbolt"
+)
+
+// itemsBucket is the bucket the items are stored in, keyed by their ID.
+var itemsBucket = []byte("teardowns")
+
+// Open opens, or creates, the queue database file at the given path.
+func Open(path string) (*Queue, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf(`unable to open the teardown queue file "%s": %w`, path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(itemsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf(`unable to initialize the teardown queue file "%s": %w`, path, err)
+	}
+
+	return &Queue{db: db}, nil
+}
+
+// Close closes the queue's database file.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// NewItem builds the item for the resource created by the given step of the forged application. The order is the
+// position of the resource in the teardown, which its retries have to follow.
+func NewItem(f *superkey.ForgedApplication, step string, order int, stepErr error) *Item {
+	steps := make(map[string]map[string]string, len(f.StepsCompleted))
+	for name, data := range f.StepsCompleted {
+		steps[name] = data
+	}
+
+	return &Item{
+		ID:           f.GUID + "/" + step,
+		GUID:         f.GUID,
+		ResourceType: step,
+		Order:        order,
+		Identifier:   f.StepResource(step),
+		Request:      f.Request,
+		Steps:        steps,
+		LastError:    stepErr.Error(),
+	}
+}
+
+// Enqueue stores the item, scheduling its first retry after the given delay. When the resource is already queued, its
+// attempts and the time of its first failure are kept, so that an item cannot outlive its maximum age by failing again.
+func (q *Queue) Enqueue(item *Item, delay time.Duration) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(itemsBucket)
+
+		now := time.Now()
+		item.FirstFailedAt = now
+		item.NextAttemptAt = now.Add(delay)
+
+		if raw := bucket.Get([]byte(item.ID)); raw != nil {
+			existing := &Item{}
+			if err := json.Unmarshal(raw, existing); err != nil {
+				return fmt.Errorf("unable to unmarshal the queued teardown: %w", err)
+			}
+
+			item.Attempts = existing.Attempts
+			item.FirstFailedAt = existing.FirstFailedAt
+		}
+
+		return put(bucket, item)
+	})
+}
+
+// Reschedule stores the failure of a retry, scheduling the next one after the given delay.
+func (q *Queue) Reschedule(item *Item, retryErr error, delay time.Duration) error {
+	item.Attempts++
+	item.LastError = retryErr.Error()
+	item.NextAttemptAt = time.Now().Add(delay)
+
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(itemsBucket), item)
+	})
+}
+
+// Remove removes the item from the queue.
+func (q *Queue) Remove(id string) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).Delete([]byte(id))
+	})
+}
+
+// Due returns the items whose next retry is due at the given time, in the order their teardowns first failed. The
+// retries of an application's resources are serialized: an item is held back while an earlier item of the same
+// resources is still waiting for its own retry, since the resources have to be torn down in order.
+func (q *Queue) Due(now time.Time) ([]*Item, error) {
+	items := make([]*Item, 0)
+
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).ForEach(func(_, raw []byte) error {
+			item := &Item{}
+			if err := json.Unmarshal(raw, item); err != nil {
+				return err
+			}
+
+			items = append(items, item)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the due teardowns from the queue: %w", err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].FirstFailedAt.Equal(items[j].FirstFailedAt) {
+			return items[i].FirstFailedAt.Before(items[j].FirstFailedAt)
+		}
+
+		return items[i].Order < items[j].Order
+	})
+
+	due := make([]*Item, 0)
+	waiting := make(map[string]bool)
+	for _, item := range items {
+		if waiting[item.GUID] {
+			continue
+		}
+
+		if item.NextAttemptAt.After(now) {
+			waiting[item.GUID] = true
+			continue
+		}
+
+		due = append(due, item)
+	}
+
+	return due, nil
+}
+
+// Len returns the number of queued items.
+func (q *Queue) Len() (int, error) {
+	var length int
+
+	err := q.db.View(func(tx *bolt.Tx) error {
+		length = tx.Bucket(itemsBucket).Stats().KeyN
+		return nil
+	})
+
+	return length, err
+}
+
+// ForgedApplication rebuilds the forged application the item's resource belongs to.
+func (item *Item) ForgedApplication() *superkey.ForgedApplication {
+	request := item.Request
+	if request == nil {
+		request = &superkey.CreateRequest{}
+	}
+
+	return &superkey.ForgedApplication{
+		StepsCompleted: item.Steps,
+		Request:        request,
+		GUID:           item.GUID,
+	}
+}
+
+// Backoff returns the delay before the next retry of an item that has been attempted the given number of times, which
+// doubles on every attempt up to the given maximum.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
+
+// put stores the item in the given bucket.
+func put(bucket *bolt.Bucket, item *Item) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the queued teardown: %w", err)
+	}
+
+	return bucket.Put([]byte(item.ID), raw)
+}
@@ -0,0 +1,31 @@
+package teardownqueue
+
+import (
+	"time"
+
+	"github.com/redhatinsights/sources-superkey-worker/superkey"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Queue is the persistent queue of the teardowns that failed and need to be retried, backed by an embedded bbolt
+// database file which is expected to live on a volume that survives the worker's restarts.
+type Queue struct {
+	db *bolt.DB
+}
+
+// Item is a resource that could not be torn down. Along with the resource, it holds the request with the superkey and
+// the tenant it belongs to, and a snapshot of the completed steps of the forged application, since some resources
+// depend on the others to be identified.
+type Item struct {
+	ID            string                       `json:"id"`
+	GUID          string                       `json:"guid"`
+	ResourceType  string                       `json:"resource_type"`
+	Order         int                          `json:"order"`
+	Identifier    string                       `json:"identifier"`
+	Request       *superkey.CreateRequest      `json:"request"`
+	Steps         map[string]map[string]string `json:"steps"`
+	Attempts      int                          `json:"attempts"`
+	LastError     string                       `json:"last_error"`
+	FirstFailedAt time.Time                    `json:"first_failed_at"`
+	NextAttemptAt time.Time                    `json:"next_attempt_at"`
+}